@@ -0,0 +1,227 @@
+package analysis
+
+import (
+	"RoLang/ast"
+)
+
+// control-flow graph of a function body, the statements are split
+// into basic blocks joined by the edges control may take between them
+type CFG struct {
+	Entry  *Block
+	Exit   *Block // reached by every return and by the end of the body
+	Blocks []*Block
+}
+
+// a run of nodes always executed in order, a block ending in a
+// branch holds the condition as its last node
+type Block struct {
+	Index int
+	Nodes []ast.Node
+	Succs []*Block
+	Preds []*Block
+}
+
+// Counts the edges of the graph
+func (g *CFG) Edges() int {
+	edges := 0
+	for _, block := range g.Blocks {
+		edges += len(block.Succs)
+	}
+
+	return edges
+}
+
+// Builds the control-flow graph of fn, following if/else, loops,
+// labeled blocks, break, continue and return. Statements after
+// a jump start a block with no predecessors.
+func BuildCFG(fn *ast.FunctionLiteral) *CFG {
+	b := &builder{cfg: &CFG{}}
+	b.cfg.Entry = b.newBlock()
+	b.cfg.Exit = b.newBlock()
+
+	b.current = b.cfg.Entry
+	if fn.Body != nil {
+		b.stmtList(fn.Body.Statements)
+	}
+	b.jump(b.cfg.Exit)
+
+	return b.cfg
+}
+
+type builder struct {
+	cfg *CFG
+	// block being filled, nil right after a jump
+	current *Block
+	targets *targets
+}
+
+// where break and continue go inside a loop or labeled block,
+// cont is nil for blocks
+type targets struct {
+	tail  *targets
+	label string
+	brk   *Block
+	cont  *Block
+}
+
+func (b *builder) newBlock() *Block {
+	block := &Block{Index: len(b.cfg.Blocks)}
+	b.cfg.Blocks = append(b.cfg.Blocks, block)
+
+	return block
+}
+
+func (b *builder) add(node ast.Node) {
+	if b.current == nil {
+		b.current = b.newBlock()
+	}
+	b.current.Nodes = append(b.current.Nodes, node)
+}
+
+// ends the current block with an edge to target
+func (b *builder) jump(target *Block) {
+	if b.current != nil {
+		addEdge(b.current, target)
+	}
+	b.current = nil
+}
+
+// ends the current block with a branch on its last node
+func (b *builder) branch(then, elze *Block) {
+	addEdge(b.current, then)
+	addEdge(b.current, elze)
+	b.current = nil
+}
+
+func addEdge(from, to *Block) {
+	from.Succs = append(from.Succs, to)
+	to.Preds = append(to.Preds, from)
+}
+
+func (b *builder) stmtList(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		b.stmt(stmt)
+	}
+}
+
+func (b *builder) stmt(stmt ast.Statement) {
+	switch s := stmt.(type) {
+	case *ast.BlockStatement:
+		if s.Label == nil {
+			b.stmtList(s.Statements)
+			return
+		}
+
+		done := b.newBlock()
+		b.targets = &targets{tail: b.targets, label: s.Label.Value, brk: done}
+		b.stmtList(s.Statements)
+		b.targets = b.targets.tail
+		b.jump(done)
+		b.current = done
+	case *ast.AttributedStatement:
+		b.stmt(s.Stmt)
+	case *ast.IfStatement:
+		if s.Binding != nil {
+			b.add(s.Binding)
+		} else {
+			b.add(s.Condition)
+		}
+
+		then, done := b.newBlock(), b.newBlock()
+		elze := done
+		if s.Else != nil {
+			elze = b.newBlock()
+		}
+		b.branch(then, elze)
+
+		b.current = then
+		b.stmt(s.Then)
+		b.jump(done)
+
+		if s.Else != nil {
+			b.current = elze
+			b.stmt(s.Else)
+			b.jump(done)
+		}
+		b.current = done
+	case *ast.WhileStatement:
+		loop, body, done := b.newBlock(), b.newBlock(), b.newBlock()
+		b.jump(loop)
+
+		b.current = loop
+		b.add(s.Condition)
+		b.branch(body, done)
+
+		b.current = body
+		b.loop(s.Label, s.Body, done, loop)
+		b.jump(loop)
+		b.current = done
+	case *ast.ForInStatement:
+		// the iterable is evaluated once, the loop test takes its next element
+		b.add(s.Iterable)
+		loop, body, done := b.newBlock(), b.newBlock(), b.newBlock()
+		b.jump(loop)
+
+		b.current = loop
+		b.add(s.Var)
+		b.branch(body, done)
+
+		b.current = body
+		b.loop(s.Label, s.Body, done, loop)
+		b.jump(loop)
+		b.current = done
+	case *ast.DoWhileStatement:
+		body, cond, done := b.newBlock(), b.newBlock(), b.newBlock()
+		b.jump(body)
+
+		b.current = body
+		b.loop(s.Label, s.Body, done, cond)
+		b.jump(cond)
+
+		b.current = cond
+		b.add(s.Condition)
+		b.branch(body, done)
+		b.current = done
+	case *ast.BreakStatement:
+		b.add(s)
+		if t := b.lookUp(s.Label); t != nil {
+			b.jump(t.brk)
+		}
+	case *ast.ContinueStatement:
+		b.add(s)
+		if t := b.lookUp(s.Label); t != nil {
+			b.jump(t.cont)
+		}
+	case *ast.ReturnStatement:
+		b.add(s)
+		b.jump(b.cfg.Exit)
+	default:
+		b.add(s)
+	}
+}
+
+func (b *builder) loop(label *ast.Identifier, body *ast.BlockStatement, brk, cont *Block) {
+	t := &targets{tail: b.targets, brk: brk, cont: cont}
+	if label != nil {
+		t.label = label.Value
+	}
+
+	b.targets = t
+	b.stmt(body)
+	b.targets = t.tail
+}
+
+// finds the loop or block a jump goes to, without a label
+// that is the innermost loop
+func (b *builder) lookUp(label *ast.Identifier) *targets {
+	for t := b.targets; t != nil; t = t.tail {
+		switch {
+		case label != nil && t.label == label.Value:
+			return t
+		case label == nil && t.cont != nil:
+			return t
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package analysis
+
+import (
+	"RoLang/ast"
+	"RoLang/lexer"
+	"RoLang/parser"
+
+	"testing"
+)
+
+func TestBuildCFG(t *testing.T) {
+	tests := []struct {
+		input  string
+		blocks int
+		edges  int
+	}{
+		// entry, then, else, join and exit
+		{"fn(x) { if x { a; } else { b; } c; }", 5, 5},
+		// entry, loop test, body, after loop, then, after if and exit
+		{"fn() { while a { if b { break; } c; } d; }", 7, 8},
+		// returning from both branches leaves the join without predecessors
+		{"fn(x) { if x { return 1; } else { return 2; } }", 5, 5},
+		{"fn() { do { continue; } while a; }", 5, 5},
+		{"fn() { for x in xs { if x { continue; } } }", 7, 8},
+		{"fn() { outer: while a { while b { break outer; } } }", 8, 9},
+		{"fn() { }", 2, 1},
+	}
+
+	for _, test := range tests {
+		fn := parseFunction(t, test.input)
+		cfg := BuildCFG(fn)
+
+		if n := len(cfg.Blocks); n != test.blocks {
+			t.Errorf("%q - wrong number of blocks. expect=%d, got=%d", test.input, test.blocks, n)
+		}
+
+		if n := cfg.Edges(); n != test.edges {
+			t.Errorf("%q - wrong number of edges. expect=%d, got=%d", test.input, test.edges, n)
+		}
+	}
+}
+
+func TestBuildCFGEdges(t *testing.T) {
+	fn := parseFunction(t, "fn() { while a { if b { break; } c; } d; }")
+	cfg := BuildCFG(fn)
+
+	if n := len(cfg.Exit.Preds); n != 1 {
+		t.Fatalf("exit does not have 1 predecessor. got=%d", n)
+	}
+
+	// the break leaves the loop for the block holding `d;`
+	after := cfg.Exit.Preds[0]
+	if n := len(after.Preds); n != 2 {
+		t.Errorf("block after the loop does not have 2 predecessors. got=%d", n)
+	}
+
+	for _, block := range after.Preds {
+		last := block.Nodes[len(block.Nodes)-1]
+		switch last.(type) {
+		case *ast.Identifier, *ast.BreakStatement:
+		default:
+			t.Errorf("block %d jumps out of the loop on %T", block.Index, last)
+		}
+	}
+}
+
+func parseFunction(t *testing.T, input string) *ast.FunctionLiteral {
+	t.Helper()
+
+	p := parser.New(lexer.New("analysis_test", input+";"))
+	program := p.Parse()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser has errors: %v", p.Errors())
+	}
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	return stmt.Expression.(*ast.FunctionLiteral)
+}
@@ -3,24 +3,30 @@ package ast
 import (
 	"RoLang/token"
 
-	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
 )
 
 type (
+	// every node knows where in the source it starts
 	Node interface {
+		// the name of the node type, like "LetStatement"
+		Kind() string
 		TokenWord() string
 		String() string
+		Location() token.SrcLoc
 	}
 
 	Statement interface {
 		Node
-		Location() token.SrcLoc
 		Statement()
 	}
 
 	Expression interface {
 		Node
-		Location() token.SrcLoc
 		Expression()
 	}
 )
@@ -28,11 +34,51 @@ type (
 type (
 	Program struct {
 		Statements []Statement
+		// every token of the source, only kept on request
+		Tokens []token.Token
 	}
 
 	BlockStatement struct {
 		Token      token.Token
 		Statements []Statement
+		Label      *Identifier // `name: { ... }`, nil when unlabeled
+	}
+
+	WhileStatement struct {
+		Token     token.Token // 'while' token
+		Label     *Identifier // nil when unlabeled
+		Condition Expression
+		Body      *BlockStatement
+	}
+
+	// `for x in xs { ... }` or `for i, x in xs { ... }`
+	ForInStatement struct {
+		Token    token.Token // 'for' token
+		Label    *Identifier // nil when unlabeled
+		IndexVar *Identifier // nil with a single variable
+		Var      *Identifier
+		Iterable Expression
+		Body     *BlockStatement
+	}
+
+	// `do { ... } while cond;`, the body runs before the first test
+	DoWhileStatement struct {
+		Token     token.Token // 'do' token
+		Label     *Identifier // nil when unlabeled
+		Body      *BlockStatement
+		Condition Expression
+	}
+
+	// `break;` or `break label;`
+	BreakStatement struct {
+		Token token.Token
+		Label *Identifier // nil without a label
+	}
+
+	// `continue;` or `continue label;`
+	ContinueStatement struct {
+		Token token.Token
+		Label *Identifier // nil without a label
 	}
 
 	FunctionStatement struct {
@@ -41,15 +87,50 @@ type (
 		Value *FunctionLiteral
 	}
 
+	// top level functions sharing a name, told apart by arity
+	FunctionGroup struct {
+		Ident    *Identifier
+		Variants []*FunctionStatement
+	}
+
 	LetStatement struct {
-		Token     token.Token
+		Token     token.Token // 'let' or 'const' token
 		Ident     *Identifier
 		InitValue Expression
+		IsConst   bool
 	}
 
 	ReturnStatement struct {
-		Token       token.Token
-		ReturnValue Expression
+		Token        token.Token
+		ReturnValue  Expression   // the value of `return a;`, nil otherwise
+		ReturnValues []Expression // every value, `return a, b;` has two
+	}
+
+	// `assert cond;` or `assert cond, message;`
+	AssertStatement struct {
+		Token     token.Token // 'assert' token
+		Condition Expression
+		Message   Expression // nil without a message
+	}
+
+	// `defer f(x);`, the call runs when the function returns
+	DeferStatement struct {
+		Token token.Token // 'defer' token
+		Call  *CallExpression
+	}
+
+	// `print a, b;` or `println a, b;`, the values may be left out
+	PrintStatement struct {
+		Token   token.Token // 'print' or 'println' token
+		Values  []Expression
+		Newline bool // println ends the line
+	}
+
+	// `import "path";` or `import "path" as alias;`
+	ImportStatement struct {
+		Token token.Token // 'import' token
+		Path  *StringLiteral
+		Alias *Identifier // nil without `as`
 	}
 
 	ExpressionStatement struct {
@@ -59,28 +140,137 @@ type (
 
 	IfStatement struct {
 		Token     token.Token
-		Condition Expression
+		Condition Expression    // nil with a binding
+		Binding   *LetStatement // `if let x = f() { ... }`, nil otherwise
 		Then      *BlockStatement
 		Else      Statement // block or expression statement
 	}
 
+	// a statement preceded by one or more `@name(args)` attributes
+	AttributedStatement struct {
+		Attributes []*Attribute
+		Stmt       Statement
+	}
+
+	Attribute struct {
+		Token token.Token // '@' or '#[' token
+		Name  *Identifier
+		Args  []Expression // nil when written without parentheses
+	}
+
 	PrefixExpression struct {
-		Token    token.Token
-		Operator string
-		Right    Expression
+		Token            token.Token
+		Operator         string
+		OriginalOperator string // the spelling Canonicalize replaced
+		Right            Expression
 	}
 
 	InfixExpression struct {
-		Token    token.Token
+		Token            token.Token
+		Operator         string          // as written, for display
+		OriginalOperator string          // the spelling Canonicalize replaced
+		OpType           token.TokenType // the operator to switch on, `and` is token.AND
+		Left             Expression
+		Right            Expression
+	}
+
+	// an expression choosing between two values, written as the
+	// ternary `cond ? a : b` or as `a unless cond else b` with the
+	// condition negated
+	IfExpression struct {
+		Token     token.Token
+		Condition Expression
+		Then      Expression
+		Else      Expression
+	}
+
+	// `(a, b, c)` evaluates each expression, yielding the last
+	SequenceExpression struct {
+		Token       token.Token // '(' token
+		Expressions []Expression
+	}
+
+	// `(a + b)` as written, only kept when the parser is asked
+	// to preserve the parentheses around expressions
+	GroupExpression struct {
+		Token token.Token // '(' token
+		Inner Expression
+	}
+
+	// `x between a and b`, true when a <= x && x <= b
+	BetweenExpression struct {
+		Token token.Token // 'between' token
+		Value Expression
+		Low   Expression
+		High  Expression
+	}
+
+	// `x is int`, or `x is not int` when Negated
+	TypeTestExpression struct {
+		Token   token.Token // 'is' token
+		Value   Expression
+		Type    *Identifier
+		Negated bool
+	}
+
+	TypeofExpression struct {
+		Token token.Token // 'typeof' token
+		Right Expression
+	}
+	TryExpression struct {
+		Token token.Token // '?' token
+		Value Expression
+	}
+
+	AssignExpression struct {
+		Token    token.Token // '=' or compound assignment token
 		Operator string
-		Left     Expression
-		Right    Expression
+		Target   Expression // identifier, index or member expression
+		Value    Expression
+	}
+
+	IndexExpression struct {
+		Token token.Token // '[' token
+		Left  Expression
+		Index Expression
+	}
+
+	// `object.property`
+	MemberExpression struct {
+		Token    token.Token // '.' token
+		Object   Expression
+		Property *Identifier
+	}
+
+	// `low..high`, or `low..=high` to include high
+	RangeExpression struct {
+		Token     token.Token // '..' or '..=' token
+		Low       Expression
+		High      Expression
+		Inclusive bool
+	}
+
+	DoExpression struct {
+		Token token.Token     // 'do' token
+		Body  *BlockStatement // last statement gives the value
+	}
+
+	// a bare `{ ... }` in expression position, valued like a do block
+	BlockExpression struct {
+		Token token.Token     // '{' token
+		Body  *BlockStatement // last statement gives the value
 	}
 
 	CallExpression struct {
 		Token     token.Token // '(' token
 		Callee    Expression
-		Arguments []Expression
+		Arguments []Expression // positional arguments
+		NamedArgs []*NamedArg  // `name: value` arguments after them
+	}
+
+	NamedArg struct {
+		Name  *Identifier
+		Value Expression
 	}
 
 	Identifier struct {
@@ -90,20 +280,58 @@ type (
 
 	FunctionLiteral struct {
 		Token      token.Token
-		Parameters []*Identifier
+		Parameters []*Param
+		Variadic   bool // last parameter collects remaining arguments
 		Body       *BlockStatement
 	}
 
+	Param struct {
+		Ident   *Identifier
+		Default Expression // nil for required parameters
+	}
+
+	ArrayLiteral struct {
+		Token    token.Token // '[' token
+		Elements []*Element
+	}
+
+	// `[value; count]`, count copies of value
+	ArrayRepeatExpression struct {
+		Token token.Token // '[' token
+		Value Expression
+		Count Expression
+	}
+
+	Element struct {
+		Value  Expression
+		Spread bool // `...xs` spreads the elements of xs in place
+	}
+
 	StringLiteral struct {
 		Token token.Token
 		Value string
 	}
 
+	// a template string, the text in Strings surrounds the
+	// interpolated Values so there is always one string more
+	TemplateLiteral struct {
+		Token   token.Token
+		Strings []string
+		Values  []Expression
+	}
+
 	IntegerLiteral struct {
 		Token token.Token
 		Value int64
 	}
 
+	// an integer literal too large for int64, only made when
+	// the parser is asked to keep such literals as they are
+	BigIntLiteral struct {
+		Token token.Token
+		Value *big.Int
+	}
+
 	FloatLiteral struct {
 		Token token.Token
 		Value float64
@@ -115,6 +343,10 @@ type (
 	}
 )
 
+func (p *Program) Kind() string {
+	return "Program"
+}
+
 func (p *Program) TokenWord() string {
 	if len(p.Statements) > 0 {
 		var out string
@@ -128,45 +360,137 @@ func (p *Program) TokenWord() string {
 }
 
 func (p *Program) String() string {
-	var out string
+	return toString(p)
+}
 
-	for _, s := range p.Statements {
-		out += s.String()
+// a program starts at its first statement, an empty
+// one has no location and gives the zero SrcLoc
+func (p *Program) Location() token.SrcLoc {
+	if len(p.Statements) == 0 {
+		return token.SrcLoc{}
 	}
 
-	return out
+	return p.Statements[0].Location()
 }
 
 func (bs *BlockStatement) Location() token.SrcLoc {
 	return bs.Token.Loc
 }
 
+func (bs *BlockStatement) Kind() string {
+	return "BlockStatement"
+}
+
 func (bs *BlockStatement) TokenWord() string {
 	return bs.Token.Word
 }
 
 func (bs *BlockStatement) String() string {
-	var out string
-	out += "{ "
-	for _, stmt := range bs.Statements {
-		out += stmt.String()
-	}
-	out += " }"
-	return out
+	return toString(bs)
 }
 
 func (bs *BlockStatement) Statement() {}
 
+func (ws *WhileStatement) Kind() string {
+	return "WhileStatement"
+}
+
+func (ws *WhileStatement) TokenWord() string {
+	return ws.Token.Word
+}
+
+func (ws *WhileStatement) String() string {
+	return toString(ws)
+}
+
+func (ws *WhileStatement) Location() token.SrcLoc {
+	return ws.Token.Loc
+}
+
+func (ws *WhileStatement) Statement() {}
+
+func (fs *ForInStatement) Kind() string {
+	return "ForInStatement"
+}
+
+func (fs *ForInStatement) TokenWord() string {
+	return fs.Token.Word
+}
+
+func (fs *ForInStatement) String() string {
+	return toString(fs)
+}
+
+func (fs *ForInStatement) Location() token.SrcLoc {
+	return fs.Token.Loc
+}
+
+func (fs *ForInStatement) Statement() {}
+
+func (dw *DoWhileStatement) Kind() string {
+	return "DoWhileStatement"
+}
+
+func (dw *DoWhileStatement) TokenWord() string {
+	return dw.Token.Word
+}
+
+func (dw *DoWhileStatement) String() string {
+	return toString(dw)
+}
+
+func (dw *DoWhileStatement) Location() token.SrcLoc {
+	return dw.Token.Loc
+}
+
+func (dw *DoWhileStatement) Statement() {}
+
+func (bs *BreakStatement) Kind() string {
+	return "BreakStatement"
+}
+
+func (bs *BreakStatement) TokenWord() string {
+	return bs.Token.Word
+}
+
+func (bs *BreakStatement) String() string {
+	return toString(bs)
+}
+
+func (bs *BreakStatement) Location() token.SrcLoc {
+	return bs.Token.Loc
+}
+
+func (bs *BreakStatement) Statement() {}
+
+func (cs *ContinueStatement) Kind() string {
+	return "ContinueStatement"
+}
+
+func (cs *ContinueStatement) TokenWord() string {
+	return cs.Token.Word
+}
+
+func (cs *ContinueStatement) String() string {
+	return toString(cs)
+}
+
+func (cs *ContinueStatement) Location() token.SrcLoc {
+	return cs.Token.Loc
+}
+
+func (cs *ContinueStatement) Statement() {}
+
+func (ls *LetStatement) Kind() string {
+	return "LetStatement"
+}
+
 func (ls *LetStatement) TokenWord() string {
 	return ls.Token.Word
 }
 
 func (ls *LetStatement) String() string {
-	if ls.InitValue != nil {
-		return fmt.Sprintf("let %s = %s;", ls.Ident.Value, ls.InitValue)
-	}
-
-	return fmt.Sprintf("let %s", ls.Ident.Value)
+	return toString(ls)
 }
 
 func (ls *LetStatement) Location() token.SrcLoc {
@@ -175,21 +499,16 @@ func (ls *LetStatement) Location() token.SrcLoc {
 
 func (ls *LetStatement) Statement() {}
 
+func (fs *FunctionStatement) Kind() string {
+	return "FunctionStatement"
+}
+
 func (fs *FunctionStatement) TokenWord() string {
 	return fs.Token.Word
 }
 
 func (fs *FunctionStatement) String() string {
-	var params string
-	for i, param := range fs.Value.Parameters {
-		if i == 0 {
-			params += param.String()
-		} else {
-			params += ", " + param.String()
-		}
-	}
-
-	return fmt.Sprintf("fn %s(%s) %s", fs.Ident, params, fs.Value.Body)
+	return toString(fs)
 }
 
 func (fs *FunctionStatement) Location() token.SrcLoc {
@@ -198,16 +517,34 @@ func (fs *FunctionStatement) Location() token.SrcLoc {
 
 func (fs *FunctionStatement) Statement() {}
 
+func (fg *FunctionGroup) Kind() string {
+	return "FunctionGroup"
+}
+
+func (fg *FunctionGroup) TokenWord() string {
+	return fg.Variants[0].TokenWord()
+}
+
+func (fg *FunctionGroup) String() string {
+	return toString(fg)
+}
+
+func (fg *FunctionGroup) Location() token.SrcLoc {
+	return fg.Variants[0].Location()
+}
+
+func (fg *FunctionGroup) Statement() {}
+
+func (rs *ReturnStatement) Kind() string {
+	return "ReturnStatement"
+}
+
 func (rs *ReturnStatement) TokenWord() string {
 	return rs.Token.Word
 }
 
 func (rs *ReturnStatement) String() string {
-	if rs.ReturnValue != nil {
-		return fmt.Sprintf("return %s;", rs.ReturnValue)
-	}
-
-	return "return;"
+	return toString(rs)
 }
 
 func (rs *ReturnStatement) Location() token.SrcLoc {
@@ -216,16 +553,88 @@ func (rs *ReturnStatement) Location() token.SrcLoc {
 
 func (rs *ReturnStatement) Statement() {}
 
+func (as *AssertStatement) Kind() string {
+	return "AssertStatement"
+}
+
+func (as *AssertStatement) TokenWord() string {
+	return as.Token.Word
+}
+
+func (as *AssertStatement) String() string {
+	return toString(as)
+}
+
+func (as *AssertStatement) Location() token.SrcLoc {
+	return as.Token.Loc
+}
+
+func (as *AssertStatement) Statement() {}
+
+func (ds *DeferStatement) Kind() string {
+	return "DeferStatement"
+}
+
+func (ds *DeferStatement) TokenWord() string {
+	return ds.Token.Word
+}
+
+func (ds *DeferStatement) String() string {
+	return toString(ds)
+}
+
+func (ds *DeferStatement) Location() token.SrcLoc {
+	return ds.Token.Loc
+}
+
+func (ds *DeferStatement) Statement() {}
+
+func (ps *PrintStatement) Kind() string {
+	return "PrintStatement"
+}
+
+func (ps *PrintStatement) TokenWord() string {
+	return ps.Token.Word
+}
+
+func (ps *PrintStatement) String() string {
+	return toString(ps)
+}
+
+func (ps *PrintStatement) Location() token.SrcLoc {
+	return ps.Token.Loc
+}
+
+func (ps *PrintStatement) Statement() {}
+
+func (is *ImportStatement) Kind() string {
+	return "ImportStatement"
+}
+
+func (is *ImportStatement) TokenWord() string {
+	return is.Token.Word
+}
+
+func (is *ImportStatement) String() string {
+	return toString(is)
+}
+
+func (is *ImportStatement) Location() token.SrcLoc {
+	return is.Token.Loc
+}
+
+func (is *ImportStatement) Statement() {}
+
+func (es *ExpressionStatement) Kind() string {
+	return "ExpressionStatement"
+}
+
 func (es *ExpressionStatement) TokenWord() string {
 	return es.Token.Word
 }
 
 func (es *ExpressionStatement) String() string {
-	if es.Expression != nil {
-		return es.Expression.String()
-	}
-
-	return ""
+	return toString(es)
 }
 
 func (es *ExpressionStatement) Location() token.SrcLoc {
@@ -234,19 +643,38 @@ func (es *ExpressionStatement) Location() token.SrcLoc {
 
 func (es *ExpressionStatement) Statement() {}
 
+func (as *AttributedStatement) Kind() string {
+	return "AttributedStatement"
+}
+
+func (as *AttributedStatement) TokenWord() string {
+	return as.Attributes[0].Token.Word
+}
+
+func (as *AttributedStatement) String() string {
+	return toString(as)
+}
+
+func (as *AttributedStatement) Location() token.SrcLoc {
+	return as.Attributes[0].Token.Loc
+}
+
+func (as *AttributedStatement) Statement() {}
+
+func (at *Attribute) String() string {
+	return build(func(e *emitter) { e.attribute(at) })
+}
+
+func (is *IfStatement) Kind() string {
+	return "IfStatement"
+}
+
 func (is *IfStatement) TokenWord() string {
 	return is.Token.Word
 }
 
 func (is *IfStatement) String() string {
-	var out string
-	out += fmt.Sprintf("if %s", is.Then)
-
-	if is.Else != nil {
-		out += fmt.Sprintf("else %s", is.Else)
-	}
-
-	return out
+	return toString(is)
 }
 
 func (is *IfStatement) Location() token.SrcLoc {
@@ -255,12 +683,16 @@ func (is *IfStatement) Location() token.SrcLoc {
 
 func (is *IfStatement) Statement() {}
 
+func (ie *InfixExpression) Kind() string {
+	return "InfixExpression"
+}
+
 func (ie *InfixExpression) TokenWord() string {
 	return ie.Token.Word
 }
 
 func (ie *InfixExpression) String() string {
-	return fmt.Sprintf("(%s %s %s)", ie.Left, ie.Operator, ie.Right)
+	return toString(ie)
 }
 
 func (ie *InfixExpression) Location() token.SrcLoc {
@@ -269,12 +701,142 @@ func (ie *InfixExpression) Location() token.SrcLoc {
 
 func (ie *InfixExpression) Expression() {}
 
+func (ie *IfExpression) Kind() string {
+	return "IfExpression"
+}
+
+func (ie *IfExpression) TokenWord() string {
+	return ie.Token.Word
+}
+
+func (ie *IfExpression) String() string {
+	return toString(ie)
+}
+
+func (ie *IfExpression) Location() token.SrcLoc {
+	return ie.Token.Loc
+}
+
+func (ie *IfExpression) Expression() {}
+
+func (se *SequenceExpression) Kind() string {
+	return "SequenceExpression"
+}
+
+func (se *SequenceExpression) TokenWord() string {
+	return se.Token.Word
+}
+
+func (se *SequenceExpression) String() string {
+	return toString(se)
+}
+
+func (se *SequenceExpression) Location() token.SrcLoc {
+	return se.Token.Loc
+}
+
+func (se *SequenceExpression) Expression() {}
+
+func (ge *GroupExpression) Kind() string {
+	return "GroupExpression"
+}
+
+func (ge *GroupExpression) TokenWord() string {
+	return ge.Token.Word
+}
+
+func (ge *GroupExpression) String() string {
+	return toString(ge)
+}
+
+func (ge *GroupExpression) Location() token.SrcLoc {
+	return ge.Token.Loc
+}
+
+func (ge *GroupExpression) Expression() {}
+
+func (be *BetweenExpression) Kind() string {
+	return "BetweenExpression"
+}
+
+func (be *BetweenExpression) TokenWord() string {
+	return be.Token.Word
+}
+
+func (be *BetweenExpression) String() string {
+	return toString(be)
+}
+
+func (be *BetweenExpression) Location() token.SrcLoc {
+	return be.Token.Loc
+}
+
+func (be *BetweenExpression) Expression() {}
+
+func (te *TypeTestExpression) Kind() string {
+	return "TypeTestExpression"
+}
+
+func (te *TypeTestExpression) TokenWord() string {
+	return te.Token.Word
+}
+
+func (te *TypeTestExpression) String() string {
+	return toString(te)
+}
+
+func (te *TypeTestExpression) Location() token.SrcLoc {
+	return te.Token.Loc
+}
+
+func (te *TypeTestExpression) Expression() {}
+
+func (te *TypeofExpression) Kind() string {
+	return "TypeofExpression"
+}
+
+func (te *TypeofExpression) TokenWord() string {
+	return te.Token.Word
+}
+
+func (te *TypeofExpression) String() string {
+	return toString(te)
+}
+
+func (te *TypeofExpression) Location() token.SrcLoc {
+	return te.Token.Loc
+}
+
+func (te *TypeofExpression) Expression() {}
+
+func (te *TryExpression) Kind() string {
+	return "TryExpression"
+}
+
+func (te *TryExpression) TokenWord() string {
+	return te.Token.Word
+}
+
+func (te *TryExpression) String() string {
+	return toString(te)
+}
+
+func (te *TryExpression) Location() token.SrcLoc {
+	return te.Token.Loc
+}
+
+func (te *TryExpression) Expression() {}
+
+func (pe *PrefixExpression) Kind() string {
+	return "PrefixExpression"
+}
+
 func (pe *PrefixExpression) TokenWord() string {
 	return pe.Token.Word
 }
 
 func (pe *PrefixExpression) String() string {
-	return fmt.Sprintf("(%s%s)", pe.Operator, pe.Right)
+	return toString(pe)
 }
 
 func (pe *PrefixExpression) Location() token.SrcLoc {
@@ -283,6 +845,118 @@ func (pe *PrefixExpression) Location() token.SrcLoc {
 
 func (pe *PrefixExpression) Expression() {}
 
+func (ae *AssignExpression) Kind() string {
+	return "AssignExpression"
+}
+
+func (ae *AssignExpression) TokenWord() string {
+	return ae.Token.Word
+}
+
+func (ae *AssignExpression) String() string {
+	return toString(ae)
+}
+
+func (ae *AssignExpression) Location() token.SrcLoc {
+	return ae.Token.Loc
+}
+
+func (ae *AssignExpression) Expression() {}
+
+func (ie *IndexExpression) Kind() string {
+	return "IndexExpression"
+}
+
+func (ie *IndexExpression) TokenWord() string {
+	return ie.Token.Word
+}
+
+func (ie *IndexExpression) String() string {
+	return toString(ie)
+}
+
+func (ie *IndexExpression) Location() token.SrcLoc {
+	return ie.Token.Loc
+}
+
+func (ie *IndexExpression) Expression() {}
+
+func (re *RangeExpression) Kind() string {
+	return "RangeExpression"
+}
+
+func (re *RangeExpression) TokenWord() string {
+	return re.Token.Word
+}
+
+func (re *RangeExpression) String() string {
+	return toString(re)
+}
+
+func (re *RangeExpression) Location() token.SrcLoc {
+	return re.Token.Loc
+}
+
+func (re *RangeExpression) Expression() {}
+
+func (me *MemberExpression) Kind() string {
+	return "MemberExpression"
+}
+
+func (me *MemberExpression) TokenWord() string {
+	return me.Token.Word
+}
+
+func (me *MemberExpression) String() string {
+	return toString(me)
+}
+
+func (me *MemberExpression) Location() token.SrcLoc {
+	return me.Token.Loc
+}
+
+func (me *MemberExpression) Expression() {}
+
+func (de *DoExpression) Kind() string {
+	return "DoExpression"
+}
+
+func (de *DoExpression) TokenWord() string {
+	return de.Token.Word
+}
+
+func (de *DoExpression) String() string {
+	return toString(de)
+}
+
+func (de *DoExpression) Location() token.SrcLoc {
+	return de.Token.Loc
+}
+
+func (de *DoExpression) Expression() {}
+
+func (be *BlockExpression) Kind() string {
+	return "BlockExpression"
+}
+
+func (be *BlockExpression) TokenWord() string {
+	return be.Token.Word
+}
+
+func (be *BlockExpression) String() string {
+	return toString(be)
+}
+
+func (be *BlockExpression) Location() token.SrcLoc {
+	return be.Token.Loc
+}
+
+func (be *BlockExpression) Expression() {}
+
+func (id *Identifier) Kind() string {
+	return "Identifier"
+}
+
 func (id *Identifier) TokenWord() string {
 	return id.Token.Word
 }
@@ -297,21 +971,16 @@ func (id *Identifier) Location() token.SrcLoc {
 
 func (id *Identifier) Expression() {}
 
+func (ce *CallExpression) Kind() string {
+	return "CallExpression"
+}
+
 func (ce *CallExpression) TokenWord() string {
 	return ce.Token.Word
 }
 
 func (ce *CallExpression) String() string {
-	var args string
-	for i, arg := range ce.Arguments {
-		if i == 0 {
-			args += arg.String()
-		} else {
-			args += ", " + arg.String()
-		}
-	}
-
-	return fmt.Sprintf("%s(%s)", ce.Callee, args)
+	return toString(ce)
 }
 
 func (ce *CallExpression) Location() token.SrcLoc {
@@ -320,20 +989,24 @@ func (ce *CallExpression) Location() token.SrcLoc {
 
 func (ce *CallExpression) Expression() {}
 
+func (fl *FunctionLiteral) Kind() string {
+	return "FunctionLiteral"
+}
+
 func (fl *FunctionLiteral) TokenWord() string {
 	return fl.Token.Word
 }
 
 func (fl *FunctionLiteral) String() string {
-	var params string
-	for i, param := range fl.Parameters {
-		if i == 0 {
-			params += param.String()
-		} else {
-			params += ", " + param.String()
-		}
-	}
-	return fmt.Sprintf("fn (%s) %s", params, fl.Body)
+	return toString(fl)
+}
+
+func (na *NamedArg) String() string {
+	return build(func(e *emitter) { e.namedArg(na) })
+}
+
+func (pm *Param) String() string {
+	return build(func(e *emitter) { e.param(pm) })
 }
 
 func (fl *FunctionLiteral) Location() token.SrcLoc {
@@ -342,6 +1015,66 @@ func (fl *FunctionLiteral) Location() token.SrcLoc {
 
 func (fl *FunctionLiteral) Expression() {}
 
+// the fewest and most arguments the function takes,
+// most is -1 when a variadic parameter takes any number
+func (fl *FunctionLiteral) Arity() (least, most int) {
+	for _, param := range fl.Parameters {
+		if param.Default == nil {
+			least++
+		}
+	}
+
+	if fl.Variadic {
+		// the variadic parameter may be given no arguments
+		return least - 1, -1
+	}
+	return least, len(fl.Parameters)
+}
+
+func (al *ArrayLiteral) Kind() string {
+	return "ArrayLiteral"
+}
+
+func (al *ArrayLiteral) TokenWord() string {
+	return al.Token.Word
+}
+
+func (al *ArrayLiteral) String() string {
+	return toString(al)
+}
+
+func (el *Element) String() string {
+	return build(func(e *emitter) { e.element(el) })
+}
+
+func (al *ArrayLiteral) Location() token.SrcLoc {
+	return al.Token.Loc
+}
+
+func (al *ArrayLiteral) Expression() {}
+
+func (ar *ArrayRepeatExpression) Kind() string {
+	return "ArrayRepeatExpression"
+}
+
+func (ar *ArrayRepeatExpression) TokenWord() string {
+	return ar.Token.Word
+}
+
+func (ar *ArrayRepeatExpression) String() string {
+	return toString(ar)
+}
+
+func (ar *ArrayRepeatExpression) Location() token.SrcLoc {
+	return ar.Token.Loc
+}
+
+func (ar *ArrayRepeatExpression) Expression() {}
+
+func (il *IntegerLiteral) Kind() string {
+	return "IntegerLiteral"
+}
+
 func (il *IntegerLiteral) TokenWord() string {
 	return il.Token.Word
 }
@@ -356,12 +1089,49 @@ func (il *IntegerLiteral) Location() token.SrcLoc {
 
 func (il *IntegerLiteral) Expression() {}
 
+func (bl *BigIntLiteral) Kind() string {
+	return "BigIntLiteral"
+}
+
+func (bl *BigIntLiteral) TokenWord() string {
+	return bl.Token.Word
+}
+
+func (bl *BigIntLiteral) String() string {
+	return bl.TokenWord()
+}
+
+func (bl *BigIntLiteral) Location() token.SrcLoc {
+	return bl.Token.Loc
+}
+
+func (bl *BigIntLiteral) Expression() {}
+
+func (fl *FloatLiteral) Kind() string {
+	return "FloatLiteral"
+}
+
 func (fl *FloatLiteral) TokenWord() string {
 	return fl.Token.Word
 }
 
+// Formats the value rather than the source word so folded floats
+// print too. Values print in plain decimal with at least one digit
+// after the point (2.0, -0.5), keeping the sign of negative zero as
+// -0.0, and switch to exponent notation (1e+21, 1e-07) outside of
+// 1e-6 <= |value| < 1e21.
 func (fl *FloatLiteral) String() string {
-	return fl.TokenWord()
+	v := fl.Value
+	if abs := math.Abs(v); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		return strconv.FormatFloat(v, 'e', -1, 64)
+	}
+
+	str := strconv.FormatFloat(v, 'f', -1, 64)
+	if !strings.Contains(str, ".") {
+		str += ".0"
+	}
+
+	return str
 }
 
 func (fl *FloatLiteral) Location() token.SrcLoc {
@@ -370,12 +1140,64 @@ func (fl *FloatLiteral) Location() token.SrcLoc {
 
 func (fl *FloatLiteral) Expression() {}
 
+func (sl *StringLiteral) Kind() string {
+	return "StringLiteral"
+}
+
 func (sl *StringLiteral) TokenWord() string {
 	return sl.Token.Word
 }
 
 func (sl *StringLiteral) String() string {
-	return `"` + sl.TokenWord() + `"`
+	return quote(sl.Value)
+}
+
+// writes s as a string literal that reads back as s, escaping
+// the quotes, backslashes and characters that do not print
+func quote(s string) string {
+	var out strings.Builder
+	out.WriteByte('"')
+
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			out.WriteByte('\\')
+			out.WriteRune(r)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\t':
+			out.WriteString(`\t`)
+		case '\r':
+			out.WriteString(`\r`)
+		default:
+			if unicode.IsPrint(r) {
+				out.WriteRune(r)
+			} else {
+				out.WriteString(`\u{` + strconv.FormatInt(int64(r), 16) + `}`)
+			}
+		}
+	}
+
+	out.WriteByte('"')
+	return out.String()
+}
+
+func (tl *TemplateLiteral) Expression() {}
+
+func (tl *TemplateLiteral) Kind() string {
+	return "TemplateLiteral"
+}
+
+func (tl *TemplateLiteral) TokenWord() string {
+	return tl.Token.Word
+}
+
+func (tl *TemplateLiteral) String() string {
+	return toString(tl)
+}
+
+func (tl *TemplateLiteral) Location() token.SrcLoc {
+	return tl.Token.Loc
 }
 
 func (sl *StringLiteral) Location() token.SrcLoc {
@@ -384,6 +1206,10 @@ func (sl *StringLiteral) Location() token.SrcLoc {
 
 func (sl *StringLiteral) Expression() {}
 
+func (bl *BoolLiteral) Kind() string {
+	return "BoolLiteral"
+}
+
 func (bl *BoolLiteral) TokenWord() string {
 	return bl.Token.Word
 }
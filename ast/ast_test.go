@@ -0,0 +1,108 @@
+package ast_test
+
+import (
+	"RoLang/ast"
+	"RoLang/lexer"
+	"RoLang/parser"
+
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestNodeKind(t *testing.T) {
+	tests := []struct {
+		node   ast.Node
+		expect string
+	}{
+		{&ast.Program{}, "Program"},
+		{&ast.BlockStatement{}, "BlockStatement"},
+		{&ast.FunctionStatement{}, "FunctionStatement"},
+		{&ast.FunctionGroup{}, "FunctionGroup"},
+		{&ast.LetStatement{}, "LetStatement"},
+		{&ast.ReturnStatement{}, "ReturnStatement"},
+		{&ast.AssertStatement{}, "AssertStatement"},
+		{&ast.DeferStatement{}, "DeferStatement"},
+		{&ast.PrintStatement{}, "PrintStatement"},
+		{&ast.ImportStatement{}, "ImportStatement"},
+		{&ast.ExpressionStatement{}, "ExpressionStatement"},
+		{&ast.IfStatement{}, "IfStatement"},
+		{&ast.AttributedStatement{}, "AttributedStatement"},
+		{&ast.IfExpression{}, "IfExpression"},
+		{&ast.PrefixExpression{}, "PrefixExpression"},
+		{&ast.InfixExpression{}, "InfixExpression"},
+		{&ast.SequenceExpression{}, "SequenceExpression"},
+		{&ast.GroupExpression{}, "GroupExpression"},
+		{&ast.BetweenExpression{}, "BetweenExpression"},
+		{&ast.TypeTestExpression{}, "TypeTestExpression"},
+		{&ast.TypeofExpression{}, "TypeofExpression"},
+		{&ast.AssignExpression{}, "AssignExpression"},
+		{&ast.IndexExpression{}, "IndexExpression"},
+		{&ast.MemberExpression{}, "MemberExpression"},
+		{&ast.RangeExpression{}, "RangeExpression"},
+		{&ast.DoExpression{}, "DoExpression"},
+		{&ast.BlockExpression{}, "BlockExpression"},
+		{&ast.CallExpression{}, "CallExpression"},
+		{&ast.Identifier{}, "Identifier"},
+		{&ast.FunctionLiteral{}, "FunctionLiteral"},
+		{&ast.ArrayLiteral{}, "ArrayLiteral"},
+		{&ast.ArrayRepeatExpression{}, "ArrayRepeatExpression"},
+		{&ast.StringLiteral{}, "StringLiteral"},
+		{&ast.TemplateLiteral{}, "TemplateLiteral"},
+		{&ast.IntegerLiteral{}, "IntegerLiteral"},
+		{&ast.FloatLiteral{}, "FloatLiteral"},
+		{&ast.BoolLiteral{}, "BoolLiteral"},
+		{&ast.WhileStatement{}, "WhileStatement"},
+		{&ast.ForInStatement{}, "ForInStatement"},
+		{&ast.DoWhileStatement{}, "DoWhileStatement"},
+		{&ast.BreakStatement{}, "BreakStatement"},
+		{&ast.ContinueStatement{}, "ContinueStatement"},
+	}
+
+	for _, test := range tests {
+		if kind := test.node.Kind(); kind != test.expect {
+			t.Errorf("%T reports the wrong kind. expect=%q, got=%q", test.node, test.expect, kind)
+		}
+	}
+
+	// parsed nodes report their own kind, not the interface they are held in
+	program := parser.New(lexer.New("ast_test_kind", "let x = -1;")).Parse()
+	let := program.Statements[0].(*ast.LetStatement)
+	if kinds := []string{program.Kind(), let.Kind(), let.InitValue.Kind()}; !reflect.DeepEqual(kinds, []string{"Program", "LetStatement", "PrefixExpression"}) {
+		t.Errorf("wrong kinds. got=%v", kinds)
+	}
+}
+
+func TestFloatLiteralString(t *testing.T) {
+	tests := []struct {
+		value  float64
+		expect string
+	}{
+		{math.Copysign(0, -1), "-0.0"},
+		{0, "0.0"},
+		{2, "2.0"},
+		{-2.5, "-2.5"},
+		{10.23, "10.23"},
+		{1e20, "100000000000000000000.0"},
+		{1e21, "1e+21"},
+		{0.000001, "0.000001"},
+		{-0.0000001, "-1e-07"},
+	}
+
+	for _, test := range tests {
+		fl := &ast.FloatLiteral{Value: test.value}
+		if found := fl.String(); found != test.expect {
+			t.Errorf("wrong format for %v. expect=%q, got=%q", test.value, test.expect, found)
+		}
+	}
+}
+
+func checkErrors(t *testing.T, p *parser.Parser) {
+	if errors := p.Errors(); len(errors) != 0 {
+		t.Errorf("parser has %d errors", len(errors))
+		for _, message := range errors {
+			t.Errorf("parser error: %s", message)
+		}
+		t.FailNow()
+	}
+}
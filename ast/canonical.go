@@ -0,0 +1,29 @@
+package ast
+
+import "RoLang/token"
+
+// Rewrites the operators in the tree to a single spelling per
+// operator, so `a and b` gets the operator `&&`. The spelling it
+// had before is kept in OriginalOperator.
+func Canonicalize(node Node) {
+	Inspect(node, func(n Node) bool {
+		switch e := n.(type) {
+		case *InfixExpression:
+			e.OriginalOperator = original(e.OriginalOperator, e.Operator)
+			e.Operator = token.TokenString[e.Token.Type]
+		case *PrefixExpression:
+			e.OriginalOperator = original(e.OriginalOperator, e.Operator)
+			e.Operator = token.TokenString[e.Token.Type]
+		}
+		return true
+	})
+}
+
+// a tree canonicalized twice keeps the spelling of the source
+func original(kept, operator string) string {
+	if kept != "" {
+		return kept
+	}
+
+	return operator
+}
@@ -0,0 +1,67 @@
+package ast_test
+
+import (
+	"RoLang/ast"
+	"RoLang/lexer"
+	"RoLang/parser"
+
+	"testing"
+)
+
+func TestCanonicalize(t *testing.T) {
+	l := lexer.New("ast_test_canonical", "a and b or not c;")
+	p := parser.New(l)
+
+	program := p.Parse()
+	checkErrors(t, p)
+
+	ast.Canonicalize(program)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+
+	or, ok := stmt.Expression.(*ast.InfixExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression not *ast.InfixExpression. got=%T", stmt.Expression)
+	}
+
+	and, ok := or.Left.(*ast.InfixExpression)
+	if !ok {
+		t.Fatalf("or.Left not *ast.InfixExpression. got=%T", or.Left)
+	}
+
+	not, ok := or.Right.(*ast.PrefixExpression)
+	if !ok {
+		t.Fatalf("or.Right not *ast.PrefixExpression. got=%T", or.Right)
+	}
+
+	tests := []struct {
+		operator       string
+		original       string
+		expectOperator string
+		expectOriginal string
+	}{
+		{and.Operator, and.OriginalOperator, "&&", "and"},
+		{or.Operator, or.OriginalOperator, "||", "or"},
+		{not.Operator, not.OriginalOperator, "!", "not"},
+	}
+
+	for _, test := range tests {
+		if test.operator != test.expectOperator {
+			t.Errorf("operator not canonical. expect=%q, got=%q", test.expectOperator, test.operator)
+		}
+
+		if test.original != test.expectOriginal {
+			t.Errorf("original operator not kept. expect=%q, got=%q", test.expectOriginal, test.original)
+		}
+	}
+
+	// canonicalizing again keeps the spelling of the source
+	ast.Canonicalize(program)
+	if and.OriginalOperator != "and" {
+		t.Errorf("original operator lost. got=%q", and.OriginalOperator)
+	}
+
+	if str := program.String(); str != "((a && b) || (!c))" {
+		t.Errorf("program.String() wrong. got=%q", str)
+	}
+}
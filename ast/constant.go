@@ -0,0 +1,343 @@
+package ast
+
+import (
+	"RoLang/token"
+
+	"math"
+)
+
+// Folds integer literals and the arithmetic on them into a
+// value, reporting false for anything else
+func ConstantInt(expr Expression) (int64, bool) {
+	switch e := expr.(type) {
+	case *IntegerLiteral:
+		return e.Value, true
+	case *GroupExpression:
+		return ConstantInt(e.Inner)
+	case *PrefixExpression:
+		right, ok := ConstantInt(e.Right)
+		if !ok {
+			return 0, false
+		}
+
+		switch e.Token.Type {
+		case token.MINUS:
+			return -right, true
+		case token.PLUS:
+			return right, true
+		}
+	case *InfixExpression:
+		left, lok := ConstantInt(e.Left)
+		right, rok := ConstantInt(e.Right)
+		if !lok || !rok {
+			return 0, false
+		}
+
+		switch e.Token.Type {
+		case token.PLUS:
+			return left + right, true
+		case token.MINUS:
+			return left - right, true
+		case token.STAR:
+			return left * right, true
+		case token.SLASH:
+			if right == 0 {
+				return 0, false
+			}
+			return left / right, true
+		}
+	}
+
+	return 0, false
+}
+
+// Folds boolean literals, the logical operators on them and
+// comparisons of constant integers into a value, reporting
+// false for anything else
+func ConstantBool(expr Expression) (bool, bool) {
+	switch e := expr.(type) {
+	case *BoolLiteral:
+		return e.Value, true
+	case *GroupExpression:
+		return ConstantBool(e.Inner)
+	case *PrefixExpression:
+		right, ok := ConstantBool(e.Right)
+		if !ok || e.Token.Type != token.BANG {
+			return false, false
+		}
+		return !right, true
+	case *InfixExpression:
+		if e.Token.Type == token.AND || e.Token.Type == token.OR {
+			left, lok := ConstantBool(e.Left)
+			right, rok := ConstantBool(e.Right)
+			if !lok || !rok {
+				return false, false
+			}
+
+			if e.Token.Type == token.AND {
+				return left && right, true
+			}
+			return left || right, true
+		}
+
+		left, lok := ConstantInt(e.Left)
+		right, rok := ConstantInt(e.Right)
+		if !lok || !rok {
+			return false, false
+		}
+
+		switch e.Token.Type {
+		case token.EQ:
+			return left == right, true
+		case token.NE:
+			return left != right, true
+		case token.LT:
+			return left < right, true
+		case token.LE:
+			return left <= right, true
+		case token.GT:
+			return left > right, true
+		case token.GE:
+			return left >= right, true
+		}
+	}
+
+	return false, false
+}
+
+// Reports whether expr folds to a value without running
+// anything, see EvalConst
+func IsConstExpr(expr Expression) bool {
+	_, ok := EvalConst(expr)
+	return ok
+}
+
+// Folds literals and the operators on them into the value the
+// evaluator would compute, an int64, float64, string or bool.
+// Reports false for identifiers, calls and anything else that
+// needs a runtime, and for operations that would fail at runtime
+// as well as divisions by zero.
+func EvalConst(expr Expression) (any, bool) {
+	switch e := expr.(type) {
+	case *IntegerLiteral:
+		return e.Value, true
+	case *FloatLiteral:
+		return e.Value, true
+	case *StringLiteral:
+		return e.Value, true
+	case *BoolLiteral:
+		return e.Value, true
+	case *GroupExpression:
+		return EvalConst(e.Inner)
+	case *PrefixExpression:
+		right, ok := EvalConst(e.Right)
+		if !ok {
+			return nil, false
+		}
+		return constPrefix(e.Token.Type, right)
+	case *InfixExpression:
+		left, lok := EvalConst(e.Left)
+		right, rok := EvalConst(e.Right)
+		if !lok || !rok {
+			return nil, false
+		}
+		return constInfix(e.Token.Type, left, right)
+	}
+
+	return nil, false
+}
+
+func constPrefix(op token.TokenType, right any) (any, bool) {
+	switch op {
+	case token.BANG:
+		return !constTruthy(right), true
+	case token.TILDE:
+		if r, ok := right.(int64); ok {
+			return ^r, true
+		}
+	case token.MINUS:
+		switch r := right.(type) {
+		case int64:
+			return -r, true
+		case float64:
+			return -r, true
+		}
+	case token.PLUS:
+		switch right.(type) {
+		case int64, float64:
+			return right, true
+		}
+	}
+
+	return nil, false
+}
+
+func constInfix(op token.TokenType, left, right any) (any, bool) {
+	switch op {
+	case token.AND:
+		return constTruthy(left) && constTruthy(right), true
+	case token.OR:
+		return constTruthy(left) || constTruthy(right), true
+	case token.EQ, token.NE:
+		equal, ok := constEqual(left, right)
+		return equal == (op == token.EQ), ok
+	}
+
+	// strings only concatenate with strings here, numbers would
+	// need the formatting of the `str` builtin
+	if l, ok := left.(string); ok {
+		if r, ok := right.(string); ok && op == token.PLUS {
+			return l + r, true
+		}
+		return nil, false
+	}
+
+	l, lok := left.(int64)
+	r, rok := right.(int64)
+	if lok && rok {
+		return constIntInfix(op, l, r)
+	}
+
+	lf, lok := constFloat(left)
+	rf, rok := constFloat(right)
+	if !lok || !rok {
+		return nil, false
+	}
+
+	switch op {
+	case token.PLUS:
+		return lf + rf, true
+	case token.MINUS:
+		return lf - rf, true
+	case token.STAR:
+		return lf * rf, true
+	case token.SLASH:
+		if rf == 0 {
+			return nil, false
+		}
+		return lf / rf, true
+	case token.POW:
+		return math.Pow(lf, rf), true
+	case token.LT:
+		return lf < rf, true
+	case token.LE:
+		return lf <= rf, true
+	case token.GT:
+		return lf > rf, true
+	case token.GE:
+		return lf >= rf, true
+	case token.SPACESHIP:
+		return constCompare(lf, rf), true
+	}
+
+	return nil, false
+}
+
+func constIntInfix(op token.TokenType, l, r int64) (any, bool) {
+	switch op {
+	case token.PLUS:
+		return l + r, true
+	case token.MINUS:
+		return l - r, true
+	case token.STAR:
+		return l * r, true
+	case token.SLASH:
+		if r == 0 {
+			return nil, false
+		}
+		return l / r, true
+	case token.POW:
+		// negative exponents give floats
+		if r < 0 {
+			return math.Pow(float64(l), float64(r)), true
+		}
+		result := int64(1)
+		for ; r > 0; r >>= 1 {
+			if r&1 == 1 {
+				result *= l
+			}
+			l *= l
+		}
+		return result, true
+	case token.AMP:
+		return l & r, true
+	case token.PIPE:
+		return l | r, true
+	case token.SHL, token.SHR:
+		if r < 0 {
+			return nil, false
+		}
+		if op == token.SHL {
+			return l << r, true
+		}
+		return l >> r, true
+	case token.LT:
+		return l < r, true
+	case token.LE:
+		return l <= r, true
+	case token.GT:
+		return l > r, true
+	case token.GE:
+		return l >= r, true
+	case token.SPACESHIP:
+		return constCompare(l, r), true
+	}
+
+	return nil, false
+}
+
+// the result of `l <=> r`, values that are neither less
+// nor equal are greater as they are to the evaluator
+func constCompare[T int64 | float64](l, r T) int64 {
+	switch {
+	case l < r:
+		return -1
+	case l == r:
+		return 0
+	default:
+		return 1
+	}
+}
+
+func constFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+
+	return 0, false
+}
+
+// numbers compare by value and bools with bools, strings
+// are left alone as the evaluator does not compare them yet
+func constEqual(left, right any) (bool, bool) {
+	if _, ok := left.(string); ok {
+		return false, false
+	}
+
+	l, lok := constFloat(left)
+	r, rok := constFloat(right)
+	if lok && rok {
+		return l == r, true
+	}
+
+	return left == right, true
+}
+
+// the values the evaluator treats as false
+func constTruthy(value any) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case int64:
+		return v != 0
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	}
+
+	return true
+}
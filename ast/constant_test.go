@@ -0,0 +1,57 @@
+package ast_test
+
+import (
+	"RoLang/ast"
+	"RoLang/lexer"
+	"RoLang/parser"
+
+	"testing"
+)
+
+func TestEvalConst(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect any
+		ok     bool
+	}{
+		{"2 + 3 * 4", int64(14), true},
+		{"(1 + 2.5) * 2", 7.0, true},
+		{"-(2 ** 10) / +4", int64(-256), true},
+		{"2 ** -1", 0.5, true},
+		{"1 << 4 | 3 & ~0", int64(19), true},
+		{`"ab" + "c"`, "abc", true},
+		{"1 < 2 and not (3 == 3.0)", false, true},
+		{"0 or 2 >= 2", true, true},
+		{"1 <=> 2", int64(-1), true},
+		{"2.5 <=> 2", int64(1), true},
+		{"3 <=> 3.0", int64(0), true},
+		// partially constant
+		{"2 + x * 4", nil, false},
+		{"1 + f()", nil, false},
+		{"-y", nil, false},
+		// the evaluator would fail on these
+		{"1 / 0", nil, false},
+		{"1.5 / (2 - 2)", nil, false},
+		{"1 << -1", nil, false},
+		{`"a" + 1`, nil, false},
+		{"true + 1", nil, false},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("ast_test_const", test.input)
+		p := parser.NewWithOptions(l, parser.Options{REPL: true})
+
+		program := p.Parse()
+		checkErrors(t, p)
+
+		expr := program.Statements[0].(*ast.ExpressionStatement).Expression
+		value, ok := ast.EvalConst(expr)
+		if ok != test.ok || value != test.expect {
+			t.Errorf("EvalConst(%s) wrong. expect=%v (%t), got=%v (%t)", test.input, test.expect, test.ok, value, ok)
+		}
+
+		if ast.IsConstExpr(expr) != test.ok {
+			t.Errorf("IsConstExpr(%s) wrong. expect=%t", test.input, test.ok)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package ast
+
+import (
+	"RoLang/token"
+
+	"fmt"
+)
+
+// a problem found in a tree, located at the node it is about
+type Diagnostic struct {
+	Loc     token.SrcLoc
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s %s", d.Loc, d.Message)
+}
+
+// Reports the branches of ifs and loops that can never be taken because
+// their condition is constant, like the then block of `if false {}`
+func DeadBranches(node Node) []Diagnostic {
+	diagnostics := []Diagnostic{}
+
+	Inspect(node, func(n Node) bool {
+		var condition Expression
+		var then, elze Node
+
+		switch n := n.(type) {
+		case *IfStatement:
+			condition, then, elze = n.Condition, n.Then, n.Else
+		case *IfExpression:
+			condition, then, elze = n.Condition, n.Then, n.Else
+		case *WhileStatement:
+			if value, ok := ConstantBool(n.Condition); ok && !value {
+				diagnostics = append(diagnostics, Diagnostic{
+					Loc:     n.Body.Location(),
+					Message: "loop body never runs, the condition is always false",
+				})
+			}
+			return true
+		default:
+			return true
+		}
+
+		value, ok := ConstantBool(condition)
+		switch {
+		case !ok:
+		case value && !isNil(elze):
+			diagnostics = append(diagnostics, Diagnostic{
+				Loc:     elze.Location(),
+				Message: "else branch is never taken, the condition is always true",
+			})
+		case !value:
+			diagnostics = append(diagnostics, Diagnostic{
+				Loc:     then.Location(),
+				Message: "then branch is never taken, the condition is always false",
+			})
+		}
+
+		return true
+	})
+
+	return diagnostics
+}
+
+// Reports the statements that follow a return, break or continue
+// in the same block, once per block at the first of them
+func CheckUnreachable(program *Program) []Diagnostic {
+	diagnostics := []Diagnostic{}
+
+	check := func(stmts []Statement) {
+		for i := 0; i+1 < len(stmts); i++ {
+			switch stmts[i].(type) {
+			case *ReturnStatement, *BreakStatement, *ContinueStatement:
+				diagnostics = append(diagnostics, Diagnostic{
+					Loc:     stmts[i+1].Location(),
+					Message: fmt.Sprintf("unreachable code after %s", stmts[i].TokenWord()),
+				})
+				return
+			}
+		}
+	}
+
+	Inspect(program, func(n Node) bool {
+		switch n := n.(type) {
+		case *Program:
+			check(n.Statements)
+		case *BlockStatement:
+			check(n.Statements)
+		}
+		return true
+	})
+
+	return diagnostics
+}
@@ -0,0 +1,87 @@
+package ast_test
+
+import (
+	"RoLang/ast"
+	"RoLang/lexer"
+	"RoLang/parser"
+
+	"testing"
+)
+
+func TestDeadBranches(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect []string
+	}{
+		{"if false { a; }", []string{"ast_test_dead:1:10: then branch is never taken, the condition is always false"}},
+		{"if true { a; } else { b; }", []string{"ast_test_dead:1:21: else branch is never taken, the condition is always true"}},
+		{"if !(1 < 2) { a; } else if 2 * 2 == 4 { b; } else { c; }", []string{
+			"ast_test_dead:1:13: then branch is never taken, the condition is always false",
+			"ast_test_dead:1:51: else branch is never taken, the condition is always true",
+		}},
+		{"let x = a unless false or true else b;", []string{"ast_test_dead:1:9: then branch is never taken, the condition is always false"}},
+		{"while 1 > 2 { a; }", []string{"ast_test_dead:1:13: loop body never runs, the condition is always false"}},
+		{"if true { a; }", []string{}},
+		{"if x { a; } else { b; }", []string{}},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("ast_test_dead", test.input)
+		p := parser.New(l)
+
+		program := p.Parse()
+		checkErrors(t, p)
+
+		diagnostics := ast.DeadBranches(program)
+		if len(diagnostics) != len(test.expect) {
+			t.Errorf("wrong number of diagnostics for %q. expect=%d, got=%v",
+				test.input, len(test.expect), diagnostics)
+			continue
+		}
+
+		for i, expect := range test.expect {
+			if found := diagnostics[i].String(); found != expect {
+				t.Errorf("wrong diagnostic for %q. expect=%q, got=%q", test.input, expect, found)
+			}
+		}
+	}
+}
+
+func TestCheckUnreachable(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect []string
+	}{
+		{"fn f() {\n\treturn 1;\n\tlet x = 2;\n\tx;\n}", []string{"ast_test_unreachable:3:2: unreachable code after return"}},
+		{"while x { break; a; } for y in ys { continue; b; }", []string{
+			"ast_test_unreachable:1:18: unreachable code after break",
+			"ast_test_unreachable:1:47: unreachable code after continue",
+		}},
+		// a return inside the if leaves the rest reachable
+		{"fn f(x) { if x { return 1; } return 2; }", []string{}},
+		{"fn f(x) { if x { return 1; } else { return 2; } }", []string{}},
+		{"fn f() { return; }", []string{}},
+		{"fn f() { fn g() { return; } g(); }", []string{}},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("ast_test_unreachable", test.input)
+		p := parser.New(l)
+
+		program := p.Parse()
+		checkErrors(t, p)
+
+		diagnostics := ast.CheckUnreachable(program)
+		if len(diagnostics) != len(test.expect) {
+			t.Errorf("wrong number of diagnostics for %q. expect=%d, got=%v",
+				test.input, len(test.expect), diagnostics)
+			continue
+		}
+
+		for i, expect := range test.expect {
+			if found := diagnostics[i].String(); found != expect {
+				t.Errorf("wrong diagnostic for %q. expect=%q, got=%q", test.input, expect, found)
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package ast
+
+import (
+	"RoLang/token"
+
+	"fmt"
+	"math/big"
+	"reflect"
+)
+
+var (
+	tokenType  = reflect.TypeOf(token.Token{})
+	srcLocType = reflect.TypeOf(token.SrcLoc{})
+	bigIntType = reflect.TypeOf(&big.Int{})
+)
+
+// Reports whether a and b are the same tree, comparing the node
+// types and every field but where in the source they were found
+func Equal(a, b Node) bool {
+	return Diff(a, b) == ""
+}
+
+// Describes the first difference between a and b with the path
+// to it, like `Statements[0].Expression.Right: "2" != "3"`,
+// or returns "" when they are Equal
+func Diff(a, b Node) string {
+	return diffValue("", reflect.ValueOf(&a).Elem(), reflect.ValueOf(&b).Elem())
+}
+
+func diffValue(path string, a, b reflect.Value) string {
+	switch a.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if a.IsNil() || b.IsNil() {
+			if a.IsNil() != b.IsNil() {
+				return fmt.Sprintf("%s: %s != %s", where(path), describe(a), describe(b))
+			}
+			return ""
+		}
+
+		if a.Kind() == reflect.Interface && a.Elem().Type() != b.Elem().Type() {
+			return fmt.Sprintf("%s: %s != %s", where(path), describe(a), describe(b))
+		}
+		if a.Type() == bigIntType {
+			if a.Interface().(*big.Int).Cmp(b.Interface().(*big.Int)) != 0 {
+				return fmt.Sprintf("%s: %v != %v", where(path), a, b)
+			}
+			return ""
+		}
+		return diffValue(path, a.Elem(), b.Elem())
+	case reflect.Struct:
+		switch a.Type() {
+		case srcLocType:
+			return ""
+		case tokenType:
+			// the spelling matters, the location does not
+			at, bt := a.Interface().(token.Token), b.Interface().(token.Token)
+			if at.Type != bt.Type || at.Word != bt.Word {
+				return fmt.Sprintf("%s: %q != %q", where(path), at.Word, bt.Word)
+			}
+			return ""
+		}
+
+		for i := 0; i < a.NumField(); i++ {
+			field := join(path, a.Type().Field(i).Name)
+			if diff := diffValue(field, a.Field(i), b.Field(i)); diff != "" {
+				return diff
+			}
+		}
+		return ""
+	case reflect.Slice:
+		if a.Len() != b.Len() {
+			return fmt.Sprintf("%s: %d elements != %d elements", where(path), a.Len(), b.Len())
+		}
+
+		for i := 0; i < a.Len(); i++ {
+			if diff := diffValue(fmt.Sprintf("%s[%d]", path, i), a.Index(i), b.Index(i)); diff != "" {
+				return diff
+			}
+		}
+		return ""
+	default:
+		if !a.Equal(b) {
+			return fmt.Sprintf("%s: %v != %v", where(path), a, b)
+		}
+		return ""
+	}
+}
+
+func join(path, field string) string {
+	if path == "" {
+		return field
+	}
+
+	return path + "." + field
+}
+
+// the root has no path of its own
+func where(path string) string {
+	if path == "" {
+		return "root"
+	}
+
+	return path
+}
+
+// a node as its type and source text, nil as nil
+func describe(v reflect.Value) string {
+	if v.IsNil() {
+		return "nil"
+	}
+
+	if node, ok := v.Interface().(Node); ok {
+		return fmt.Sprintf("%s %s", node.Kind(), node)
+	}
+
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,57 @@
+package ast_test
+
+import (
+	"RoLang/ast"
+	"RoLang/lexer"
+	"RoLang/parser"
+
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	parse := func(input string) *ast.Program {
+		p := parser.New(lexer.New("ast_test_equal", input))
+		program := p.Parse()
+		checkErrors(t, p)
+
+		return program
+	}
+
+	// the same program laid out differently
+	a := parse("fn f(x) { return x * 2; }\nlet y = f(1) + 2;")
+	b := parse("fn f(x) {\n\treturn x * 2;\n}\n\nlet y =\n\tf(1) + 2;")
+
+	if !ast.Equal(a, b) {
+		t.Errorf("equal programs differ: %s", ast.Diff(a, b))
+	}
+	if diff := ast.Diff(a, b); diff != "" {
+		t.Errorf("Diff of equal programs not empty. got=%q", diff)
+	}
+
+	tests := []struct {
+		input  string
+		expect string
+	}{
+		{"fn f(x) { return x * 2; }\nlet y = f(1) + 3;", `Statements[1].InitValue.Right.Token: "2" != "3"`},
+		{"fn f(x) { return x * 2; }\nlet z = f(1) + 2;", `Statements[1].Ident.Token: "y" != "z"`},
+		{"fn f(x) { return x * 2; }\nlet y = f(1) - 2;", `Statements[1].InitValue.Token: "+" != "-"`},
+		{"fn f(x) { return x * 2; }\nlet y = f(1, 2) + 2;", "Statements[1].InitValue.Left.Arguments: 1 elements != 2 elements"},
+		{"fn f(x) { return x * 2; }\nlet y = f(1) + y;", "Statements[1].InitValue.Right: IntegerLiteral 2 != Identifier y"},
+		{"fn f(x) { return; }\nlet y = f(1) + 2;", "Statements[0].Value.Body.Statements[0].ReturnValue: InfixExpression (x * 2) != nil"},
+	}
+
+	for _, test := range tests {
+		c := parse(test.input)
+
+		if ast.Equal(a, c) {
+			t.Errorf("%q: different programs compare equal", test.input)
+		}
+		if diff := ast.Diff(a, c); diff != test.expect {
+			t.Errorf("%q: wrong Diff. expect=%q, got=%q", test.input, test.expect, diff)
+		}
+	}
+
+	if ast.Equal(a, nil) || !ast.Equal(nil, nil) {
+		t.Errorf("wrong comparison with nil")
+	}
+}
@@ -0,0 +1,191 @@
+package ast
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// every node type by name, used to rebuild nodes from JSON,
+// new nodes need to be added here as well as in Inspect
+var nodeTypes = map[string]reflect.Type{}
+
+func init() {
+	for _, node := range []Node{
+		&Program{}, &BlockStatement{}, &FunctionStatement{}, &FunctionGroup{},
+		&LetStatement{}, &ReturnStatement{}, &AssertStatement{}, &DeferStatement{}, &PrintStatement{},
+		&ImportStatement{}, &ExpressionStatement{},
+		&IfStatement{}, &AttributedStatement{}, &IfExpression{}, &PrefixExpression{}, &InfixExpression{},
+		&SequenceExpression{}, &GroupExpression{}, &BetweenExpression{}, &TypeTestExpression{}, &TypeofExpression{}, &TryExpression{}, &AssignExpression{},
+		&IndexExpression{}, &MemberExpression{}, &RangeExpression{}, &DoExpression{}, &BlockExpression{}, &CallExpression{},
+		&Identifier{}, &FunctionLiteral{}, &ArrayLiteral{}, &ArrayRepeatExpression{},
+		&StringLiteral{}, &TemplateLiteral{}, &IntegerLiteral{}, &BigIntLiteral{}, &FloatLiteral{},
+		&BoolLiteral{}, &WhileStatement{}, &ForInStatement{}, &DoWhileStatement{}, &BreakStatement{},
+		&ContinueStatement{},
+	} {
+		nodeTypes[node.Kind()] = reflect.TypeOf(node).Elem()
+	}
+}
+
+var (
+	nodeInterface        = reflect.TypeOf((*Node)(nil)).Elem()
+	unmarshalerInterface = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// Serializes the tree rooted at node. Every node becomes an object
+// holding its type name under "node", its location under "loc" and
+// its fields by name, with child nodes nested in the same way.
+func ToJSON(node Node) ([]byte, error) {
+	return json.Marshal(encodeValue(reflect.ValueOf(node)))
+}
+
+// Rebuilds a tree serialized by ToJSON
+func FromJSON(data []byte) (Node, error) {
+	v, err := decodeNode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if !v.IsValid() {
+		return nil, nil
+	}
+
+	return v.Interface().(Node), nil
+}
+
+func encodeValue(v reflect.Value) any {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Kind() == reflect.Interface {
+			return encodeValue(v.Elem())
+		}
+
+		// values like big integers marshal themselves
+		if _, ok := v.Interface().(json.Marshaler); ok && !v.Type().Implements(nodeInterface) {
+			return v.Interface()
+		}
+
+		fields := map[string]any{}
+		if node, ok := v.Interface().(Node); ok {
+			fields["node"] = node.Kind()
+			fields["loc"] = node.Location()
+		}
+
+		elem := v.Elem()
+		for i := 0; i < elem.NumField(); i++ {
+			fields[elem.Type().Field(i).Name] = encodeValue(elem.Field(i))
+		}
+		return fields
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+
+		list := make([]any, v.Len())
+		for i := range list {
+			list[i] = encodeValue(v.Index(i))
+		}
+		return list
+	default:
+		// tokens and plain values marshal as they are
+		return v.Interface()
+	}
+}
+
+// decodes an object written for a node into a pointer to a new node,
+// null gives the zero Value
+func decodeNode(data []byte) (reflect.Value, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return reflect.Value{}, err
+	}
+
+	if fields == nil {
+		return reflect.Value{}, nil
+	}
+
+	var name string
+	if err := json.Unmarshal(fields["node"], &name); err != nil {
+		return reflect.Value{}, fmt.Errorf("node without a type name: %s", err)
+	}
+
+	t, ok := nodeTypes[name]
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("unknown node type %q", name)
+	}
+
+	v := reflect.New(t)
+	if err := decodeFields(fields, v.Elem()); err != nil {
+		return reflect.Value{}, fmt.Errorf("%s: %s", name, err)
+	}
+
+	return v, nil
+}
+
+func decodeFields(fields map[string]json.RawMessage, v reflect.Value) error {
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+
+		if data, ok := fields[name]; ok {
+			if err := decodeValue(data, v.Field(i)); err != nil {
+				return fmt.Errorf("field %s: %s", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func decodeValue(data []byte, v reflect.Value) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	t := v.Type()
+	switch {
+	case t.Kind() == reflect.Interface || t.Kind() == reflect.Pointer && t.Implements(nodeInterface):
+		node, err := decodeNode(data)
+		if err != nil {
+			return err
+		}
+		if !node.Type().AssignableTo(t) {
+			return fmt.Errorf("%s cannot be used as %s", node.Type().Elem().Name(), t)
+		}
+		v.Set(node)
+	case t.Kind() == reflect.Pointer && t.Implements(unmarshalerInterface):
+		return json.Unmarshal(data, v.Addr().Interface())
+	case t.Kind() == reflect.Pointer:
+		// parts of nodes like parameters are plain objects
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return err
+		}
+
+		part := reflect.New(t.Elem())
+		if err := decodeFields(fields, part.Elem()); err != nil {
+			return err
+		}
+		v.Set(part)
+	case t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.String:
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return err
+		}
+
+		list := reflect.MakeSlice(t, len(items), len(items))
+		for i, item := range items {
+			if err := decodeValue(item, list.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(list)
+	default:
+		// tokens and plain values unmarshal as they are
+		return json.Unmarshal(data, v.Addr().Interface())
+	}
+
+	return nil
+}
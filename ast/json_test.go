@@ -0,0 +1,56 @@
+package ast_test
+
+import (
+	"RoLang/ast"
+	"RoLang/lexer"
+	"RoLang/parser"
+	"RoLang/token"
+
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	input := `
+import "math" as m;
+@cfg(A) const scale = -0.0;
+fn area(w, h = 2, ...rest) {
+	if w is not int or w between 0 and 1 {
+		return [w; 3];
+	} else {
+		xs[0] += (w, h);
+	}
+	return do { let a = w * h; a };
+}
+area(1, [1, ...xs], 99999999999999999999);
+` + "`x ${area(2)}`;"
+
+	l := lexer.New("ast_test_json", input)
+	p := parser.NewWithOptions(l, parser.Options{Defines: map[string]bool{"A": true}, IntOverflow: parser.OverflowToBigInt})
+
+	program := p.Parse()
+	checkErrors(t, p)
+
+	data, err := ast.ToJSON(program)
+	if err != nil {
+		t.Fatalf("ToJSON returned an error: %s", err)
+	}
+
+	node, err := ast.FromJSON(data)
+	if err != nil {
+		t.Fatalf("FromJSON returned an error: %s", err)
+	}
+
+	if found, expect := node.String(), program.String(); found != expect {
+		t.Errorf("round trip changed the program.\nexpect=%q\ngot=   %q", expect, found)
+	}
+	if diff := ast.Diff(program, node); diff != "" {
+		t.Errorf("round trip changed the tree. %s", diff)
+	}
+
+	// locations survive the round trip
+	fn := node.(*ast.Program).Statements[2].(*ast.FunctionStatement)
+	expectLoc := token.SrcLoc{File: "ast_test_json", Line: 4, Col: 4}
+	if loc := fn.Ident.Location(); loc != expectLoc {
+		t.Errorf("wrong location after round trip. expect=%s, got=%s", expectLoc, loc)
+	}
+}
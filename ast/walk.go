@@ -0,0 +1,166 @@
+package ast
+
+import "reflect"
+
+// Traverses the tree rooted at node in depth first order. f is called
+// for every node and its children are visited only when f returns true.
+func Inspect(node Node, f func(Node) bool) {
+	if isNil(node) || !f(node) {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		for _, stmt := range n.Statements {
+			Inspect(stmt, f)
+		}
+	case *BlockStatement:
+		Inspect(n.Label, f)
+		for _, stmt := range n.Statements {
+			Inspect(stmt, f)
+		}
+	case *WhileStatement:
+		Inspect(n.Label, f)
+		Inspect(n.Condition, f)
+		Inspect(n.Body, f)
+	case *ForInStatement:
+		Inspect(n.Label, f)
+		Inspect(n.IndexVar, f)
+		Inspect(n.Var, f)
+		Inspect(n.Iterable, f)
+		Inspect(n.Body, f)
+	case *DoWhileStatement:
+		Inspect(n.Label, f)
+		Inspect(n.Body, f)
+		Inspect(n.Condition, f)
+	case *BreakStatement:
+		Inspect(n.Label, f)
+	case *ContinueStatement:
+		Inspect(n.Label, f)
+	case *FunctionStatement:
+		Inspect(n.Ident, f)
+		Inspect(n.Value, f)
+	case *FunctionGroup:
+		Inspect(n.Ident, f)
+		for _, variant := range n.Variants {
+			Inspect(variant, f)
+		}
+	case *LetStatement:
+		Inspect(n.Ident, f)
+		Inspect(n.InitValue, f)
+	case *ReturnStatement:
+		if len(n.ReturnValues) > 1 {
+			for _, value := range n.ReturnValues {
+				Inspect(value, f)
+			}
+		} else {
+			Inspect(n.ReturnValue, f)
+		}
+	case *AssertStatement:
+		Inspect(n.Condition, f)
+		Inspect(n.Message, f)
+	case *DeferStatement:
+		Inspect(n.Call, f)
+	case *PrintStatement:
+		for _, value := range n.Values {
+			Inspect(value, f)
+		}
+	case *ImportStatement:
+		Inspect(n.Path, f)
+		Inspect(n.Alias, f)
+	case *ExpressionStatement:
+		Inspect(n.Expression, f)
+	case *AttributedStatement:
+		for _, attr := range n.Attributes {
+			Inspect(attr.Name, f)
+			for _, arg := range attr.Args {
+				Inspect(arg, f)
+			}
+		}
+		Inspect(n.Stmt, f)
+	case *IfStatement:
+		Inspect(n.Binding, f)
+		Inspect(n.Condition, f)
+		Inspect(n.Then, f)
+		Inspect(n.Else, f)
+	case *PrefixExpression:
+		Inspect(n.Right, f)
+	case *InfixExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Right, f)
+	case *IfExpression:
+		Inspect(n.Condition, f)
+		Inspect(n.Then, f)
+		Inspect(n.Else, f)
+	case *SequenceExpression:
+		for _, expr := range n.Expressions {
+			Inspect(expr, f)
+		}
+	case *GroupExpression:
+		Inspect(n.Inner, f)
+	case *BetweenExpression:
+		Inspect(n.Value, f)
+		Inspect(n.Low, f)
+		Inspect(n.High, f)
+	case *TypeTestExpression:
+		Inspect(n.Value, f)
+		Inspect(n.Type, f)
+	case *TypeofExpression:
+		Inspect(n.Right, f)
+	case *TryExpression:
+		Inspect(n.Value, f)
+	case *AssignExpression:
+		Inspect(n.Target, f)
+		Inspect(n.Value, f)
+	case *IndexExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Index, f)
+	case *RangeExpression:
+		Inspect(n.Low, f)
+		Inspect(n.High, f)
+	case *MemberExpression:
+		Inspect(n.Object, f)
+		Inspect(n.Property, f)
+	case *ArrayLiteral:
+		for _, elem := range n.Elements {
+			Inspect(elem.Value, f)
+		}
+	case *ArrayRepeatExpression:
+		Inspect(n.Value, f)
+		Inspect(n.Count, f)
+	case *TemplateLiteral:
+		for _, value := range n.Values {
+			Inspect(value, f)
+		}
+	case *DoExpression:
+		Inspect(n.Body, f)
+	case *BlockExpression:
+		Inspect(n.Body, f)
+	case *CallExpression:
+		Inspect(n.Callee, f)
+		for _, arg := range n.Arguments {
+			Inspect(arg, f)
+		}
+		for _, arg := range n.NamedArgs {
+			Inspect(arg.Name, f)
+			Inspect(arg.Value, f)
+		}
+	case *FunctionLiteral:
+		for _, param := range n.Parameters {
+			Inspect(param.Ident, f)
+			Inspect(param.Default, f)
+		}
+		Inspect(n.Body, f)
+	}
+}
+
+// optional children are stored as nil pointers, which
+// are not equal to nil once wrapped in an interface
+func isNil(node Node) bool {
+	if node == nil {
+		return true
+	}
+
+	v := reflect.ValueOf(node)
+	return v.Kind() == reflect.Pointer && v.IsNil()
+}
@@ -0,0 +1,424 @@
+package ast
+
+import (
+	"RoLang/token"
+
+	"io"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// Writes the text String gives for node to w, streaming the children
+// instead of building their strings first. Returns the number of
+// bytes written and the first error of w, which stops the output.
+func WriteTo(w io.Writer, node Node) (int, error) {
+	e := &emitter{w: w}
+	e.node(node)
+
+	return e.n, e.err
+}
+
+type emitter struct {
+	w   io.Writer
+	n   int
+	err error
+}
+
+// the String of every node but the literals is built by the emitter
+func toString(node Node) string {
+	var out strings.Builder
+	WriteTo(&out, node)
+
+	return out.String()
+}
+
+// same for the parts of nodes that are not nodes themselves
+func build(emit func(e *emitter)) string {
+	var out strings.Builder
+	emit(&emitter{w: &out})
+
+	return out.String()
+}
+
+func (e *emitter) str(s string) {
+	if e.err != nil {
+		return
+	}
+
+	n, err := io.WriteString(e.w, s)
+	e.n += n
+	e.err = err
+}
+
+func (e *emitter) node(node Node) {
+	// missing children print as fmt would print them
+	if node == nil {
+		e.str("%!s(<nil>)")
+		return
+	}
+	if v := reflect.ValueOf(node); v.Kind() == reflect.Pointer && v.IsNil() {
+		e.str("<nil>")
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		for _, stmt := range n.Statements {
+			e.node(stmt)
+		}
+	case *BlockStatement:
+		e.label(n.Label)
+		e.str("{ ")
+		for _, stmt := range n.Statements {
+			e.node(stmt)
+		}
+		e.str(" }")
+	case *WhileStatement:
+		e.label(n.Label)
+		e.str("while ")
+		e.node(n.Condition)
+		e.str(" ")
+		e.node(n.Body)
+	case *ForInStatement:
+		e.label(n.Label)
+		e.str("for ")
+		if n.IndexVar != nil {
+			e.node(n.IndexVar)
+			e.str(", ")
+		}
+		e.node(n.Var)
+		e.str(" in ")
+		e.node(n.Iterable)
+		e.str(" ")
+		e.node(n.Body)
+	case *DoWhileStatement:
+		e.label(n.Label)
+		e.str("do ")
+		e.node(n.Body)
+		e.str(" while ")
+		e.node(n.Condition)
+		e.str(";")
+	case *BreakStatement:
+		e.jump("break", n.Label)
+	case *ContinueStatement:
+		e.jump("continue", n.Label)
+	case *LetStatement:
+		if n.IsConst {
+			e.str("const ")
+		} else {
+			e.str("let ")
+		}
+		e.str(n.Ident.Value)
+		if n.InitValue != nil {
+			e.str(" = ")
+			e.node(n.InitValue)
+		}
+		e.str(";")
+	case *FunctionStatement:
+		e.str("fn ")
+		e.node(n.Ident)
+		e.str("(")
+		e.params(n.Value)
+		e.str(") ")
+		e.node(n.Value.Body)
+	case *FunctionGroup:
+		for _, variant := range n.Variants {
+			e.node(variant)
+		}
+	case *ReturnStatement:
+		e.str("return")
+		if len(n.ReturnValues) > 1 {
+			e.str(" ")
+			e.list(n.ReturnValues)
+		} else if n.ReturnValue != nil {
+			e.str(" ")
+			e.node(n.ReturnValue)
+		}
+		e.str(";")
+	case *AssertStatement:
+		e.str("assert ")
+		e.node(n.Condition)
+		if n.Message != nil {
+			e.str(", ")
+			e.node(n.Message)
+		}
+		e.str(";")
+	case *DeferStatement:
+		e.str("defer ")
+		e.node(n.Call)
+		e.str(";")
+	case *PrintStatement:
+		e.str(n.Token.Word)
+		if len(n.Values) > 0 {
+			e.str(" ")
+			e.list(n.Values)
+		}
+		e.str(";")
+	case *ImportStatement:
+		e.str("import ")
+		e.node(n.Path)
+		if n.Alias != nil {
+			e.str(" as ")
+			e.node(n.Alias)
+		}
+		e.str(";")
+	case *ExpressionStatement:
+		if n.Expression != nil {
+			e.node(n.Expression)
+		}
+	case *AttributedStatement:
+		for _, attr := range n.Attributes {
+			e.attribute(attr)
+			e.str(" ")
+		}
+		e.node(n.Stmt)
+	case *IfStatement:
+		e.str("if ")
+		if n.Binding != nil {
+			// the binding is written without the ';' of a let
+			e.str("let ")
+			e.str(n.Binding.Ident.Value)
+			e.str(" = ")
+			e.node(n.Binding.InitValue)
+		} else {
+			e.node(n.Condition)
+		}
+		e.str(" ")
+		e.node(n.Then)
+		if n.Else != nil {
+			e.str("else ")
+			e.node(n.Else)
+		}
+	case *InfixExpression:
+		e.str("(")
+		e.node(n.Left)
+		e.str(" ")
+		e.str(n.Operator)
+		e.str(" ")
+		e.node(n.Right)
+		e.str(")")
+	case *IfExpression:
+		e.str("(if ")
+		e.node(n.Condition)
+		e.str(" then ")
+		e.node(n.Then)
+		e.str(" else ")
+		e.node(n.Else)
+		e.str(")")
+	case *SequenceExpression:
+		e.str("(")
+		e.list(n.Expressions)
+		e.str(")")
+	case *GroupExpression:
+		e.str("(")
+		e.node(n.Inner)
+		e.str(")")
+	case *BetweenExpression:
+		e.str("(")
+		e.node(n.Value)
+		e.str(" between ")
+		e.node(n.Low)
+		e.str(" and ")
+		e.node(n.High)
+		e.str(")")
+	case *TypeTestExpression:
+		e.str("(")
+		e.node(n.Value)
+		if n.Negated {
+			e.str(" is not ")
+		} else {
+			e.str(" is ")
+		}
+		e.node(n.Type)
+		e.str(")")
+	case *TypeofExpression:
+		e.str("(typeof ")
+		e.node(n.Right)
+		e.str(")")
+	case *TryExpression:
+		e.str("(")
+		e.node(n.Value)
+		e.str("?)")
+	case *PrefixExpression:
+		e.str("(")
+		e.str(n.Operator)
+		// word operators like `not` need a space before the operand
+		if op := n.Operator; op != "" && unicode.IsLetter(rune(op[0])) {
+			e.str(" ")
+		}
+		e.node(n.Right)
+		e.str(")")
+	case *AssignExpression:
+		e.str("(")
+		e.node(n.Target)
+		e.str(" ")
+		e.str(n.Operator)
+		e.str(" ")
+		e.node(n.Value)
+		e.str(")")
+	case *IndexExpression:
+		e.str("(")
+		e.node(n.Left)
+		e.str("[")
+		e.node(n.Index)
+		e.str("])")
+	case *RangeExpression:
+		e.str("(")
+		e.node(n.Low)
+		if n.Inclusive {
+			e.str(" ..= ")
+		} else {
+			e.str(" .. ")
+		}
+		e.node(n.High)
+		e.str(")")
+	case *MemberExpression:
+		e.str("(")
+		e.node(n.Object)
+		e.str(".")
+		e.node(n.Property)
+		e.str(")")
+	case *DoExpression:
+		e.str("do ")
+		e.node(n.Body)
+	case *BlockExpression:
+		e.node(n.Body)
+	case *CallExpression:
+		e.node(n.Callee)
+		e.str("(")
+		e.list(n.Arguments)
+		for i, arg := range n.NamedArgs {
+			if i != 0 || len(n.Arguments) != 0 {
+				e.str(", ")
+			}
+			e.namedArg(arg)
+		}
+		e.str(")")
+	case *FunctionLiteral:
+		e.str("fn (")
+		e.params(n)
+		e.str(") ")
+		e.node(n.Body)
+	case *ArrayLiteral:
+		e.str("[")
+		for i, elem := range n.Elements {
+			if i != 0 {
+				e.str(", ")
+			}
+			e.element(elem)
+		}
+		e.str("]")
+	case *ArrayRepeatExpression:
+		e.str("[")
+		e.node(n.Value)
+		e.str("; ")
+		e.node(n.Count)
+		e.str("]")
+	case *TemplateLiteral:
+		e.str("`")
+		for i, value := range n.Values {
+			e.str(n.Strings[i])
+			e.str("${")
+			e.node(value)
+			e.str("}")
+		}
+		e.str(n.Strings[len(n.Strings)-1])
+		e.str("`")
+	default:
+		// identifiers and literals are written as they are
+		e.str(node.String())
+	}
+}
+
+func (e *emitter) label(label *Identifier) {
+	if label != nil {
+		e.node(label)
+		e.str(": ")
+	}
+}
+
+func (e *emitter) jump(keyword string, label *Identifier) {
+	e.str(keyword)
+	if label != nil {
+		e.str(" ")
+		e.node(label)
+	}
+	e.str(";")
+}
+
+func (e *emitter) list(exprs []Expression) {
+	for i, expr := range exprs {
+		if i != 0 {
+			e.str(", ")
+		}
+		e.node(expr)
+	}
+}
+
+func (e *emitter) params(fn *FunctionLiteral) {
+	for i, param := range fn.Parameters {
+		if i != 0 {
+			e.str(", ")
+		}
+		if fn.Variadic && i == len(fn.Parameters)-1 {
+			e.str("...")
+		}
+		e.param(param)
+	}
+}
+
+func (e *emitter) param(param *Param) {
+	e.node(param.Ident)
+	if param.Default != nil {
+		e.str(" = ")
+		e.node(param.Default)
+	}
+}
+
+func (e *emitter) namedArg(arg *NamedArg) {
+	e.node(arg.Name)
+	e.str(": ")
+	e.node(arg.Value)
+}
+
+func (e *emitter) element(elem *Element) {
+	if elem.Spread {
+		e.str("...")
+	}
+	e.node(elem.Value)
+}
+
+func (e *emitter) attribute(attr *Attribute) {
+	if attr.Token.Type == token.HASH {
+		e.str("#[")
+	} else {
+		e.str("@")
+	}
+
+	e.node(attr.Name)
+	if attr.Args != nil {
+		e.str("(")
+		e.list(attr.Args)
+		e.str(")")
+	}
+
+	if attr.Token.Type == token.HASH {
+		e.str("]")
+	}
+}
+
+// Writes the source of program back to w from the tokens it keeps,
+// as it was written when they were read with their trivia. Returns
+// the number of bytes written and the first error of w.
+func WriteSource(w io.Writer, program *Program) (int, error) {
+	e := &emitter{w: w}
+	for _, tok := range program.Tokens {
+		for _, trivia := range tok.Trivia {
+			e.str(trivia)
+		}
+		e.str(tok.Source)
+	}
+
+	return e.n, e.err
+}
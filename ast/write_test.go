@@ -0,0 +1,171 @@
+package ast_test
+
+import (
+	"RoLang/ast"
+	"RoLang/lexer"
+	"RoLang/parser"
+	"RoLang/token"
+
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	input := `
+import "math" as m;
+@cfg(A) #[inline] const scale = -0.0;
+fn area(w, h = 2, ...rest) {
+	outer: while w > 0 {
+		if w is not int or w between 0 and 1 { break outer; } else { continue; }
+	}
+	do { w -= 1; } while not w.done;
+	xs[0] += (w, h);
+	return do { let a = w * h; a } unless rest else [w; 3];
+}
+let r = area(1, [1, ...xs]) then |x| -x;
+` + "`x ${area(2)}`;"
+
+	l := lexer.New("ast_test_write", input)
+	p := parser.NewWithOptions(l, parser.Options{Defines: map[string]bool{"A": true}})
+
+	program := p.Parse()
+	checkErrors(t, p)
+
+	// as String printed it before it was built on WriteTo
+	expect := `import "math" as m;@cfg(A) #[inline] const scale = (-0.0);` +
+		`fn area(w, h = 2, ...rest) { outer: while (w > 0) { if ((w is not int) or (w between 0 and 1)) { break outer; }else { continue; } }` +
+		`do { (w -= 1) } while (not (w.done));((xs[0]) += (w, h))` +
+		`return (if (not rest) then do { let a = (w * h);a } else [w; 3]); }` +
+		`let r = fn (x) { return (-x); }(area(1, [1, ...xs]));` + "`x ${area(2)}`"
+
+	var out strings.Builder
+	n, err := ast.WriteTo(&out, program)
+	if err != nil {
+		t.Fatalf("WriteTo returned an error: %s", err)
+	}
+
+	if found := out.String(); found != expect {
+		t.Errorf("WriteTo wrote the wrong text.\nexpect=%q\ngot=   %q", expect, found)
+	}
+
+	if n != len(expect) {
+		t.Errorf("WriteTo returned the wrong count. expect=%d, got=%d", len(expect), n)
+	}
+
+	if found := program.String(); found != expect {
+		t.Errorf("program.String() wrong.\nexpect=%q\ngot=   %q", expect, found)
+	}
+}
+
+type failingWriter struct {
+	room int
+}
+
+func (w *failingWriter) Write(b []byte) (int, error) {
+	if len(b) > w.room {
+		n := w.room
+		w.room = 0
+		return n, errors.New("no room left")
+	}
+
+	w.room -= len(b)
+	return len(b), nil
+}
+
+func TestWriteToError(t *testing.T) {
+	program, errs := parser.Parse("ast_test_write", "let x = 1 + 2; let y = x;")
+	if len(errs) != 0 {
+		t.Fatalf("parser has errors: %v", errs)
+	}
+
+	n, err := ast.WriteTo(&failingWriter{room: 10}, program)
+	if err == nil {
+		t.Fatalf("expected the error of the writer")
+	}
+
+	if n != 10 {
+		t.Errorf("wrong count after the error. expect=10, got=%d", n)
+	}
+}
+
+// a program of 1000 statements for the benchmarks
+func benchProgram(b *testing.B) *ast.Program {
+	var input strings.Builder
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&input, "let x%d = f(x, %d) * (y + %d);\n", i, i, i)
+	}
+
+	program, errs := parser.Parse("ast_bench", input.String())
+	if len(errs) != 0 {
+		b.Fatalf("parser has errors: %v", errs)
+	}
+
+	return program
+}
+
+func BenchmarkProgramString(b *testing.B) {
+	program := benchProgram(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_ = program.String()
+	}
+}
+
+func BenchmarkWriteTo(b *testing.B) {
+	program := benchProgram(b)
+	var out bytes.Buffer
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		out.Reset()
+		ast.WriteTo(&out, program)
+	}
+}
+
+func TestWriteSource(t *testing.T) {
+	input := "let  greeting = \"h\\x69\\u{1F600}\";\r\n" +
+		"\n" +
+		"FN  shout(s)   {\n" +
+		"\treturn `${s}!` +\n" +
+		"\t\t\"\"\"\n  raw\"\"\";\n" +
+		"}\n" +
+		"\n" +
+		"shout( greeting ) \\\n" +
+		"  then |x| x;   \n\n"
+
+	l := lexer.NewWithOptions("ast_test_source", input, lexer.Options{Trivia: true, FoldKeywords: true})
+	p := parser.NewWithOptions(l, parser.Options{KeepTokens: true})
+
+	program := p.Parse()
+	checkErrors(t, p)
+
+	var out strings.Builder
+	if _, err := ast.WriteSource(&out, program); err != nil {
+		t.Fatalf("WriteSource failed: %s", err)
+	}
+	if found := out.String(); found != input {
+		t.Errorf("source not written back as it was.\nexpect=%q\ngot=   %q", input, found)
+	}
+
+	// the nodes hold the trivia of their tokens
+	fn := program.Statements[1].(*ast.FunctionStatement)
+	if expect := []string{"\r\n", "\n"}; !reflect.DeepEqual(fn.Token.Trivia, expect) {
+		t.Errorf("wrong trivia on %q. expect=%q, got=%q", fn.Token.Word, expect, fn.Token.Trivia)
+	}
+	if program.Tokens[len(program.Tokens)-1].Type != token.EOF {
+		t.Errorf("tokens do not end with the EOF. got=%v", program.Tokens[len(program.Tokens)-1])
+	}
+
+	// without KeepTokens there is nothing to write
+	program = parser.New(lexer.NewWithOptions("ast_test_source", input, lexer.Options{Trivia: true})).Parse()
+	if program.Tokens != nil {
+		t.Errorf("tokens kept without the option. got=%d", len(program.Tokens))
+	}
+}
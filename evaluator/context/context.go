@@ -56,7 +56,9 @@ func New(in io.Reader, out, err io.Writer) *Context {
 					out += strconv.FormatFloat(v, 'f', -1, 64)
 				case string:
 					out += v
-				case objects.FuncObject:
+				case bool:
+					out += strconv.FormatBool(v)
+				case objects.FuncObject, objects.FuncGroup:
 					out += "function"
 				case nil:
 					out += "null"
@@ -90,7 +92,7 @@ func New(in io.Reader, out, err io.Writer) *Context {
 				return "string"
 			case bool:
 				return "bool"
-			case objects.FuncObject:
+			case objects.FuncObject, objects.FuncGroup:
 				return "function"
 			case BuiltIn:
 				return "builtin"
@@ -7,7 +7,9 @@ import (
 
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"strings"
 )
 
 // global context variable to maintain
@@ -38,9 +40,29 @@ func recoveryHandler() {
 	}
 }
 
-func Evaluate(program *ast.Program) {
+// evaluates the program and returns the value of its
+// last statement when that is an expression statement
+func Evaluate(program *ast.Program) (result any) {
 	defer recoveryHandler()
-	evalStatements(program.Statements)
+
+	stmts := program.Statements
+	if len(stmts) == 0 {
+		return nil
+	}
+
+	evalStatements(stmts[:len(stmts)-1])
+
+	if s, ok := stmts[len(stmts)-1].(*ast.ExpressionStatement); ok {
+		return evalExpression(s.Expression)
+	}
+
+	evalStatement(stmts[len(stmts)-1])
+	return nil
+}
+
+// formats a value the same way as the `str` builtin
+func Inspect(value any) string {
+	return valueStr(value)
 }
 
 func exprErrorHandler(expr ast.Expression) {
@@ -85,8 +107,16 @@ func evalStatement(stmt ast.Statement) {
 		evalLetStatement(s)
 	case *ast.FunctionStatement:
 		evalFunctionStatement(s)
+	case *ast.FunctionGroup:
+		evalFunctionGroup(s)
 	case *ast.ReturnStatement:
 		evalReturnStatement(s)
+	case *ast.AssertStatement:
+		evalAssertStatement(s)
+	case *ast.DeferStatement:
+		panic(fmt.Errorf("defer is not supported yet"))
+	case *ast.PrintStatement:
+		evalPrintStatement(s)
 	case *ast.IfStatement:
 		evalIfStatement(s)
 	case *ast.BlockStatement:
@@ -96,7 +126,33 @@ func evalStatement(stmt ast.Statement) {
 		defer ctxt.RestoreEnv()
 	case *ast.ExpressionStatement:
 		evalExpression(s.Expression)
+	case *ast.AttributedStatement:
+		evalStatement(s.Stmt)
+	}
+}
+
+func evalAssertStatement(s *ast.AssertStatement) {
+	if isTruthy(evalExpression(s.Condition)) {
+		return
 	}
+
+	if s.Message == nil {
+		panic(fmt.Errorf("assertion failed: %s", s.Condition))
+	}
+	panic(fmt.Errorf("assertion failed: %s", valueStr(evalExpression(s.Message))))
+}
+
+func evalPrintStatement(s *ast.PrintStatement) {
+	values := make([]string, len(s.Values))
+	for i, value := range s.Values {
+		values[i] = valueStr(evalExpression(value))
+	}
+
+	out := strings.Join(values, " ")
+	if s.Newline {
+		out += "\n"
+	}
+	io.WriteString(ctxt.Out, out)
 }
 
 func evalFunctionStatement(s *ast.FunctionStatement) {
@@ -107,15 +163,36 @@ func evalFunctionStatement(s *ast.FunctionStatement) {
 	}
 }
 
+func evalFunctionGroup(s *ast.FunctionGroup) {
+	group := objects.FuncGroup{}
+	for _, variant := range s.Variants {
+		group.Variants = append(group.Variants, evalFunctionLiteral(variant.Value))
+	}
+
+	name := s.Ident.Value
+	if !ctxt.Env.Set(name, group) {
+		panic(fmt.Errorf("variable %s already exists in current scope", name))
+	}
+}
+
 func evalLetStatement(s *ast.LetStatement) {
 	name := s.Ident.Value
-	init := evalExpression(s.InitValue)
+
+	// declared without a value it starts out as null
+	var init any
+	if s.InitValue != nil {
+		init = evalExpression(s.InitValue)
+	}
 	if !ctxt.Env.Set(name, init) {
 		panic(fmt.Errorf("variable %s already exists in current scope", name))
 	}
 }
 
 func evalReturnStatement(s *ast.ReturnStatement) {
+	if len(s.ReturnValues) > 1 {
+		panic(fmt.Errorf("returning several values is not supported yet"))
+	}
+
 	var retValue any
 	if s.ReturnValue != nil {
 		retValue = evalExpression(s.ReturnValue)
@@ -124,6 +201,11 @@ func evalReturnStatement(s *ast.ReturnStatement) {
 }
 
 func evalIfStatement(s *ast.IfStatement) {
+	if s.Binding != nil {
+		evalIfLet(s)
+		return
+	}
+
 	condition := evalExpression(s.Condition)
 
 	if isTruthy(condition) {
@@ -133,6 +215,24 @@ func evalIfStatement(s *ast.IfStatement) {
 	}
 }
 
+// the binding only exists in the then block,
+// which runs when the value is not null
+func evalIfLet(s *ast.IfStatement) {
+	value := evalExpression(s.Binding.InitValue)
+	if value == nil {
+		if s.Else != nil {
+			evalStatement(s.Else)
+		}
+		return
+	}
+
+	ctxt.CreateEnv()
+	defer ctxt.RestoreEnv()
+
+	ctxt.Env.Set(s.Binding.Ident.Value, value)
+	evalStatement(s.Then)
+}
+
 func evalExpression(expr ast.Expression) any {
 	defer exprErrorHandler(expr)
 
@@ -141,25 +241,77 @@ func evalExpression(expr ast.Expression) any {
 		return evalInfixExpression(e)
 	case *ast.PrefixExpression:
 		return evalPrefixExpression(e)
+	case *ast.GroupExpression:
+		return evalExpression(e.Inner)
 	case *ast.Identifier:
 		return evalIdentifier(e)
 	case *ast.StringLiteral:
 		return e.Value
+	case *ast.TemplateLiteral:
+		return evalTemplateLiteral(e)
 	case *ast.BoolLiteral:
 		return e.Value
 	case *ast.IntegerLiteral:
 		return e.Value
+	case *ast.BigIntLiteral:
+		panic(fmt.Errorf("integer %s does not fit in 64 bits", e.Token.Word))
 	case *ast.FloatLiteral:
 		return e.Value
 	case *ast.FunctionLiteral:
 		return evalFunctionLiteral(e)
 	case *ast.CallExpression:
 		return evalCallExpression(e)
+	case *ast.DoExpression:
+		return evalValueBlock(e.Body)
+	case *ast.BlockExpression:
+		return evalValueBlock(e.Body)
+	case *ast.BetweenExpression:
+		return evalBetweenExpression(e)
+	case *ast.IfExpression:
+		if isTruthy(evalExpression(e.Condition)) {
+			return evalExpression(e.Then)
+		}
+		return evalExpression(e.Else)
+	case *ast.SequenceExpression:
+		var value any
+		for _, expr := range e.Expressions {
+			value = evalExpression(expr)
+		}
+		return value
+	case *ast.TypeTestExpression:
+		return (typeStr(evalExpression(e.Value)) == e.Type.Value) != e.Negated
+	case *ast.TypeofExpression:
+		return typeStr(evalExpression(e.Right))
+	case *ast.TryExpression:
+		panic(fmt.Errorf("try expressions are not supported yet"))
 	default:
 		panic(fmt.Errorf("unknown expression type %T", expr))
 	}
 }
 
+func evalTemplateLiteral(e *ast.TemplateLiteral) any {
+	out := e.Strings[0]
+	for i, value := range e.Values {
+		out += valueStr(evalExpression(value)) + e.Strings[i+1]
+	}
+
+	return out
+}
+
+// evaluates a do block or block expression to its final expression
+func evalValueBlock(body *ast.BlockStatement) any {
+	ctxt.CreateEnv()
+	// should pop out the current environment no matter what
+	defer ctxt.RestoreEnv()
+
+	stmts := body.Statements
+	evalStatements(stmts[:len(stmts)-1])
+
+	// the parser guarantees the block ends with an expression
+	last := stmts[len(stmts)-1].(*ast.ExpressionStatement)
+	return evalExpression(last.Expression)
+}
+
 func evalCallExpression(e *ast.CallExpression) any {
 	value := evalExpression(e.Callee)
 	if value == nil {
@@ -167,6 +319,10 @@ func evalCallExpression(e *ast.CallExpression) any {
 		panic(fmt.Errorf("cannot function call on null objects"))
 	}
 
+	if len(e.NamedArgs) != 0 {
+		panic(fmt.Errorf("named arguments are not supported yet"))
+	}
+
 	args := evalCallArgs(e.Arguments)
 	return callFunction(value, args)
 }
@@ -192,7 +348,7 @@ func callFunction(fn any, args []any) (retValue any) {
 			case objects.ReturnObject:
 				retValue = val.Value // is a return value
 			default:
-				panic(retValue) // some runtime error
+				panic(err) // some runtime error
 			}
 		}
 		defer returnRetriever() // set return value or propagate error
@@ -201,19 +357,44 @@ func callFunction(fn any, args []any) (retValue any) {
 		ctxt.SetEnv(obj.Env)
 
 		function := obj.Fn
-		if len(args) != len(function.Parameters) {
-			panic(fmt.Errorf("incorrect no of arguments. got=%d, expect=%d",
-				len(args), len(function.Parameters)))
+		if function.Variadic {
+			panic(fmt.Errorf("variadic parameters are not supported yet"))
+		}
+
+		required, most := function.Arity()
+		if len(args) < required || len(args) > most {
+			if required == most {
+				panic(fmt.Errorf("incorrect no of arguments. got=%d, expect=%d",
+					len(args), required))
+			}
+			panic(fmt.Errorf("incorrect no of arguments. got=%d, expect=%d to %d",
+				len(args), required, most))
 		}
 
 		for i, param := range function.Parameters {
-			ctxt.Env.Set(param.Value, args[i])
+			if i < len(args) {
+				ctxt.Env.Set(param.Ident.Value, args[i])
+			} else {
+				// defaults are evaluated in the function's scope
+				// so they can refer to the earlier parameters
+				ctxt.Env.Set(param.Ident.Value, evalExpression(param.Default))
+			}
 		}
 
 		evalStatements(function.Body.Statements)
 		// reaching here means function does not return any value
 		// in one of the control flow paths
 		panic(objects.ReturnObject{Value: nil})
+	case objects.FuncGroup:
+		// the parser makes sure no two variants take the same
+		// number of arguments, so the first one taking it is the only
+		for _, variant := range obj.Variants {
+			least, most := variant.Fn.Arity()
+			if len(args) >= least && (most < 0 || len(args) <= most) {
+				return callFunction(variant, args)
+			}
+		}
+		panic(fmt.Errorf("no variant of the function takes %d arguments", len(args)))
 	case context.BuiltIn:
 		return obj(args...)
 	default:
@@ -240,6 +421,15 @@ func evalIdentifier(e *ast.Identifier) any {
 }
 
 func evalInfixExpression(e *ast.InfixExpression) any {
+	// logical operators only evaluate the right
+	// operand when the left one does not decide
+	switch e.Operator {
+	case "&&", "and":
+		return isTruthy(evalExpression(e.Left)) && isTruthy(evalExpression(e.Right))
+	case "||", "or":
+		return isTruthy(evalExpression(e.Left)) || isTruthy(evalExpression(e.Right))
+	}
+
 	left := evalExpression(e.Left)
 	if left == nil {
 		return nil
@@ -259,6 +449,8 @@ func evalInfixExpression(e *ast.InfixExpression) any {
 		return evalMulOperator(left, right)
 	case "/":
 		return evalDivOperator(left, right)
+	case "**":
+		return evalPowOperator(left, right)
 	case "<":
 		return evalLtOperator(left, right)
 	case ">":
@@ -267,15 +459,31 @@ func evalInfixExpression(e *ast.InfixExpression) any {
 		return !evalGtOperator(left, right)
 	case ">=":
 		return !evalLtOperator(left, right)
+	case "<=>":
+		return evalCmpOperator(left, right)
 	case "==":
 		return evalEqOperator(left, right)
 	case "!=":
 		return !evalEqOperator(left, right)
+	case "&", "|", "<<", ">>":
+		return evalBitwiseOperator(e.Operator, left, right)
 	default:
 		panic(fmt.Errorf("unknown operator %s", e.Operator))
 	}
 }
 
+func evalBetweenExpression(e *ast.BetweenExpression) any {
+	// the value is evaluated once for both comparisons
+	value := evalExpression(e.Value)
+	low := evalExpression(e.Low)
+	high := evalExpression(e.High)
+	if value == nil || low == nil || high == nil {
+		return nil
+	}
+
+	return !evalLtOperator(value, low) && !evalGtOperator(value, high)
+}
+
 func evalAddOperator(left, right any) any {
 	switch l := left.(type) {
 	case int64:
@@ -391,6 +599,64 @@ func evalDivOperator(left, right any) any {
 	}
 }
 
+// integer powers stay integers unless the exponent is negative
+func evalPowOperator(left, right any) any {
+	if l, ok := left.(int64); ok {
+		if r, ok := right.(int64); ok && r >= 0 {
+			result := int64(1)
+			for ; r > 0; r >>= 1 {
+				if r&1 == 1 {
+					result *= l
+				}
+				l *= l
+			}
+			return result
+		}
+	}
+
+	l, lok := toFloat(left)
+	r, rok := toFloat(right)
+	if !lok || !rok {
+		panic(fmt.Errorf("power not supported for %s and %s", typeStr(left), typeStr(right)))
+	}
+
+	return math.Pow(l, r)
+}
+
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+func evalBitwiseOperator(op string, left, right any) any {
+	l, lok := left.(int64)
+	r, rok := right.(int64)
+	if !lok || !rok {
+		panic(fmt.Errorf("operator %s not supported for %s and %s", op, typeStr(left), typeStr(right)))
+	}
+
+	switch op {
+	case "&":
+		return l & r
+	case "|":
+		return l | r
+	}
+
+	if r < 0 {
+		panic(fmt.Errorf("negative shift count %d", r))
+	}
+	if op == "<<" {
+		return l << r
+	}
+	return l >> r
+}
+
 func evalLtOperator(left, right any) bool {
 	switch l := left.(type) {
 	case int64:
@@ -416,6 +682,18 @@ func evalLtOperator(left, right any) bool {
 	}
 }
 
+// -1, 0 or 1 as left is less than, equal to or greater than right
+func evalCmpOperator(left, right any) int64 {
+	switch {
+	case evalLtOperator(left, right):
+		return -1
+	case evalGtOperator(left, right):
+		return 1
+	default:
+		return 0
+	}
+}
+
 func evalGtOperator(left, right any) bool {
 	return !evalLtOperator(left, right) && !evalEqOperator(left, right)
 }
@@ -459,10 +737,21 @@ func evalPrefixExpression(e *ast.PrefixExpression) any {
 	}
 
 	switch e.Operator {
-	case "!":
+	case "!", "not":
 		return evalBangOperator(right)
 	case "-":
 		return evalNegateOperator(right)
+	case "+":
+		switch right.(type) {
+		case int64, float64:
+			return right
+		}
+		panic(fmt.Errorf("unary plus not supported for %s", typeStr(right)))
+	case "~":
+		if v, ok := right.(int64); ok {
+			return ^v
+		}
+		panic(fmt.Errorf("bitwise not supported for %s", typeStr(right)))
 	default:
 		panic(fmt.Errorf("unknown operator %s", e.Operator))
 	}
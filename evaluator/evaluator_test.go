@@ -70,6 +70,13 @@ func TestLetStatement(t *testing.T) {
 				{"s", "hello world"},
 			},
 		},
+		{
+			"let n; let m = n;",
+			[]expectType{
+				{"n", nil},
+				{"m", nil},
+			},
+		},
 	}
 
 	for i, test := range tests {
@@ -79,10 +86,98 @@ func TestLetStatement(t *testing.T) {
 	}
 }
 
+func TestDoExpression(t *testing.T) {
+	input := "let x = do { let a = 2; a * 21 };"
+
+	testLetStatements(t, input, []expectType{
+		{"x", int64(42)},
+	})
+}
+
+func TestTemplateLiteral(t *testing.T) {
+	input := "let name = \"ro\"; let x = `hi ${name}, ${1 + 2} ${true}`;"
+
+	testLetStatements(t, input, []expectType{
+		{"name", "ro"},
+		{"x", "hi ro, 3 true"},
+	})
+}
+
+func TestBetweenExpression(t *testing.T) {
+	input := "let a = 5 between 1 and 10; let b = 1.5 between 2 and 3; let c = 3 between 1 and 3;"
+
+	testLetStatements(t, input, []expectType{
+		{"a", true},
+		{"b", false},
+		{"c", true},
+	})
+}
+
+func TestBlockExpression(t *testing.T) {
+	input := "let y = { let x = 1; x + 1 };"
+
+	testLetStatements(t, input, []expectType{
+		{"y", int64(2)},
+	})
+}
+
+func TestFunctionGroup(t *testing.T) {
+	input := `
+fn area(r) { return r * r * 3; }
+fn area(w, h) { return w * h; }
+fn area(w, h, d, s = 1) { return w * h * d * s; }
+let a = area(2);
+let b = area(2, 5);
+let c = area(2, 5, 3);
+let d = area(2, 5, 3, 2);
+`
+	testLetStatements(t, input, []expectType{
+		{"a", int64(12)},
+		{"b", int64(10)},
+		{"c", int64(30)},
+		{"d", int64(60)},
+	})
+}
+
+func TestPipelineExpression(t *testing.T) {
+	input := `
+fn double(n) { return n * 2; }
+let a = 5 then |x| double(x);
+let b = 1 then |x| x + 1 then |y| double(y) then |z| z - 1;
+`
+	testLetStatements(t, input, []expectType{
+		{"a", int64(10)},
+		{"b", int64(3)},
+	})
+}
+
+func TestArrowFunction(t *testing.T) {
+	input := `
+let double = fn(n) => n * 2;
+let apply = fn(f, x) => f(x);
+let a = apply(double, 21);
+`
+	testLetStatements(t, input, []expectType{
+		{"a", int64(42)},
+	})
+}
+
 func TestCallExpressions(t *testing.T) {
 	// TODO needs test
 }
 
+func TestDefaultParameters(t *testing.T) {
+	input := `
+fn add(a, b = a + 1) { return a + b; }
+let x = add(1);
+let y = add(1, 5);
+`
+	testLetStatements(t, input, []expectType{
+		{"x", int64(3)},
+		{"y", int64(6)},
+	})
+}
+
 func TestClosureExpression(t *testing.T) {
 	// TODO needs test
 }
@@ -123,8 +218,29 @@ func TestInfixOperator(t *testing.T) {
 		{"(3.0 + 2.0) * 2.0 == 10.0", true},
 		{"5.0 >= 5.0", true},
 		{"7.5 <= 7.4", false},
-		// {"(10.0 > 5.0) && (2.0 < 4.0)", true},
-		// {"(10.0 < 5.0) || (2.0 > 1.0)", true},
+		{"(10.0 > 5.0) && (2.0 < 4.0)", true},
+		{"(10.0 < 5.0) || (2.0 > 1.0)", true},
+		{"1 > 2 and 2 > 1", false},
+		{"1 > 2 or not false", true},
+		{"1 is int", true},
+		{`"a" is not string`, false},
+		{"1.5 is int or 1.5 is float", true},
+		{"typeof 1.5", "float"},
+		{`typeof "a" + "!"`, "string!"},
+		{"(1, 2, 3) + 1", 4},
+		{"1 unless 2 > 1 else 2", 2},
+		{"1 unless false else 2", 1},
+		{"1 > 2 ? 3 : 4", 4},
+		{"1 < 2 ? 3 : 4 ? 5 : 6", 3},
+		{"6 & 3 | 8", 10},
+		{"1 << 4 >> 2", 4},
+		{"~5 & 7", 2},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"+(2 - 5) * -+2", 6},
+		{"2 ** -1", 0.5},
+		{"1.5e2 - -1e-1", 150.1},
 		{"!(3.5 == 3.5)", false},
 		{"true == true", true},
 		{"false == false", true},
@@ -135,6 +251,10 @@ func TestInfixOperator(t *testing.T) {
 		{"(1 < 2) == false", false},
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
+		{"1 <=> 2", -1},
+		{"2 <=> 2", 0},
+		{"2.5 <=> 2", 1},
+		{"1 + 1 <=> 2 == 0", true},
 		{`"hello" + 1`, "hello1"},
 		{`1 + "hello" + 2.23`, "1hello2.23"},
 	}
@@ -184,6 +304,10 @@ func TestErrorStatements(t *testing.T) {
 		{"let x = y;", "variable not found: y"},
 		{"let x = 1; let y = x();", "not a callable int"},
 		{"fn f(){} let x = f(); x();", "cannot function call on null objects"},
+		{"assert 1 > 2;", "assertion failed: (1 > 2)"},
+		{`let n = 3; assert n < 2, "n is " + n;`, "assertion failed: n is 3"},
+		{"fn f(a) { return a; } f(1, 2);", "incorrect no of arguments. got=2, expect=1"},
+		{"fn sum(...n) { return 0; } sum(1, 2, 3);", "variadic parameters are not supported yet"},
 	}
 
 	for i, test := range tests {
@@ -201,6 +325,28 @@ func TestOutStatements(t *testing.T) {
 
 	Init(nil, out, nil)
 
+	testEvalStatements(`let a = 1; print "a is", a; println; println a + 1, 2.5, true;`)
+	if expect := "a is 1\n2 2.5 true\n"; out.String() != expect {
+		t.Errorf("wrong output. got=%q expect=%q", out.String(), expect)
+	}
+}
+
+func TestIfLet(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	Init(nil, out, nil)
+
+	testEvalStatements(`
+fn f(n) { if n > 0 { return n * 2; } }
+if let x = f(2) { println "got", x; } else { println "none"; }
+if let x = f(0) { println "got", x; } else { println "none"; }
+let x = 1;
+if let x = f(3) { println x; }
+println x;
+`)
+	if expect := "got 4\nnone\n6\n1\n"; out.String() != expect {
+		t.Errorf("wrong output. got=%q expect=%q", out.String(), expect)
+	}
 }
 
 func testLetStatements(t *testing.T, input string, expects []expectType) bool {
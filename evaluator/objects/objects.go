@@ -13,4 +13,8 @@ type (
 		Env *env.Environment
 		Fn  *ast.FunctionLiteral
 	}
+	// overloads of a function, called by argument count
+	FuncGroup struct {
+		Variants []FuncObject
+	}
 )
@@ -3,39 +3,167 @@ package lexer
 import (
 	"RoLang/token"
 	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
 )
 
 type Lexer struct {
-	file   string
-	input  string
-	line   uint // current line number
-	col    uint // current column number
-	offset uint // next position to read
-	char   byte // current ASCII character
+	file    string
+	input   string
+	options Options
+	line    uint // current line number
+	col     uint // current column number
+	offset  uint // next position to read
+	char    byte // current ASCII character
+
+	// the whitespace read so far is indenting a line,
+	// and which kinds of it were seen
+	lineStart   bool
+	indentTabs  bool
+	indentSpace bool
+	warnings    []Warning
+	// the last token was a NEWLINE
+	newlineRun bool
+	// the whitespace before the current token only
+	// broke lines escaped with a '\'
+	joined bool
+
+	// where more input comes from when it is read in
+	// pieces, nil once all of it is in input
+	reader  io.Reader
+	stream  bool
+	buf     []byte
+	readErr error
+}
+
+// an advisory note about the source, lexing carries on as usual
+type Warning struct {
+	Loc     token.SrcLoc
+	Message string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s %s", w.Loc, w.Message)
+}
+
+// optional lexer behaviour, the zero value
+// gives the default behaviour
+type Options struct {
+	// columns a tab advances by, so reported columns match
+	// the editor's display, zero counts a tab as one column
+	TabWidth int
+	// count lines and columns from 0 as editor protocols like
+	// LSP do, instead of from 1
+	ZeroBased bool
+	// match keywords regardless of case, so `LET` and `Let` are `let`,
+	// identifiers stay case-sensitive
+	FoldKeywords bool
+	// warn about lines indented with both tabs and spaces
+	CheckIndentation bool
+	// produce a NEWLINE token for each line break instead of skipping
+	// it, for tools like formatters, the parser does not expect them.
+	// CollapseNewlines produces one for a run of them.
+	EmitNewlines     bool
+	CollapseNewlines bool
+	// keep the whitespace before each token and the token as written
+	// in the source, so the input can be printed back byte for byte.
+	// The whitespace after the last token goes with the EOF.
+	Trivia bool
+	// keywords of a language variant, looked up before the default
+	// ones, mapping a word to token.IDENT makes it a name again.
+	// Token types from token.TOTAL on are free for new keywords,
+	// the parser of this package does not accept them.
+	Keywords map[string]token.TokenType
 }
 
 func New(file, input string) *Lexer {
+	return NewWithOptions(file, input, Options{})
+}
+
+// Creates a lexer that knows the extra keywords as well as the
+// default ones, see Options.Keywords
+func NewWithKeywords(file, input string, extra map[string]token.TokenType) *Lexer {
+	return NewWithOptions(file, input, Options{Keywords: extra})
+}
+
+func NewWithOptions(file, input string, options Options) *Lexer {
+	l := newLexer(file, input, options)
+
+	// Read the first char to set the state
+	l.readChar()
+	return l
+}
+
+func newLexer(file, input string, options Options) *Lexer {
+	if options.TabWidth <= 0 {
+		options.TabWidth = 1
+	}
+
 	// Allocating on heap
 	l := &Lexer{
-		file:  file,
-		input: input,
-		line:  1,
-		col:   1,
+		file:    file,
+		input:   input,
+		options: options,
 	}
+	l.lineStart = true
+	l.line = l.firstPos()
+	l.col = l.firstPos()
+
+	return l
+}
+
+// Creates a lexer for a piece of source found at loc inside a larger
+// file, so the tokens it produces are located in that file. The options
+// should count lines and columns the way the lexer of the file does.
+func NewAt(loc token.SrcLoc, input string, options Options) *Lexer {
+	l := newLexer(loc.File, input, options)
+	l.lineStart = false
+	l.line, l.col = loc.Line, loc.Col
 
-	// Read the first char to set the state
 	l.readChar()
 	return l
 }
 
+// the options of the lexer, with TabWidth set to the columns a tab
+// takes where it was left at zero
+func (l *Lexer) Options() Options {
+	return l.options
+}
+
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
+	l.compact()
 
+	begin := l.offset - 1
 	l.skipWhiteSpace()
+	start := l.offset - 1
+
+	tok := l.scanToken()
+	if l.options.Trivia {
+		tok.Trivia = splitTrivia(l.source(begin, start))
+		tok.Source = l.source(start, l.offset-1)
+	}
+
+	return tok
+}
+
+func (l *Lexer) scanToken() token.Token {
+	var tok token.Token
+
+	l.newlineRun = false
 
 	switch l.char {
+	case '\n':
+		// only reached when newlines are emitted
+		tok = l.makeToken(token.NEWLINE, "\n")
+		l.newline()
+		l.newlineRun = true
 	case ';':
 		tok = l.makeToken(token.SEMCOL, ";")
+	case ':':
+		tok = l.makeToken(token.COLON, ":")
+	case '?':
+		tok = l.makeToken(token.QUESTION, "?")
 	case '(':
 		tok = l.makeToken(token.LPAREN, "(")
 	case ')':
@@ -44,22 +172,85 @@ func (l *Lexer) NextToken() token.Token {
 		tok = l.makeToken(token.LBRACE, "{")
 	case '}':
 		tok = l.makeToken(token.RBRACE, "}")
+	case '[':
+		tok = l.makeToken(token.LBRACKET, "[")
+	case ']':
+		tok = l.makeToken(token.RBRACKET, "]")
 	case ',':
 		tok = l.makeToken(token.COMMA, ",")
+	case '@':
+		tok = l.makeToken(token.AT, "@")
+	case '#':
+		// only outer attributes start with '#'
+		if l.peekChar() == '[' {
+			l.readChar()
+			tok = l.makeToken(token.HASH, "#[")
+		} else {
+			tok = l.unknownChar()
+		}
 	case '+':
-		tok = l.makeToken(token.PLUS, "+")
+		if l.peekChar() == '=' {
+			l.readChar()
+			tok = l.makeToken(token.PLUS_ASSIGN, "+=")
+		} else {
+			tok = l.makeToken(token.PLUS, "+")
+		}
 	case '-':
-		tok = l.makeToken(token.MINUS, "-")
+		if l.peekChar() == '=' {
+			l.readChar()
+			tok = l.makeToken(token.MINUS_ASSIGN, "-=")
+		} else {
+			tok = l.makeToken(token.MINUS, "-")
+		}
 	case '*':
-		tok = l.makeToken(token.STAR, "*")
+		if l.peekChar() == '=' {
+			l.readChar()
+			tok = l.makeToken(token.STAR_ASSIGN, "*=")
+		} else if l.peekChar() == '*' {
+			l.readChar()
+			tok = l.makeToken(token.POW, "**")
+		} else {
+			tok = l.makeToken(token.STAR, "*")
+		}
 	case '/':
-		tok = l.makeToken(token.SLASH, "/")
+		if l.peekChar() == '=' {
+			l.readChar()
+			tok = l.makeToken(token.SLASH_ASSIGN, "/=")
+		} else {
+			tok = l.makeToken(token.SLASH, "/")
+		}
 	case '"':
-		tok = l.readString()
+		if l.hasPrefix(`"""`) {
+			tok = l.readRawString()
+		} else {
+			tok = l.readString()
+		}
+	case '`':
+		tok = l.readTemplate()
+	case '.':
+		if l.hasPrefix("...") {
+			l.readChar()
+			l.readChar()
+			tok = l.makeToken(token.ELLIPSIS, "...")
+		} else if l.hasPrefix("..=") {
+			l.readChar()
+			l.readChar()
+			tok = l.makeToken(token.DOTDOTEQ, "..=")
+		} else if l.hasPrefix("..") {
+			l.readChar()
+			tok = l.makeToken(token.DOTDOT, "..")
+		} else {
+			tok = l.makeToken(token.DOT, ".")
+		}
 	case '=':
+		// operators of several characters are only formed from adjacent
+		// ones, with whitespace between them `! =` is '!' and then '='
 		if l.peekChar() == '=' {
 			l.readChar()
 			tok = l.makeToken(token.EQ, "==")
+		} else if l.peekChar() == '>' {
+			l.readChar()
+			tok = l.makeToken(token.ARROW, "=>")
 		} else {
 			tok = l.makeToken(token.ASSIGN, "=")
 		}
@@ -71,9 +262,16 @@ func (l *Lexer) NextToken() token.Token {
 			tok = l.makeToken(token.BANG, "!")
 		}
 	case '<':
-		if l.peekChar() == '=' {
+		if l.hasPrefix("<=>") {
+			l.readChar()
+			l.readChar()
+			tok = l.makeToken(token.SPACESHIP, "<=>")
+		} else if l.peekChar() == '=' {
 			l.readChar()
 			tok = l.makeToken(token.LE, "<=")
+		} else if l.peekChar() == '<' {
+			l.readChar()
+			tok = l.makeToken(token.SHL, "<<")
 		} else {
 			tok = l.makeToken(token.LT, "<")
 		}
@@ -81,11 +279,42 @@ func (l *Lexer) NextToken() token.Token {
 		if l.peekChar() == '=' {
 			l.readChar()
 			tok = l.makeToken(token.GE, ">=")
+		} else if l.peekChar() == '>' {
+			l.readChar()
+			tok = l.makeToken(token.SHR, ">>")
 		} else {
 			tok = l.makeToken(token.GT, ">")
 		}
+	case '&':
+		if l.peekChar() == '&' {
+			l.readChar()
+			tok = l.makeToken(token.AND, "&&")
+		} else {
+			tok = l.makeToken(token.AMP, "&")
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			l.readChar()
+			tok = l.makeToken(token.OR, "||")
+		} else {
+			tok = l.makeToken(token.PIPE, "|")
+		}
+	case '~':
+		tok = l.makeToken(token.TILDE, "~")
 	case 0:
-		tok = l.makeToken(token.EOF, "eof")
+		// a NUL byte inside the input is not its end
+		if l.offset <= uint(len(l.input)) {
+			tok = l.unknownChar()
+		} else if l.readErr != nil {
+			// the input ends early, reported once before the EOF
+			tok = l.makeErr(fmt.Sprintf("reading %s: %s", l.file, l.readErr))
+			l.readErr = nil
+		} else {
+			// the end stays right after the last character
+			// however often it is read
+			tok = l.makeToken(token.EOF, "eof")
+			l.col = tok.Loc.Col
+		}
 	default:
 		if isAlpha(l.char) { // check [A-Za-z_]
 			tok = l.readIdent()
@@ -94,7 +323,7 @@ func (l *Lexer) NextToken() token.Token {
 			tok = l.readNum()
 			return tok
 		} else {
-			tok = l.makeErr(fmt.Sprintf("Unknown token %c", l.char))
+			tok = l.unknownChar()
 		}
 	}
 
@@ -103,6 +332,60 @@ func (l *Lexer) NextToken() token.Token {
 	return tok
 }
 
+// the input between two offsets, which go past
+// its end once the EOF has been read
+func (l *Lexer) source(from, to uint) string {
+	end := uint(len(l.input))
+	from, to = min(from, end), min(to, end)
+
+	if l.stream {
+		return strings.Clone(l.input[from:to])
+	}
+	return l.input[from:to]
+}
+
+// splits whitespace into runs of blanks, line breaks
+// and line breaks escaped with '\'
+func splitTrivia(space string) []string {
+	var trivia []string
+
+	for space != "" {
+		n := 0
+		switch {
+		case strings.HasPrefix(space, "\r\n"), strings.HasPrefix(space, "\\\n"):
+			n = 2
+		case strings.HasPrefix(space, "\\\r\n"):
+			n = 3
+		case space[0] == '\n':
+			n = 1
+		default:
+			n = 1
+			for n < len(space) && space[n] != '\n' && space[n] != '\\' && !strings.HasPrefix(space[n:], "\r\n") {
+				n++
+			}
+		}
+
+		trivia = append(trivia, space[:n])
+		space = space[n:]
+	}
+
+	return trivia
+}
+
+// Drains the rest of the input, returning its
+// tokens up to and including the final EOF
+func (l *Lexer) Tokens() []token.Token {
+	tokens := []token.Token{}
+
+	for {
+		tok := l.NextToken()
+		tokens = append(tokens, tok)
+		if tok.Type == token.EOF {
+			return tokens
+		}
+	}
+}
+
 func (l *Lexer) makeToken(tokenType token.TokenType, word string) token.Token {
 	token := token.Token{
 		Loc: token.SrcLoc{
@@ -110,8 +393,9 @@ func (l *Lexer) makeToken(tokenType token.TokenType, word string) token.Token {
 			Line: l.line, // current line
 			Col:  l.col,  // current column
 		},
-		Type: tokenType,
-		Word: word,
+		Type:   tokenType,
+		Word:   word,
+		Joined: l.joined,
 	}
 
 	l.col += uint(len(word))
@@ -125,23 +409,280 @@ func (l *Lexer) makeErr(message string) token.Token {
 			Line: l.line, // current line
 			Col:  l.col,  // current column
 		},
-		Type: token.ERR,
-		Word: message,
+		Type:   token.ERR,
+		Word:   message,
+		Joined: l.joined,
 	}
 }
 
+// an error for a byte no token starts with, lexing
+// carries on after it
+func (l *Lexer) unknownChar() token.Token {
+	var tok token.Token
+	if ' ' < l.char && l.char < 0x7f {
+		tok = l.makeErr(fmt.Sprintf("Unknown token %c", l.char))
+	} else {
+		// control characters and bytes of UTF-8 sequences
+		tok = l.makeErr(fmt.Sprintf("Unknown token \\x%02x", l.char))
+	}
+	l.col++
+
+	return tok
+}
+
+func (l *Lexer) makeErrAt(loc token.SrcLoc, message string) token.Token {
+	return token.Token{
+		Loc:    loc,
+		Type:   token.ERR,
+		Word:   message,
+		Joined: l.joined,
+	}
+}
+
+// reads a double quoted string, the word is its text with the
+// escapes decoded. A malformed escape makes the string an error
+// located at its '\', reported once the closing quote is found.
 func (l *Lexer) readString() token.Token {
-	l.readChar() // consume '"'
+	loc := token.SrcLoc{File: l.file, Line: l.line, Col: l.col}
+	l.advance() // consume '"'
 
+	// strings without escapes are taken from the input as they are,
+	// the word is only built up once the first escape is found
 	start := l.offset - 1
+	escaped := false
+
+	var word strings.Builder
+	var err *token.Token
 
 	for l.char != '"' && l.char != 0 {
-		l.readChar()
+		if l.char != '\\' {
+			if escaped {
+				word.WriteByte(l.char)
+			}
+			l.advance()
+			continue
+		}
+
+		if !escaped {
+			word.WriteString(l.input[start : l.offset-1])
+			escaped = true
+		}
+		if tok, ok := l.readEscape(&word); !ok && err == nil {
+			err = &tok
+		}
+	}
+
+	if l.char == '"' {
+		l.col++ // account for the closing quote
+	}
+	if err != nil {
+		return *err
+	}
+	if !escaped {
+		return token.Token{Loc: loc, Type: token.STRING, Word: l.slice(start), Joined: l.joined}
+	}
+
+	return token.Token{Loc: loc, Type: token.STRING, Word: word.String(), Joined: l.joined}
+}
+
+// decodes the escape starting at the current '\' into word, a
+// malformed one gives the error to report instead
+func (l *Lexer) readEscape(word *strings.Builder) (token.Token, bool) {
+	loc := token.SrcLoc{File: l.file, Line: l.line, Col: l.col}
+	l.advance() // consume '\'
+
+	escape := l.char
+	switch escape {
+	case 'n':
+		word.WriteByte('\n')
+	case 't':
+		word.WriteByte('\t')
+	case 'r':
+		word.WriteByte('\r')
+	case '0':
+		word.WriteByte(0)
+	case '\\', '"':
+		word.WriteByte(escape)
+	case 'x':
+		// `\xHH` is the code point 0xHH
+		l.advance()
+		value, digits := l.readHex(2)
+		if digits != 2 {
+			return l.makeErrAt(loc, "invalid escape \\x, expected two hex digits"), false
+		}
+		word.WriteRune(rune(value))
+		return token.Token{}, true
+	case 'u':
+		return l.readUnicodeEscape(word, loc)
+	default:
+		if escape == 0 {
+			return l.makeErrAt(loc, "unterminated escape"), false
+		}
+		l.advance()
+		return l.makeErrAt(loc, fmt.Sprintf("unknown escape \\%c", escape)), false
+	}
+
+	l.advance()
+	return token.Token{}, true
+}
+
+// decodes `\u{H...}` with up to six hex digits, the '\' and 'u' are read
+func (l *Lexer) readUnicodeEscape(word *strings.Builder, loc token.SrcLoc) (token.Token, bool) {
+	l.advance() // consume 'u'
+	if l.char != '{' {
+		return l.makeErrAt(loc, "invalid escape \\u, expected '{'"), false
+	}
+	l.advance()
+
+	value, digits := l.readHex(7)
+	if digits == 0 || digits > 6 || l.char != '}' {
+		return l.makeErrAt(loc, "invalid escape \\u{...}, expected one to six hex digits"), false
+	}
+	l.advance()
+
+	if value > utf8.MaxRune || 0xD800 <= value && value <= 0xDFFF {
+		return l.makeErrAt(loc, fmt.Sprintf("escape \\u{%X} is not a valid code point", value)), false
+	}
+	word.WriteRune(rune(value))
+
+	return token.Token{}, true
+}
+
+// reads up to max hex digits, returning their value and count
+func (l *Lexer) readHex(max int) (uint64, int) {
+	value, digits := uint64(0), 0
+	for ; digits < max; digits++ {
+		var digit byte
+		switch {
+		case isDigit(l.char):
+			digit = l.char - '0'
+		case 'a' <= l.char && l.char <= 'f':
+			digit = l.char - 'a' + 10
+		case 'A' <= l.char && l.char <= 'F':
+			digit = l.char - 'A' + 10
+		default:
+			return value, digits
+		}
+
+		value = value*16 + uint64(digit)
+		l.advance()
+	}
+
+	return value, digits
+}
+
+// reads a triple quoted string, keeping newlines, backslashes
+// and single quotes in it exactly as they are written
+func (l *Lexer) readRawString() token.Token {
+	loc := token.SrcLoc{File: l.file, Line: l.line, Col: l.col}
+
+	// consume the opening quotes
+	l.advance()
+	l.advance()
+	l.advance()
+
+	start := l.offset - 1
+
+	for !l.hasPrefix(`"""`) {
+		if l.char == 0 {
+			return l.makeErrAt(loc, "unterminated raw string")
+		}
+		l.advance()
+	}
+
+	word := l.slice(start)
+
+	// stop on the last closing quote
+	l.advance()
+	l.advance()
+	l.col++
+
+	return token.Token{Loc: loc, Type: token.STRING, Word: word, Joined: l.joined, Raw: true}
+}
+
+// reads a backtick delimited template up to its closing backtick,
+// the word is the raw source between the backticks with the
+// `${...}` interpolations left in place for the parser to split
+func (l *Lexer) readTemplate() token.Token {
+	loc := token.SrcLoc{File: l.file, Line: l.line, Col: l.col}
+
+	l.readChar() // consume '`'
+	l.col++
+
+	start := l.offset - 1
+
+	for l.char != '`' {
+		switch {
+		case l.char == 0:
+			return l.makeErrAt(loc, "unterminated template string")
+		case l.char == '$' && l.peekChar() == '{':
+			l.advance()
+			l.advance()
+			if !l.skipInterpolation() {
+				return l.makeErrAt(loc, "unterminated interpolation in template string")
+			}
+		default:
+			l.advance()
+		}
+	}
+
+	word := l.slice(start)
+	l.col++ // account for the closing backtick
+
+	return token.Token{Loc: loc, Type: token.TEMPLATE, Word: word, Joined: l.joined}
+}
+
+// skips to the '}' closing an interpolation, keeping count of
+// nested braces and ignoring any inside string literals
+func (l *Lexer) skipInterpolation() bool {
+	depth := 1
+
+	for {
+		switch l.char {
+		case 0, '`':
+			return false
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '"':
+			l.advance()
+			for l.char != '"' {
+				if l.char == 0 {
+					return false
+				}
+				// an escaped quote does not end the string
+				if l.char == '\\' {
+					l.advance()
+					if l.char == 0 {
+						return false
+					}
+				}
+				l.advance()
+			}
+		}
+
+		l.advance()
+		if depth == 0 {
+			return true
+		}
 	}
+}
 
-	word := l.input[start : l.offset-1]
+// reads the current character and moves the column along
+// with it, starting a new line after a line break
+func (l *Lexer) advance() {
+	switch l.char {
+	case '\n':
+		l.line++
+		l.col = l.firstPos()
+	case '\t':
+		l.col += uint(l.options.TabWidth)
+	default:
+		l.col++
+	}
 
-	return l.makeToken(token.STRING, word)
+	l.readChar()
 }
 
 func (l *Lexer) readIdent() token.Token {
@@ -153,12 +694,29 @@ func (l *Lexer) readIdent() token.Token {
 		l.readChar()
 	}
 
-	word := l.input[start : l.offset-1]
-	tokType = token.LookUpKeyword(word) // lookup for keywords: fn, let, return...
+	word := l.slice(start)
+	tokType = l.lookUpKeyword(word) // lookup for keywords: fn, let, return...
+
+	if tokType == token.IDENT && l.options.FoldKeywords {
+		// a folded keyword is spelled as usual so the
+		// parser sees the same token either way
+		if folded := strings.ToLower(word); l.lookUpKeyword(folded) != token.IDENT {
+			tokType, word = l.lookUpKeyword(folded), folded
+		}
+	}
 
 	return l.makeToken(tokType, word)
 }
 
+// the keywords of the lexer's options come before the defaults
+func (l *Lexer) lookUpKeyword(word string) token.TokenType {
+	if tokType, ok := l.options.Keywords[word]; ok {
+		return tokType
+	}
+
+	return token.LookUpKeyword(word)
+}
+
 func (l *Lexer) readNum() token.Token {
 	var tokType token.TokenType
 
@@ -169,7 +727,8 @@ func (l *Lexer) readNum() token.Token {
 		l.readChar()
 	}
 
-	if l.char == '.' { // floating point literal
+	// `1..2` is a range rather than the float `1.`
+	if l.char == '.' && l.peekChar() != '.' { // floating point literal
 		l.readChar()
 		tokType = token.FLOAT
 		for isDigit(l.char) {
@@ -177,12 +736,66 @@ func (l *Lexer) readNum() token.Token {
 		}
 	}
 
-	word := l.input[start : l.offset-1]
+	// an exponent needs its digits, otherwise the 'e'
+	// starts the next token
+	if l.char == 'e' || l.char == 'E' {
+		digits := l.offset
+		if next := l.peekChar(); next == '+' || next == '-' {
+			digits++
+		}
 
-	return l.makeToken(tokType, word)
+		l.fill(digits + 1)
+		if digits < uint(len(l.input)) && isDigit(l.input[digits]) {
+			for l.offset <= digits {
+				l.readChar()
+			}
+			tokType = token.FLOAT
+			for isDigit(l.char) {
+				l.readChar()
+			}
+		}
+	}
+
+	word := l.slice(start)
+
+	// a trailing 'i' or 'f' picks the type, `5f` is a float, and
+	// is left out of the word. It is only a suffix when no name
+	// continues after it, `5if` is still the 5 before `if`
+	suffix := l.char
+	if suffix != 'i' && suffix != 'f' {
+		return l.makeToken(tokType, word)
+	}
+	if next := l.peekChar(); isAlpha(next) || isDigit(next) || next == '_' {
+		return l.makeToken(tokType, word)
+	}
+	l.readChar()
+
+	var tok token.Token
+	switch {
+	case suffix == 'f':
+		tok = l.makeToken(token.FLOAT, word)
+	case tokType == token.FLOAT:
+		tok = l.makeErr(fmt.Sprintf("float literal %si cannot take the integer suffix", word))
+		l.col += uint(len(word))
+	default:
+		tok = l.makeToken(token.INT, word)
+	}
+	l.col++ // account for the suffix
+
+	return tok
+}
+
+// the number of the first line and column
+func (l *Lexer) firstPos() uint {
+	if l.options.ZeroBased {
+		return 0
+	}
+
+	return 1
 }
 
 func (l *Lexer) readChar() {
+	l.fill(l.offset + 1)
 	if l.offset >= uint(len(l.input)) {
 		l.char = 0
 	} else {
@@ -193,32 +806,88 @@ func (l *Lexer) readChar() {
 }
 
 func (l *Lexer) peekChar() byte {
-	if l.offset == uint(len(l.input)) {
+	l.fill(l.offset + 1)
+	if l.offset >= uint(len(l.input)) {
 		return 0
 	}
 
 	return l.input[l.offset]
 }
 
+// reports whether the input starting at the
+// current character begins with word
+func (l *Lexer) hasPrefix(word string) bool {
+	l.fill(l.offset - 1 + uint(len(word)))
+	if l.offset > uint(len(l.input)) {
+		return false
+	}
+
+	return strings.HasPrefix(l.input[l.offset-1:], word)
+}
+
 func (l *Lexer) skipWhiteSpace() {
+	// a '\' right before a line break joins the lines
+	joined, broken := false, false
+	defer func() { l.joined = joined && !broken }()
+
 	for {
 		switch l.char {
 		case ' ':
 			l.col++
-		case '\t': // assume tab characters take 4 spaces
-			l.col += 4
+			l.indentSpace = l.indentSpace || l.lineStart
+		case '\t':
+			l.col += uint(l.options.TabWidth)
+			l.indentTabs = l.indentTabs || l.lineStart
 		case '\n':
-			l.col = 1
-			l.line++
+			if l.options.EmitNewlines && !(l.options.CollapseNewlines && l.newlineRun) {
+				return
+			}
+			l.newline()
+			broken = true
+		case '\\':
+			if !l.hasPrefix("\\\n") && !l.hasPrefix("\\\r\n") {
+				l.checkIndentation()
+				return
+			}
+			l.readChar()
+			if l.char == '\r' {
+				l.readChar()
+			}
+			l.newline()
+			joined = true
 		case '\r':
-			l.col = 1
+			l.col = l.firstPos()
 		default:
+			l.checkIndentation()
 			return
 		}
 		l.readChar()
 	}
 }
 
+func (l *Lexer) newline() {
+	l.col = l.firstPos()
+	l.line++
+	l.lineStart, l.indentTabs, l.indentSpace = true, false, false
+}
+
+// called at the first token of a line, blank lines are not checked
+func (l *Lexer) checkIndentation() {
+	if l.options.CheckIndentation && l.lineStart && l.indentTabs && l.indentSpace {
+		l.warnings = append(l.warnings, Warning{
+			Loc:     token.SrcLoc{File: l.file, Line: l.line, Col: l.firstPos()},
+			Message: "indentation mixes tabs and spaces",
+		})
+	}
+
+	l.lineStart = false
+}
+
+// Returns the warnings found in the source read so far
+func (l *Lexer) Warnings() []Warning {
+	return l.warnings
+}
+
 func isAlpha(char byte) bool {
 	return 'a' <= char && char <= 'z' || 'A' <= char && char <= 'Z'
 }
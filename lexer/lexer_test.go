@@ -1,7 +1,12 @@
 package lexer
 
 import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"RoLang/token"
 )
@@ -128,3 +133,960 @@ if 5 < 10 {
 		}
 	}
 }
+
+func TestLogicalOperators(t *testing.T) {
+	input := "a && b || c and d or not e"
+
+	tests := []struct {
+		expectType token.TokenType
+		expectWord string
+	}{
+		{token.IDENT, "a"},
+		{token.AND, "&&"},
+		{token.IDENT, "b"},
+		{token.OR, "||"},
+		{token.IDENT, "c"},
+		{token.AND, "and"},
+		{token.IDENT, "d"},
+		{token.OR, "or"},
+		{token.BANG, "not"},
+		{token.IDENT, "e"},
+		{token.EOF, "eof"},
+	}
+
+	lexer := New("lexer_test", input)
+
+	for i, test := range tests {
+		tok := lexer.NextToken()
+
+		if tok.Type != test.expectType || tok.Word != test.expectWord {
+			t.Fatalf("Test[%d] - wrong token. expect=%d[%q], found=%d[%q]",
+				i, test.expectType, test.expectWord, tok.Type, tok.Word)
+		}
+	}
+}
+
+func TestAssignOperators(t *testing.T) {
+	input := "a[0] += 1; b -= c; d *= e / f; g /= h = i;"
+
+	tests := []token.TokenType{
+		token.IDENT, token.LBRACKET, token.INT, token.RBRACKET, token.PLUS_ASSIGN, token.INT, token.SEMCOL,
+		token.IDENT, token.MINUS_ASSIGN, token.IDENT, token.SEMCOL,
+		token.IDENT, token.STAR_ASSIGN, token.IDENT, token.SLASH, token.IDENT, token.SEMCOL,
+		token.IDENT, token.SLASH_ASSIGN, token.IDENT, token.ASSIGN, token.IDENT, token.SEMCOL,
+		token.EOF,
+	}
+
+	lexer := New("lexer_test", input)
+
+	for i, expectType := range tests {
+		tok := lexer.NextToken()
+
+		if tok.Type != expectType {
+			t.Fatalf("Test[%d] - wrong token type. expect=%d, found=%d[%q]",
+				i, expectType, tok.Type, tok.Word)
+		}
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	input := "`hello ${name}, you are ${ {\"}\"} }`; `a ${\"\\\"}\" + b} c`; `a ${b`"
+
+	tests := []struct {
+		expectType token.TokenType
+		expectWord string
+	}{
+		{token.TEMPLATE, `hello ${name}, you are ${ {"}"} }`},
+		{token.SEMCOL, ";"},
+		{token.TEMPLATE, `a ${"\"}" + b} c`},
+		{token.SEMCOL, ";"},
+		{token.ERR, "unterminated interpolation in template string"},
+		{token.EOF, "eof"},
+	}
+
+	lexer := New("lexer_test", input)
+
+	for i, test := range tests {
+		tok := lexer.NextToken()
+
+		if tok.Type != test.expectType || tok.Word != test.expectWord {
+			t.Fatalf("Test[%d] - wrong token. expect=%d[%q], found=%d[%q]",
+				i, test.expectType, test.expectWord, tok.Type, tok.Word)
+		}
+	}
+}
+
+func TestTokens(t *testing.T) {
+	tokens := New("lexer_test", "let x = 5 + y;").Tokens()
+
+	tests := []struct {
+		expectType token.TokenType
+		expectCol  uint
+	}{
+		{token.LET, 1},
+		{token.IDENT, 5},
+		{token.ASSIGN, 7},
+		{token.INT, 9},
+		{token.PLUS, 11},
+		{token.IDENT, 13},
+		{token.SEMCOL, 14},
+		{token.EOF, 15},
+	}
+
+	if len(tokens) != len(tests) {
+		t.Fatalf("wrong number of tokens. expect=%d, found=%d", len(tests), len(tokens))
+	}
+
+	for i, test := range tests {
+		tok := tokens[i]
+		expectLoc := token.SrcLoc{File: "lexer_test", Line: 1, Col: test.expectCol}
+
+		if tok.Type != test.expectType || tok.Loc != expectLoc {
+			t.Errorf("Test[%d] - wrong token. expect=%d at %s, found=%d[%q] at %s",
+				i, test.expectType, expectLoc, tok.Type, tok.Word, tok.Loc)
+		}
+	}
+}
+
+func TestBitwiseOperators(t *testing.T) {
+	input := "a << 2 >> b <= c >= d < e > f & g | ~h"
+
+	tests := []token.TokenType{
+		token.IDENT, token.SHL, token.INT, token.SHR, token.IDENT,
+		token.LE, token.IDENT, token.GE, token.IDENT, token.LT, token.IDENT, token.GT, token.IDENT,
+		token.AMP, token.IDENT, token.PIPE, token.TILDE, token.IDENT,
+		token.EOF,
+	}
+
+	lexer := New("lexer_test", input)
+
+	for i, expectType := range tests {
+		tok := lexer.NextToken()
+
+		if tok.Type != expectType {
+			t.Fatalf("Test[%d] - wrong token type. expect=%d, found=%d[%q]",
+				i, expectType, tok.Type, tok.Word)
+		}
+	}
+}
+
+func TestSpaceship(t *testing.T) {
+	input := "a <=> b <= c < d <=>= e <<=> f"
+
+	tests := []struct {
+		expectType token.TokenType
+		expectWord string
+	}{
+		{token.IDENT, "a"}, {token.SPACESHIP, "<=>"}, {token.IDENT, "b"},
+		{token.LE, "<="}, {token.IDENT, "c"}, {token.LT, "<"}, {token.IDENT, "d"},
+		{token.SPACESHIP, "<=>"}, {token.ASSIGN, "="}, {token.IDENT, "e"},
+		{token.SHL, "<<"}, {token.ARROW, "=>"}, {token.IDENT, "f"},
+		{token.EOF, "eof"},
+	}
+
+	lexer := New("lexer_test", input)
+
+	for i, test := range tests {
+		tok := lexer.NextToken()
+
+		if tok.Type != test.expectType || tok.Word != test.expectWord {
+			t.Fatalf("Test[%d] - wrong token. expect=%q, found=%q",
+				i, test.expectWord, tok.Word)
+		}
+	}
+
+	// the word may stop short of the third character
+	for _, input := range []string{"<=", "<"} {
+		tok := New("lexer_test", input).NextToken()
+		if tok.Word != input {
+			t.Errorf("wrong token for %q. got=%q", input, tok.Word)
+		}
+	}
+}
+
+func TestSplitOperators(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect []token.TokenType
+	}{
+		{"a == b", []token.TokenType{token.IDENT, token.EQ, token.IDENT}},
+		{"a = = b", []token.TokenType{token.IDENT, token.ASSIGN, token.ASSIGN, token.IDENT}},
+		{"a != b", []token.TokenType{token.IDENT, token.NE, token.IDENT}},
+		{"a ! = b", []token.TokenType{token.IDENT, token.BANG, token.ASSIGN, token.IDENT}},
+		{"a <= b", []token.TokenType{token.IDENT, token.LE, token.IDENT}},
+		{"a < = b", []token.TokenType{token.IDENT, token.LT, token.ASSIGN, token.IDENT}},
+		{"a >= b", []token.TokenType{token.IDENT, token.GE, token.IDENT}},
+		{"a > = b", []token.TokenType{token.IDENT, token.GT, token.ASSIGN, token.IDENT}},
+		// any whitespace keeps them apart, line breaks too
+		{"a !\t= b", []token.TokenType{token.IDENT, token.BANG, token.ASSIGN, token.IDENT}},
+		{"a <\n= b", []token.TokenType{token.IDENT, token.LT, token.ASSIGN, token.IDENT}},
+		{"a <=\n> b", []token.TokenType{token.IDENT, token.LE, token.GT, token.IDENT}},
+	}
+
+	for _, test := range tests {
+		l := New("lexer_test", test.input)
+
+		for i, expectType := range append(test.expect, token.EOF) {
+			tok := l.NextToken()
+
+			if tok.Type != expectType {
+				t.Errorf("%q: tests[%d] - wrong token type. expect=%s, got=%s %q",
+					test.input, i, token.TokenString[expectType], token.TokenString[tok.Type], tok.Word)
+				break
+			}
+		}
+	}
+}
+
+func TestStringColumns(t *testing.T) {
+	// the quotes count towards the columns of the tokens after a string
+	input := `"ab" + "" + "a\n" x;`
+
+	tests := []struct {
+		expectType token.TokenType
+		expectCol  uint
+	}{
+		{token.STRING, 1},
+		{token.PLUS, 6},
+		{token.STRING, 8},
+		{token.PLUS, 11},
+		{token.STRING, 13},
+		{token.IDENT, 19},
+		{token.SEMCOL, 20},
+		{token.EOF, 21},
+	}
+
+	lexer := New("lexer_test", input)
+
+	for i, test := range tests {
+		tok := lexer.NextToken()
+
+		if tok.Type != test.expectType || tok.Loc.Col != test.expectCol {
+			t.Errorf("Test[%d] - wrong token. expect=%s at %d, found=%s[%q] at %d",
+				i, test.expectType, test.expectCol, tok.Type, tok.Word, tok.Loc.Col)
+		}
+	}
+}
+
+func TestNewAt(t *testing.T) {
+	loc := token.SrcLoc{File: "lexer_test", Line: 3, Col: 6}
+	lexer := NewAt(loc, "a\tb\nc", Options{ZeroBased: true, TabWidth: 4})
+
+	expects := []token.SrcLoc{
+		{File: "lexer_test", Line: 3, Col: 6},
+		{File: "lexer_test", Line: 3, Col: 11},
+		{File: "lexer_test", Line: 4, Col: 0},
+	}
+
+	for i, expect := range expects {
+		if tok := lexer.NextToken(); tok.Loc != expect {
+			t.Errorf("Test[%d] - wrong location for %q. expect=%s, found=%s", i, tok.Word, expect, tok.Loc)
+		}
+	}
+}
+
+func TestRawString(t *testing.T) {
+	input := "let s = \"\"\"a \"quoted\" \\n\nline\"\"\"; x\n\"\"\"open\n"
+
+	tests := []struct {
+		expectType token.TokenType
+		expectWord string
+		expectLine uint
+		expectCol  uint
+	}{
+		{token.LET, "let", 1, 1},
+		{token.IDENT, "s", 1, 5},
+		{token.ASSIGN, "=", 1, 7},
+		{token.STRING, "a \"quoted\" \\n\nline", 1, 9},
+		{token.SEMCOL, ";", 2, 8},
+		{token.IDENT, "x", 2, 10},
+		{token.ERR, "unterminated raw string", 3, 1},
+		{token.EOF, "eof", 4, 1},
+	}
+
+	lexer := New("lexer_test", input)
+
+	for i, test := range tests {
+		tok := lexer.NextToken()
+
+		if tok.Type != test.expectType || tok.Word != test.expectWord {
+			t.Fatalf("Test[%d] - wrong token. expect=%d[%q], found=%d[%q]",
+				i, test.expectType, test.expectWord, tok.Type, tok.Word)
+		}
+
+		if tok.Loc.Line != test.expectLine || tok.Loc.Col != test.expectCol {
+			t.Errorf("Test[%d] - wrong location. expect=%d:%d, found=%d:%d",
+				i, test.expectLine, test.expectCol, tok.Loc.Line, tok.Loc.Col)
+		}
+
+		if raw := test.expectType == token.STRING; tok.Raw != raw {
+			t.Errorf("Test[%d] - wrong raw flag. expect=%t, found=%t", i, raw, tok.Raw)
+		}
+	}
+}
+
+func TestTabWidth(t *testing.T) {
+	input := "\tlet x = `\t${y}`;"
+
+	tests := []struct {
+		tabWidth int
+		expect   []uint
+	}{
+		{0, []uint{2, 6, 8, 10, 17}},
+		{4, []uint{5, 9, 11, 13, 23}},
+	}
+
+	for _, test := range tests {
+		lexer := NewWithOptions("lexer_test", input, Options{TabWidth: test.tabWidth})
+
+		for i, expectCol := range test.expect {
+			tok := lexer.NextToken()
+
+			if tok.Loc.Col != expectCol {
+				t.Errorf("TabWidth %d, token %d %q - wrong column. expect=%d, found=%d",
+					test.tabWidth, i, tok.Word, expectCol, tok.Loc.Col)
+			}
+		}
+	}
+}
+
+func TestZeroBased(t *testing.T) {
+	input := "let x\n  = 5;"
+
+	oneBased := New("lexer_test", input).Tokens()
+	zeroBased := NewWithOptions("lexer_test", input, Options{ZeroBased: true}).Tokens()
+
+	for i := range oneBased {
+		one, zero := oneBased[i].Loc, zeroBased[i].Loc
+
+		if one.Line != zero.Line+1 || one.Col != zero.Col+1 {
+			t.Errorf("token %d %q - positions do not differ by one. one based=%d:%d, zero based=%d:%d",
+				i, oneBased[i].Word, one.Line, one.Col, zero.Line, zero.Col)
+		}
+	}
+
+	if loc := zeroBased[0].Loc; loc.Line != 0 || loc.Col != 0 {
+		t.Errorf("first token not at 0:0. got=%d:%d", loc.Line, loc.Col)
+	}
+}
+
+func TestNumberExponents(t *testing.T) {
+	input := "1e-3 2E+4 3e5 4.5e1 6e 7e-x 8--9 2**3"
+
+	tests := []struct {
+		expectType token.TokenType
+		expectWord string
+	}{
+		{token.FLOAT, "1e-3"},
+		{token.FLOAT, "2E+4"},
+		{token.FLOAT, "3e5"},
+		{token.FLOAT, "4.5e1"},
+		// without digits the 'e' is a name of its own
+		{token.INT, "6"},
+		{token.IDENT, "e"},
+		{token.INT, "7"},
+		{token.IDENT, "e"},
+		{token.MINUS, "-"},
+		{token.IDENT, "x"},
+		// there is no decrement, `--` is two minuses
+		{token.INT, "8"},
+		{token.MINUS, "-"},
+		{token.MINUS, "-"},
+		{token.INT, "9"},
+		{token.INT, "2"},
+		{token.POW, "**"},
+		{token.INT, "3"},
+		{token.EOF, "eof"},
+	}
+
+	l := New("lexer_test", input)
+
+	for i, test := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != test.expectType || tok.Word != test.expectWord {
+			t.Errorf("tests[%d] - wrong token. expect=%s %q, got=%s %q",
+				i, token.TokenString[test.expectType], test.expectWord, token.TokenString[tok.Type], tok.Word)
+		}
+	}
+}
+
+func TestNumberSuffixes(t *testing.T) {
+	input := "5i 5f 1.5f 2e3f 5.0i 1e2i 7 5if x 5ix"
+
+	tests := []struct {
+		expectType token.TokenType
+		expectWord string
+		expectCol  uint
+	}{
+		{token.INT, "5", 1},
+		{token.FLOAT, "5", 4},
+		{token.FLOAT, "1.5", 7},
+		{token.FLOAT, "2e3", 12},
+		// an integer suffix on a float is an error
+		{token.ERR, "float literal 5.0i cannot take the integer suffix", 17},
+		{token.ERR, "float literal 1e2i cannot take the integer suffix", 22},
+		{token.INT, "7", 27},
+		// a name after the letter keeps it from being a suffix
+		{token.INT, "5", 29},
+		{token.IF, "if", 30},
+		{token.IDENT, "x", 33},
+		{token.INT, "5", 35},
+		{token.IDENT, "ix", 36},
+		{token.EOF, "eof", 38},
+	}
+
+	l := New("lexer_test", input)
+
+	for i, test := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != test.expectType || tok.Word != test.expectWord || tok.Loc.Col != test.expectCol {
+			t.Errorf("tests[%d] - wrong token. expect=%s %q at %d, got=%s %q at %d",
+				i, token.TokenString[test.expectType], test.expectWord, test.expectCol,
+				token.TokenString[tok.Type], tok.Word, tok.Loc.Col)
+		}
+	}
+}
+
+func TestRanges(t *testing.T) {
+	input := "0..10 1..=n 1.5..2 a.b ...xs x => y"
+
+	tests := []struct {
+		expectType token.TokenType
+		expectWord string
+	}{
+		{token.INT, "0"},
+		{token.DOTDOT, ".."},
+		{token.INT, "10"},
+		{token.INT, "1"},
+		{token.DOTDOTEQ, "..="},
+		{token.IDENT, "n"},
+		{token.FLOAT, "1.5"},
+		{token.DOTDOT, ".."},
+		{token.INT, "2"},
+		{token.IDENT, "a"},
+		{token.DOT, "."},
+		{token.IDENT, "b"},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "xs"},
+		{token.IDENT, "x"},
+		{token.ARROW, "=>"},
+		{token.IDENT, "y"},
+		{token.EOF, "eof"},
+	}
+
+	l := New("lexer_test", input)
+
+	for i, test := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != test.expectType || tok.Word != test.expectWord {
+			t.Errorf("tests[%d] - wrong token. expect=%s %q, got=%s %q",
+				i, token.TokenString[test.expectType], test.expectWord, token.TokenString[tok.Type], tok.Word)
+		}
+	}
+}
+
+func TestFoldKeywords(t *testing.T) {
+	input := "LET Let let Lettuce And NOT"
+
+	tests := []struct {
+		options Options
+		expect  []token.TokenType
+		words   []string
+	}{
+		{Options{}, []token.TokenType{token.IDENT, token.IDENT, token.LET, token.IDENT, token.IDENT, token.IDENT},
+			[]string{"LET", "Let", "let", "Lettuce", "And", "NOT"}},
+		{Options{FoldKeywords: true}, []token.TokenType{token.LET, token.LET, token.LET, token.IDENT, token.AND, token.BANG},
+			[]string{"let", "let", "let", "Lettuce", "and", "not"}},
+	}
+
+	for _, test := range tests {
+		l := NewWithOptions("lexer_test", input, test.options)
+
+		for i, expect := range test.expect {
+			tok := l.NextToken()
+
+			if tok.Type != expect || tok.Word != test.words[i] {
+				t.Errorf("FoldKeywords %t, token %d - wrong token. expect=%s %q, got=%s %q",
+					test.options.FoldKeywords, i, token.TokenString[expect], test.words[i],
+					token.TokenString[tok.Type], tok.Word)
+			}
+		}
+	}
+}
+
+func TestKeywords(t *testing.T) {
+	// a token type of the language variant, past the default ones
+	loop := token.TOTAL
+
+	input := "loop { until x; } while Loop"
+	extra := map[string]token.TokenType{
+		"loop":  loop,
+		"until": token.IF,
+		"while": token.IDENT,
+	}
+
+	tests := []struct {
+		l      *Lexer
+		expect []token.TokenType
+	}{
+		{New("lexer_test", input), []token.TokenType{
+			token.IDENT, token.LBRACE, token.IDENT, token.IDENT, token.SEMCOL, token.RBRACE, token.WHILE, token.IDENT}},
+		{NewWithKeywords("lexer_test", input, extra), []token.TokenType{
+			loop, token.LBRACE, token.IF, token.IDENT, token.SEMCOL, token.RBRACE, token.IDENT, token.IDENT}},
+		// folding goes through the extra keywords too
+		{NewWithOptions("lexer_test", input, Options{Keywords: extra, FoldKeywords: true}), []token.TokenType{
+			loop, token.LBRACE, token.IF, token.IDENT, token.SEMCOL, token.RBRACE, token.IDENT, loop}},
+	}
+
+	for i, test := range tests {
+		for j, expect := range test.expect {
+			if tok := test.l.NextToken(); tok.Type != expect {
+				t.Errorf("test[%d] token %d - wrong type for %q. expect=%s, got=%s", i, j, tok.Word, expect, tok.Type)
+			}
+		}
+	}
+}
+
+func TestTrivia(t *testing.T) {
+	input := "let  s =\t\"a\\tb\";\r\n\n  x \\\n+ 1; \n"
+
+	expect := []struct {
+		trivia []string
+		source string
+	}{
+		{nil, "let"},
+		{[]string{"  "}, "s"},
+		{[]string{" "}, "="},
+		{[]string{"\t"}, `"a\tb"`},
+		{nil, ";"},
+		{[]string{"\r\n", "\n", "  "}, "x"},
+		{[]string{" ", "\\\n"}, "+"},
+		{[]string{" "}, "1"},
+		{nil, ";"},
+		{[]string{" ", "\n"}, ""},
+	}
+
+	l := NewWithOptions("lexer_test", input, Options{Trivia: true})
+	for i, expect := range expect {
+		tok := l.NextToken()
+
+		if !reflect.DeepEqual(tok.Trivia, expect.trivia) || tok.Source != expect.source {
+			t.Errorf("token %d - wrong trivia. expect=%q %q, got=%q %q", i, expect.trivia, expect.source, tok.Trivia, tok.Source)
+		}
+	}
+
+	// nothing is kept by default
+	if tok := New("lexer_test", input).NextToken(); tok.Trivia != nil || tok.Source != "" {
+		t.Errorf("trivia kept without the option. got=%q %q", tok.Trivia, tok.Source)
+	}
+}
+
+func TestCheckIndentation(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect []string
+	}{
+		{"if x {\n\t y;\n}", []string{"lexer_test:2:1: indentation mixes tabs and spaces"}},
+		{"if x {\n  \ty;\n\t\tz;\n}", []string{"lexer_test:2:1: indentation mixes tabs and spaces"}},
+		{"if x {\n\ty;\n\t\tz;\n}", []string{}},
+		{"if x {\n    y;\n}", []string{}},
+		// blank lines and whitespace after the first token are fine
+		{"if x {\n \t\n\ty = \t 1;\n}", []string{}},
+		{"\"\"\"a\n\t b\"\"\";", []string{}},
+	}
+
+	for _, test := range tests {
+		l := NewWithOptions("lexer_test", test.input, Options{CheckIndentation: true})
+		l.Tokens()
+
+		warnings := l.Warnings()
+		if len(warnings) != len(test.expect) {
+			t.Errorf("%q - wrong number of warnings. expect=%d, got=%d: %v",
+				test.input, len(test.expect), len(warnings), warnings)
+			continue
+		}
+
+		for i, warning := range warnings {
+			if warning.String() != test.expect[i] {
+				t.Errorf("%q - wrong warning. expect=%q, got=%q", test.input, test.expect[i], warning)
+			}
+		}
+	}
+
+	// the check is off by default
+	l := New("lexer_test", "if x {\n\t y;\n}")
+	l.Tokens()
+	if n := len(l.Warnings()); n != 0 {
+		t.Errorf("expected no warnings without CheckIndentation. got=%d", n)
+	}
+}
+
+func TestEmitNewlines(t *testing.T) {
+	input := "let a = 1;\n\n  let b = 2;\nlet c = 3;\n"
+
+	tests := []struct {
+		options Options
+		expect  []token.SrcLoc
+	}{
+		{Options{}, []token.SrcLoc{}},
+		{Options{EmitNewlines: true}, []token.SrcLoc{
+			{File: "lexer_test", Line: 1, Col: 11},
+			{File: "lexer_test", Line: 2, Col: 1},
+			{File: "lexer_test", Line: 3, Col: 13},
+			{File: "lexer_test", Line: 4, Col: 11},
+		}},
+		// the blank line joins the break before it
+		{Options{EmitNewlines: true, CollapseNewlines: true}, []token.SrcLoc{
+			{File: "lexer_test", Line: 1, Col: 11},
+			{File: "lexer_test", Line: 3, Col: 13},
+			{File: "lexer_test", Line: 4, Col: 11},
+		}},
+	}
+
+	for _, test := range tests {
+		newlines := []token.SrcLoc{}
+		tokens := NewWithOptions("lexer_test", input, test.options).Tokens()
+
+		for _, tok := range tokens {
+			if tok.Type == token.NEWLINE {
+				newlines = append(newlines, tok.Loc)
+			}
+		}
+
+		if len(newlines) != len(test.expect) {
+			t.Errorf("%+v - wrong number of newlines. expect=%d, got=%d", test.options, len(test.expect), len(newlines))
+			continue
+		}
+
+		for i, loc := range newlines {
+			if loc != test.expect[i] {
+				t.Errorf("%+v - newline %d at the wrong location. expect=%s, got=%s", test.options, i, test.expect[i], loc)
+			}
+		}
+
+		// the other tokens are where they are without the option
+		var last token.Token
+		for _, tok := range tokens {
+			if tok.Type == token.SEMCOL {
+				last = tok
+			}
+		}
+		if last.Loc.Line != 4 || last.Loc.Col != 10 {
+			t.Errorf("%+v - last ';' at the wrong location. got=%s", test.options, last.Loc)
+		}
+	}
+}
+
+func TestLineContinuation(t *testing.T) {
+	input := "a +\\\nb;\nc \\\r\n\n+ d"
+
+	expects := []struct {
+		expectType   token.TokenType
+		expectWord   string
+		expectLine   uint
+		expectJoined bool
+	}{
+		{token.IDENT, "a", 1, false},
+		{token.PLUS, "+", 1, false},
+		{token.IDENT, "b", 2, true},
+		{token.SEMCOL, ";", 2, false},
+		{token.IDENT, "c", 3, false},
+		// an unescaped break after the continuation still ends the line
+		{token.PLUS, "+", 5, false},
+		{token.IDENT, "d", 5, false},
+		{token.EOF, "eof", 5, false},
+	}
+
+	l := NewWithOptions("lexer_test", input, Options{EmitNewlines: true})
+
+	for i, expect := range expects {
+		tok := l.NextToken()
+		if tok.Type == token.NEWLINE {
+			if i != 4 && i != 5 {
+				t.Fatalf("expects[%d] - unexpected newline at %s", i, tok.Loc)
+			}
+			tok = l.NextToken()
+		}
+
+		if tok.Type != expect.expectType || tok.Word != expect.expectWord {
+			t.Fatalf("expects[%d] - wrong token. expect=%q, got=%q", i, expect.expectWord, tok.Word)
+		}
+		if tok.Loc.Line != expect.expectLine {
+			t.Errorf("expects[%d] - wrong line. expect=%d, got=%d", i, expect.expectLine, tok.Loc.Line)
+		}
+		if tok.Joined != expect.expectJoined {
+			t.Errorf("expects[%d] - wrong joined. expect=%t, got=%t", i, expect.expectJoined, tok.Joined)
+		}
+	}
+
+	// a backslash elsewhere is still an error
+	if tok := New("lexer_test", "a \\ b").Tokens()[1]; tok.Type != token.ERR {
+		t.Errorf("expected an error for a lone backslash. got=%q", tok.Word)
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	input := "#[inline] @cfg(A) # x"
+
+	tests := []struct {
+		expectType token.TokenType
+		expectWord string
+	}{
+		{token.HASH, "#["},
+		{token.IDENT, "inline"},
+		{token.RBRACKET, "]"},
+		{token.AT, "@"},
+		{token.IDENT, "cfg"},
+		{token.LPAREN, "("},
+		{token.IDENT, "A"},
+		{token.RPAREN, ")"},
+		{token.ERR, "Unknown token #"},
+		{token.IDENT, "x"},
+		{token.EOF, "eof"},
+	}
+
+	lexer := New("lexer_test", input)
+
+	for i, test := range tests {
+		tok := lexer.NextToken()
+
+		if tok.Type != test.expectType || tok.Word != test.expectWord {
+			t.Fatalf("Test[%d] - wrong token. expect=%d[%q], found=%d[%q]",
+				i, test.expectType, test.expectWord, tok.Type, tok.Word)
+		}
+	}
+}
+
+func TestUnknownCharacters(t *testing.T) {
+	input := "a # b\x00c\x01 @ é"
+
+	tests := []struct {
+		expectType token.TokenType
+		expectWord string
+		expectCol  uint
+	}{
+		{token.IDENT, "a", 1},
+		{token.ERR, "Unknown token #", 3},
+		{token.IDENT, "b", 5},
+		// a NUL byte does not end the input
+		{token.ERR, `Unknown token \x00`, 6},
+		{token.IDENT, "c", 7},
+		{token.ERR, `Unknown token \x01`, 8},
+		// '@' starts an attribute
+		{token.AT, "@", 10},
+		// each byte of a UTF-8 sequence is reported
+		{token.ERR, `Unknown token \xc3`, 12},
+		{token.ERR, `Unknown token \xa9`, 13},
+		{token.EOF, "eof", 14},
+	}
+
+	l := New("lexer_test", input)
+
+	for i, test := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != test.expectType || tok.Word != test.expectWord {
+			t.Fatalf("Test[%d] - wrong token. expect=%s[%q], found=%s[%q]",
+				i, test.expectType, test.expectWord, tok.Type, tok.Word)
+		}
+		if tok.Loc.Col != test.expectCol {
+			t.Errorf("Test[%d] - wrong column. expect=%d, got=%d", i, test.expectCol, tok.Loc.Col)
+		}
+	}
+
+	// reading past the end keeps giving EOF, at the same place
+	for i := 0; i < 3; i++ {
+		if tok := l.NextToken(); tok.Type != token.EOF || tok.Loc.Col != 14 {
+			t.Fatalf("expected EOF at 1:14 after the end. got=%s[%q] at %s", tok.Type, tok.Word, tok.Loc)
+		}
+	}
+}
+
+func FuzzLexer(f *testing.F) {
+	for _, seed := range []string{
+		"let x = 1;", "@", "#", "\x00", "\"unterminated", `"""raw`, "`${`", "`${\"}`",
+		"1e", "1.e+", "a..=b", "\\", "\\\r\n", "\r\n\t", "é",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		l := NewWithOptions("lexer_fuzz", input, Options{EmitNewlines: true, CheckIndentation: true})
+
+		// every token but the last takes up at least one byte
+		for i := 0; ; i++ {
+			if i > len(input) {
+				t.Fatalf("more tokens than bytes in %q", input)
+			}
+			if tok := l.NextToken(); tok.Type == token.EOF {
+				break
+			}
+		}
+	})
+}
+
+// hands out the input a few bytes at a time, so tokens
+// and runes are split across reads
+type chunkReader struct {
+	input string
+	size  int
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if r.input == "" {
+		return 0, io.EOF
+	}
+
+	n := copy(p[:min(len(p), r.size)], r.input)
+	r.input = r.input[n:]
+	return n, nil
+}
+
+func TestNewReader(t *testing.T) {
+	piece := "let s = \"héllo wörld\"; # x\n" +
+		"let t = `a ${b + \"}\"} ✓`;\r\n" +
+		"let raw = \"\"\"one\ntwo\"\"\";\n" +
+		"\tlet n = 1.5e-3 + 10 .. 20 ..= 1e;\\\n" +
+		"a => b ** c <<= d >> 2;\n"
+	input := strings.Repeat(piece, 2000)
+
+	for _, size := range []int{1, 7, 4096, len(input)} {
+		options := Options{TabWidth: 4, EmitNewlines: true, Trivia: true}
+		expect := NewWithOptions("lexer_test", input, options).Tokens()
+		found := NewReaderWithOptions("lexer_test", &chunkReader{input, size}, options).Tokens()
+
+		if len(found) != len(expect) {
+			t.Fatalf("reads of %d - wrong number of tokens. expect=%d, got=%d", size, len(expect), len(found))
+		}
+
+		for i := range expect {
+			if !reflect.DeepEqual(found[i], expect[i]) {
+				t.Fatalf("reads of %d - token %d differs. expect=%+v, got=%+v", size, i, expect[i], found[i])
+			}
+		}
+	}
+
+	// only about a chunk is held at a time
+	l := NewReader("lexer_test", strings.NewReader(input))
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if len(l.input) > 2*chunkSize {
+			t.Fatalf("lexer holds %d bytes of input", len(l.input))
+		}
+	}
+
+	// a failing reader ends the input with an error
+	r := io.MultiReader(strings.NewReader("let x"), iotest.ErrReader(errors.New("disk gone")))
+	tokens := NewReader("lexer_test", r).Tokens()
+
+	expect := []string{"let", "x", "reading lexer_test: disk gone", "eof"}
+	if len(tokens) != len(expect) {
+		t.Fatalf("wrong number of tokens. got=%v", tokens)
+	}
+	for i, tok := range tokens {
+		if tok.Word != expect[i] {
+			t.Errorf("token %d wrong. expect=%q, got=%q", i, expect[i], tok.Word)
+		}
+	}
+	if tokens[2].Type != token.ERR {
+		t.Errorf("read error not an ERR token. got=%s", tokens[2].Type)
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	input := `"smile \u{1F600}" "\xFF\x41" "a\tb\n\"c\" \\ \0" "\u{e9}t\u{E9}"
+"bad \u{110000} x" "\xG1" "\q" "\u{D800}" "\u{}" "\u41" "é\u{1F600}é" after`
+
+	tests := []struct {
+		expectType token.TokenType
+		expectWord string
+		expectCol  uint
+	}{
+		{token.STRING, "smile 😀", 1},
+		{token.STRING, "ÿA", 19},
+		{token.STRING, "a\tb\n\"c\" \\ \x00", 30},
+		{token.STRING, "été", 50},
+		{token.ERR, "escape \\u{110000} is not a valid code point", 6},
+		{token.ERR, "invalid escape \\x, expected two hex digits", 21},
+		{token.ERR, "unknown escape \\q", 28},
+		{token.ERR, "escape \\u{D800} is not a valid code point", 33},
+		{token.ERR, "invalid escape \\u{...}, expected one to six hex digits", 44},
+		{token.ERR, "invalid escape \\u, expected '{'", 51},
+		// columns count bytes
+		{token.STRING, "é😀é", 57},
+		{token.IDENT, "after", 73},
+		{token.EOF, "eof", 78},
+	}
+
+	l := New("lexer_test", input)
+
+	for i, test := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != test.expectType || tok.Word != test.expectWord {
+			t.Fatalf("Test[%d] - wrong token. expect=%s[%q], found=%s[%q]",
+				i, test.expectType, test.expectWord, tok.Type, tok.Word)
+		}
+		if tok.Loc.Col != test.expectCol {
+			t.Errorf("Test[%d] - wrong column. expect=%d, got=%d", i, test.expectCol, tok.Loc.Col)
+		}
+	}
+}
+
+// the lexer scans bytes, text outside ASCII only ever appears inside
+// strings and templates, which take it as it is, so it lexes the same
+// as ASCII of as many bytes would
+func TestNonASCII(t *testing.T) {
+	mixed := "let s = \"héllo ✓\";\nlet t = `${s} wörld`; let u = \"\"\"ü\n\"\"\";"
+	ascii := "let s = \"hello abcd\";\nlet t = `${s} woorld`; let u = \"\"\"uu\n\"\"\";"
+	if len(mixed) != len(ascii) {
+		t.Fatalf("inputs differ in length. %d != %d", len(mixed), len(ascii))
+	}
+
+	expect := New("lexer_test", ascii).Tokens()
+	found := New("lexer_test", mixed).Tokens()
+	if len(found) != len(expect) {
+		t.Fatalf("wrong number of tokens. expect=%d, got=%d", len(expect), len(found))
+	}
+
+	for i := range expect {
+		if found[i].Type != expect[i].Type || found[i].Loc != expect[i].Loc {
+			t.Errorf("token %d differs. expect=%s at %s, got=%s at %s",
+				i, expect[i].Type, expect[i].Loc, found[i].Type, found[i].Loc)
+		}
+	}
+}
+
+func benchInput(line string) string {
+	return strings.Repeat(line, 2000)
+}
+
+func benchLex(input string) {
+	l := New("lexer_bench", input)
+	for l.NextToken().Type != token.EOF {
+	}
+}
+
+func BenchmarkLexerASCII(b *testing.B) {
+	input := benchInput("let greeting = \"hello\" + name;\nfn f(x) { return x * 2 >= 10 and x != 3; }\n")
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		benchLex(input)
+	}
+}
+
+func BenchmarkLexerMixed(b *testing.B) {
+	input := benchInput("let greeting = \"héllo wörld ✓\" + name;\nfn f(x) { return `${x} × 2` >= 10; }\n")
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		benchLex(input)
+	}
+}
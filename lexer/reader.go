@@ -0,0 +1,67 @@
+package lexer
+
+import (
+	"io"
+	"strings"
+)
+
+// bytes asked of the reader at a time
+const chunkSize = 4096
+
+// Creates a lexer reading the source from r as the tokens need it,
+// so only the current token and a chunk after it are held in memory.
+// An error of r other than io.EOF is produced as an ERR token
+// before the EOF.
+func NewReader(file string, r io.Reader) *Lexer {
+	return NewReaderWithOptions(file, r, Options{})
+}
+
+func NewReaderWithOptions(file string, r io.Reader, options Options) *Lexer {
+	l := newLexer(file, "", options)
+	l.reader = r
+	l.stream = true
+
+	// Read the first char to set the state
+	l.readChar()
+	return l
+}
+
+// reads from the reader until the input holds end bytes
+// or the reader has no more
+func (l *Lexer) fill(end uint) {
+	for l.reader != nil && uint(len(l.input)) < end {
+		if l.buf == nil {
+			l.buf = make([]byte, chunkSize)
+		}
+
+		n, err := l.reader.Read(l.buf)
+		l.input += string(l.buf[:n])
+
+		if err != nil {
+			if err != io.EOF {
+				l.readErr = err
+			}
+			l.reader = nil
+		}
+	}
+}
+
+// drops the input before the current character when streaming,
+// called between tokens so no token is in the middle of being read
+func (l *Lexer) compact() {
+	if l.stream && l.offset > 1 && l.offset-1 <= uint(len(l.input)) {
+		l.input = l.input[l.offset-1:]
+		l.offset = 1
+	}
+}
+
+// the input from start up to the current character, copied when
+// streaming so the token does not keep the whole chunk alive
+func (l *Lexer) slice(start uint) string {
+	word := l.input[start : l.offset-1]
+	if l.stream {
+		word = strings.Clone(word)
+	}
+
+	return word
+}
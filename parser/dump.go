@@ -0,0 +1,285 @@
+package parser
+
+import (
+	"RoLang/ast"
+
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Renders the tree as an S-expression, `let x = 5;` gives (let x (int 5)).
+// Every statement of a block goes on a line of its own indented under
+// it, everything else stays on one line, so a change to the tree shows
+// up as a change to the lines holding it. Missing children print as nil.
+func DumpAST(program *ast.Program) string {
+	var out strings.Builder
+	dump(program).write(&out, 0)
+
+	return out.String()
+}
+
+// an atom when list is nil, a block puts its items on lines of their own
+type sexp struct {
+	atom  string
+	list  []sexp
+	block bool
+}
+
+func atom(s string) sexp {
+	return sexp{atom: s}
+}
+
+func list(head string, items ...sexp) sexp {
+	return sexp{list: append([]sexp{atom(head)}, items...)}
+}
+
+// whether the expression or one inside it spans several lines
+func (s sexp) breaks() bool {
+	if s.block && len(s.list) > 1 {
+		return true
+	}
+	for _, item := range s.list {
+		if item.breaks() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// items before the first one spanning lines stay on the line of the
+// head, the ones from there on go on lines of their own
+func (s sexp) write(out *strings.Builder, indent int) {
+	if s.list == nil {
+		out.WriteString(s.atom)
+		return
+	}
+
+	out.WriteByte('(')
+	broken := false
+	for i, item := range s.list {
+		if i > 0 {
+			if s.block || broken || item.breaks() {
+				broken = true
+				out.WriteByte('\n')
+				out.WriteString(strings.Repeat("  ", indent+1))
+			} else {
+				out.WriteByte(' ')
+			}
+		}
+		item.write(out, indent+1)
+	}
+	out.WriteByte(')')
+}
+
+func dumpAll[T ast.Node](nodes []T) []sexp {
+	items := make([]sexp, len(nodes))
+	for i, node := range nodes {
+		items[i] = dump(node)
+	}
+
+	return items
+}
+
+// the optional children that are there
+func dumpSome(nodes ...ast.Node) []sexp {
+	var items []sexp
+	for _, node := range nodes {
+		if !isNil(node) {
+			items = append(items, dump(node))
+		}
+	}
+
+	return items
+}
+
+func dumpLabel(label *ast.Identifier) []sexp {
+	if label == nil {
+		return nil
+	}
+
+	return []sexp{list("label", dump(label))}
+}
+
+func dumpBlock(head string, block *ast.BlockStatement) sexp {
+	s := list(head, dumpLabel(block.Label)...)
+	s.list = append(s.list, dumpAll(block.Statements)...)
+	s.block = true
+
+	return s
+}
+
+func dumpFunction(head string, name *ast.Identifier, fn *ast.FunctionLiteral) sexp {
+	params := list("params")
+	for i, param := range fn.Parameters {
+		item := dump(param.Ident)
+		if fn.Variadic && i == len(fn.Parameters)-1 {
+			item = list("rest", item)
+		}
+		if param.Default != nil {
+			item = list("default", item, dump(param.Default))
+		}
+		params.list = append(params.list, item)
+	}
+
+	if name == nil {
+		return list(head, params, dump(fn.Body))
+	}
+	return list(head, dump(name), params, dump(fn.Body))
+}
+
+func dump(node ast.Node) sexp {
+	if isNil(node) {
+		return atom("nil")
+	}
+
+	switch n := node.(type) {
+	case *ast.Program:
+		s := list("program", dumpAll(n.Statements)...)
+		s.block = true
+		return s
+	case *ast.BlockStatement:
+		return dumpBlock("block", n)
+	case *ast.WhileStatement:
+		items := append(dumpLabel(n.Label), dump(n.Condition), dump(n.Body))
+		return list("while", items...)
+	case *ast.ForInStatement:
+		vars := list("vars", dumpSome(n.IndexVar, n.Var)...)
+		items := append(dumpLabel(n.Label), vars, dump(n.Iterable), dump(n.Body))
+		return list("for", items...)
+	case *ast.DoWhileStatement:
+		items := append(dumpLabel(n.Label), dump(n.Body), dump(n.Condition))
+		return list("do-while", items...)
+	case *ast.BreakStatement:
+		return list("break", dumpSome(n.Label)...)
+	case *ast.ContinueStatement:
+		return list("continue", dumpSome(n.Label)...)
+	case *ast.FunctionStatement:
+		if n.Value == nil {
+			return list("fn", dump(n.Ident), atom("nil"))
+		}
+		return dumpFunction("fn", n.Ident, n.Value)
+	case *ast.FunctionGroup:
+		return list("fn-group", append([]sexp{dump(n.Ident)}, dumpAll(n.Variants)...)...)
+	case *ast.LetStatement:
+		head := "let"
+		if n.IsConst {
+			head = "const"
+		}
+		return list(head, append([]sexp{dump(n.Ident)}, dumpSome(n.InitValue)...)...)
+	case *ast.ReturnStatement:
+		if len(n.ReturnValues) == 0 && n.ReturnValue != nil {
+			return list("return", dump(n.ReturnValue))
+		}
+		return list("return", dumpAll(n.ReturnValues)...)
+	case *ast.AssertStatement:
+		return list("assert", append([]sexp{dump(n.Condition)}, dumpSome(n.Message)...)...)
+	case *ast.DeferStatement:
+		return list("defer", dump(n.Call))
+	case *ast.PrintStatement:
+		head := "print"
+		if n.Newline {
+			head = "println"
+		}
+		return list(head, dumpAll(n.Values)...)
+	case *ast.ImportStatement:
+		return list("import", append([]sexp{dump(n.Path)}, dumpSome(n.Alias)...)...)
+	case *ast.ExpressionStatement:
+		return list("expr", dump(n.Expression))
+	case *ast.AttributedStatement:
+		var items []sexp
+		for _, attr := range n.Attributes {
+			items = append(items, list("attr", append([]sexp{dump(attr.Name)}, dumpAll(attr.Args)...)...))
+		}
+		return list("attributed", append(items, dump(n.Stmt))...)
+	case *ast.IfStatement:
+		condition := dump(n.Condition)
+		if n.Binding != nil {
+			condition = dump(n.Binding)
+		}
+		return list("if", append([]sexp{condition, dump(n.Then)}, dumpSome(n.Else)...)...)
+	case *ast.IfExpression:
+		return list("if", dump(n.Condition), dump(n.Then), dump(n.Else))
+	case *ast.PrefixExpression:
+		return list("prefix", atom(n.Operator), dump(n.Right))
+	case *ast.InfixExpression:
+		return list("infix", atom(n.Operator), dump(n.Left), dump(n.Right))
+	case *ast.SequenceExpression:
+		return list("sequence", dumpAll(n.Expressions)...)
+	case *ast.GroupExpression:
+		return list("group", dump(n.Inner))
+	case *ast.BetweenExpression:
+		return list("between", dump(n.Value), dump(n.Low), dump(n.High))
+	case *ast.TypeTestExpression:
+		head := "is"
+		if n.Negated {
+			head = "is-not"
+		}
+		return list(head, dump(n.Value), dump(n.Type))
+	case *ast.TypeofExpression:
+		return list("typeof", dump(n.Right))
+	case *ast.TryExpression:
+		return list("try", dump(n.Value))
+	case *ast.AssignExpression:
+		return list("assign", atom(n.Operator), dump(n.Target), dump(n.Value))
+	case *ast.IndexExpression:
+		return list("index", dump(n.Left), dump(n.Index))
+	case *ast.MemberExpression:
+		return list("member", dump(n.Object), dump(n.Property))
+	case *ast.RangeExpression:
+		head := "range"
+		if n.Inclusive {
+			head = "range-inclusive"
+		}
+		return list(head, dump(n.Low), dump(n.High))
+	case *ast.DoExpression:
+		return list("do", dump(n.Body))
+	case *ast.BlockExpression:
+		return list("block-expr", dump(n.Body))
+	case *ast.CallExpression:
+		items := append([]sexp{dump(n.Callee)}, dumpAll(n.Arguments)...)
+		for _, arg := range n.NamedArgs {
+			items = append(items, list("named", dump(arg.Name), dump(arg.Value)))
+		}
+		return list("call", items...)
+	case *ast.FunctionLiteral:
+		return dumpFunction("fn", nil, n)
+	case *ast.ArrayLiteral:
+		var items []sexp
+		for _, elem := range n.Elements {
+			item := dump(elem.Value)
+			if elem.Spread {
+				item = list("spread", item)
+			}
+			items = append(items, item)
+		}
+		return list("array", items...)
+	case *ast.ArrayRepeatExpression:
+		return list("array-repeat", dump(n.Value), dump(n.Count))
+	case *ast.TemplateLiteral:
+		// the strings around the values, in the order they are written
+		var items []sexp
+		for i, str := range n.Strings {
+			items = append(items, atom(strconv.Quote(str)))
+			if i < len(n.Values) {
+				items = append(items, dump(n.Values[i]))
+			}
+		}
+		return list("template", items...)
+	case *ast.Identifier:
+		return atom(n.Value)
+	case *ast.IntegerLiteral:
+		return list("int", atom(strconv.FormatInt(n.Value, 10)))
+	case *ast.BigIntLiteral:
+		return list("int", atom(n.Value.String()))
+	case *ast.FloatLiteral:
+		return list("float", atom(strconv.FormatFloat(n.Value, 'g', -1, 64)))
+	case *ast.StringLiteral:
+		return list("string", atom(strconv.Quote(n.Value)))
+	case *ast.BoolLiteral:
+		return list("bool", atom(strconv.FormatBool(n.Value)))
+	}
+
+	return atom(fmt.Sprintf("(unknown %s)", node.Kind()))
+}
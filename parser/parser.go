@@ -5,112 +5,473 @@ import (
 	"RoLang/lexer"
 	"RoLang/token"
 
+	"errors"
 	"fmt"
+	"math/big"
 	"reflect"
 	"strconv"
+	"strings"
 )
 
 type Parser struct {
-	lexer *lexer.Lexer
-	// all error messages generated while parsing
-	errors []string
-	// pointers for reading tokens
+	lexer   *lexer.Lexer
+	options Options
+	// all errors generated while parsing
+	errors []ParseError
+	// pointers for reading tokens, prevToken is the one read before
+	// currToken and only used to tell `a?` from `a ?`
+	prevToken token.Token
 	currToken token.Token
 	nextToken token.Token
+	// tokens looked at past nextToken, see peekN
+	ahead []token.Token
+	// '(' and '[' open around currToken, line breaks inside them
+	// do not end statements, a block starts over from none
+	brackets int
+	// '{' open around currToken, counted through any error so a
+	// broken statement can be skipped to its end
+	braces int
+	// a closing bracket was assumed at the end of input, the
+	// statement it ends is assumed to be terminated there as well
+	closedAtEOF bool
+	// parsing the statements of a block that yields a value,
+	// where the final expression may leave out its ';'
+	valueBlock bool
+	// the final expression last left without its ';' in a value block,
+	// checked once a `do { ... }` turns out to be a loop body after all
+	unterminated ast.Statement
+	// labels of the enclosing loops and blocks, innermost last,
+	// and the number of enclosing loops in the current function
+	labels []label
+	loops  int
+	// unlabeled break and continue statements of the innermost loop,
+	// checked once a `do { ... }` turns out not to be a loop
+	jumps []token.Token
+	// function bodies being parsed, a return needs at least one
+	functions int
+	// top level functions declared so far by name, for overloads
+	declared map[string]*ast.FunctionGroup
+	// expressions and blocks being parsed, checked against MaxDepth
+	depth int
+	// every token read up to the EOF, with KeepTokens
+	tokens []token.Token
 	// pratt table
 	table [token.TOTAL]Entry
 }
 
+// a label in scope, only loop labels can be continued
+type label struct {
+	name string
+	loop bool
+}
+
 type (
 	Precedence   uint
 	prefixParser func() ast.Expression
 	infixParser  func(ast.Expression) ast.Expression
 )
 
+// optional parser behaviour, the zero value
+// gives the default behaviour
+type Options struct {
+	// accept end of input as a statement terminator so single
+	// line REPL submissions may leave out the trailing ';'
+	REPL bool
+	// a line break ends a complete statement as if a ';' was
+	// there, operators at the end of a line continue it. Inside
+	// '(' and '[' line breaks are ignored, so lists may span lines.
+	AutoSemicolon bool
+	// constructs a sandboxed embedder forbids, keyed by the Kind of
+	// their node, with "RawStringLiteral" for strings between triple
+	// quotes. Each use is reported as an error and left out of the tree.
+	Disallow map[string]bool
+	// names defined for conditional compilation, statements
+	// marked `@cfg(NAME)` are left out unless NAME is defined
+	Defines map[string]bool
+	// accept a ',' after the last argument of a call
+	TrailingCommas bool
+	// report expression statements that only compute a value with
+	// operators, like `x + y;`, as their result is thrown away
+	RejectUselessExpressions bool
+	// parse a '-' directly before a number as a negative literal,
+	// `-5` becomes the integer -5 rather than a prefix expression
+	FoldNegativeLiterals bool
+	// the deepest expressions and blocks may nest before parsing
+	// stops with an error instead of overflowing the stack, 0 for
+	// no limit
+	MaxDepth int
+	// read `a < b < c` as `(a < b) && (b < c)` like Python does,
+	// instead of comparing the result of `a < b` with c
+	ChainComparisons bool
+	// keep the parentheses written around an expression as a
+	// GroupExpression so formatters can print them back
+	PreserveParens bool
+	// keep every token read in Program.Tokens, with a lexer in trivia
+	// mode ast.WriteSource prints the source back from them as it was
+	KeepTokens bool
+	// what becomes of integer literals too large for int64
+	IntOverflow OverflowPolicy
+}
+
+// how integer literals that do not fit in int64 are parsed
+type OverflowPolicy int
+
+const (
+	OverflowError    OverflowPolicy = iota // report them, the default
+	OverflowToFloat                        // the nearest FloatLiteral
+	OverflowToBigInt                       // a BigIntLiteral
+)
+
+// an error found while parsing, located at the
+// token where the parser noticed it
+type ParseError struct {
+	Loc     token.SrcLoc
+	Message string
+}
+
+func (e ParseError) Error() string {
+	return fmt.Sprintf("%s %s", e.Loc, e.Message)
+}
+
+// the error with the line of source it was found on, see token.FormatError
+func (e ParseError) Format(source string) string {
+	return token.FormatError(source, e.Loc, e.Message)
+}
+
+// a parsed program together with the errors found in it, the
+// statements that failed to parse are left out of the program
+type ParseResult struct {
+	Program *ast.Program
+	Errors  []ParseError
+}
+
 type Entry struct {
-	prefix     prefixParser
-	infix      infixParser
-	precedence Precedence
+	prefix prefixParser
+	infix  infixParser
 }
 
 const (
-	NONE    Precedence = iota
-	ASSIGN             // =
-	EQUALS             // == !=
-	COMPARE            // < > <= >=
-	SUM                // + -
-	PRODUCT            // * /
-	PREFIX             // !x -x
-	POSTFIX            // x() x++
+	NONE     Precedence = iota
+	ASSIGN              // =
+	PIPELINE            // a then |x| f(x)
+	GUARD               // a unless c else b, c ? a : b
+	OR                  // || or
+	AND                 // && and
+	EQUALS              // == !=
+	COMPARE             // < > <= >=
+	RANGE               // a..b a..=b
+	BIT_OR              // |
+	BIT_AND             // &
+	SHIFT               // << >>
+	SUM                 // + -
+	PRODUCT             // * /
+	PREFIX              // !x -x
+	POWER               // **
+	POSTFIX             // x() x++ x?
 )
 
-func New(lexer *lexer.Lexer) *Parser {
-	p := &Parser{
-		lexer:  lexer,
-		errors: []string{},
+// how tightly each infix and postfix operator binds, tokens
+// that cannot follow an expression are left at NONE
+var precedences = [token.TOTAL]Precedence{
+	token.LPAREN:       POSTFIX,
+	token.LBRACKET:     POSTFIX,
+	token.DOT:          POSTFIX,
+	token.ASSIGN:       ASSIGN,
+	token.PLUS_ASSIGN:  ASSIGN,
+	token.MINUS_ASSIGN: ASSIGN,
+	token.STAR_ASSIGN:  ASSIGN,
+	token.SLASH_ASSIGN: ASSIGN,
+	token.MINUS:        SUM,
+	token.PLUS:         SUM,
+	token.STAR:         PRODUCT,
+	token.SLASH:        PRODUCT,
+	token.POW:          POWER,
+	token.EQ:           EQUALS,
+	token.NE:           EQUALS,
+	token.LT:           COMPARE,
+	token.LE:           COMPARE,
+	token.GT:           COMPARE,
+	token.GE:           COMPARE,
+	token.SPACESHIP:    COMPARE,
+	token.BETWEEN:      COMPARE,
+	token.IS:           COMPARE,
+	token.DOTDOT:       RANGE,
+	token.DOTDOTEQ:     RANGE,
+	token.PIPE:         BIT_OR,
+	token.AMP:          BIT_AND,
+	token.SHL:          SHIFT,
+	token.SHR:          SHIFT,
+	token.UNLESS:       GUARD,
+	token.QUESTION:     GUARD,
+	token.THEN:         PIPELINE,
+	token.AND:          AND,
+	token.OR:           OR,
+}
+
+// Returns how tightly the operator binds to the expression before it,
+// a higher precedence binds tighter and NONE means the token does not
+// continue an expression. So do the types past token.TOTAL a lexer
+// may give the keywords of a language variant.
+func PrecedenceOf(tokenType token.TokenType) Precedence {
+	if tokenType >= token.TOTAL {
+		return NONE
 	}
 
+	return precedences[tokenType]
+}
+
+func New(lexer *lexer.Lexer) *Parser {
+	return NewWithOptions(lexer, Options{})
+}
+
+func NewWithOptions(lexer *lexer.Lexer, options Options) *Parser {
+	p := &Parser{options: options}
+
 	// TODO put this into a global variable so that every time a parser
 	// is created it refers to the same table instead of creating and filling
 	// a new table
 	p.table = [token.TOTAL]Entry{
-		// prefix expression do not need a precedence
-		token.LPAREN: {p.parseGroupedExpression, p.parseCallExpression, POSTFIX},
-		token.STRING: {p.parseStringLiteral, nil, NONE},
-		token.IDENT:  {p.parseIdentifier, nil, NONE},
-		token.FN:     {p.parseFunctionLiteral, nil, NONE},
-		token.INT:    {p.parseIntegerLiteral, nil, NONE},
-		token.FLOAT:  {p.parseFloatLiteral, nil, NONE},
-		token.TRUE:   {p.parseBoolLiteral, nil, NONE},
-		token.FALSE:  {p.parseBoolLiteral, nil, NONE},
-		token.BANG:   {p.parsePrefixExpression, nil, NONE},
-		token.MINUS:  {p.parsePrefixExpression, p.parseInfixExpression, SUM},
-		token.PLUS:   {nil, p.parseInfixExpression, SUM},
-		token.STAR:   {nil, p.parseInfixExpression, PRODUCT},
-		token.SLASH:  {nil, p.parseInfixExpression, PRODUCT},
-		token.EQ:     {nil, p.parseInfixExpression, EQUALS},
-		token.NE:     {nil, p.parseInfixExpression, EQUALS},
-		token.LT:     {nil, p.parseInfixExpression, COMPARE},
-		token.LE:     {nil, p.parseInfixExpression, COMPARE},
-		token.GT:     {nil, p.parseInfixExpression, COMPARE},
-		token.GE:     {nil, p.parseInfixExpression, COMPARE},
+		token.LPAREN:       {p.parseGroupedExpression, p.parseCallExpression},
+		token.LBRACKET:     {p.parseArrayLiteral, p.parseIndexExpression},
+		token.DOT:          {nil, p.parseMemberExpression},
+		token.ASSIGN:       {nil, p.parseAssignExpression},
+		token.PLUS_ASSIGN:  {nil, p.parseAssignExpression},
+		token.MINUS_ASSIGN: {nil, p.parseAssignExpression},
+		token.STAR_ASSIGN:  {nil, p.parseAssignExpression},
+		token.SLASH_ASSIGN: {nil, p.parseAssignExpression},
+		token.ERR:          {p.parseLexError, nil},
+		token.STRING:       {p.parseStringLiteral, nil},
+		token.TEMPLATE:     {p.parseTemplateLiteral, nil},
+		token.IDENT:        {p.parseIdentifier, nil},
+		token.FN:           {p.parseFunctionLiteral, nil},
+		token.DO:           {p.parseDoExpression, nil},
+		token.LBRACE:       {p.parseBlockExpression, nil},
+		token.INT:          {p.parseIntegerLiteral, nil},
+		token.FLOAT:        {p.parseFloatLiteral, nil},
+		token.TRUE:         {p.parseBoolLiteral, nil},
+		token.FALSE:        {p.parseBoolLiteral, nil},
+		token.BANG:         {p.parsePrefixExpression, nil},
+		token.TYPEOF:       {p.parseTypeofExpression, nil},
+		token.TILDE:        {p.parsePrefixExpression, nil},
+		token.MINUS:        {p.parsePrefixExpression, p.parseInfixExpression},
+		token.PLUS:         {p.parsePrefixExpression, p.parseInfixExpression},
+		token.STAR:         {nil, p.parseInfixExpression},
+		token.SLASH:        {nil, p.parseInfixExpression},
+		token.POW:          {nil, p.parseInfixExpression},
+		token.EQ:           {nil, p.parseInfixExpression},
+		token.NE:           {nil, p.parseInfixExpression},
+		token.LT:           {nil, p.parseComparison},
+		token.LE:           {nil, p.parseComparison},
+		token.GT:           {nil, p.parseComparison},
+		token.GE:           {nil, p.parseComparison},
+		token.SPACESHIP:    {nil, p.parseInfixExpression},
+		token.BETWEEN:      {nil, p.parseBetweenExpression},
+		token.IS:           {nil, p.parseTypeTestExpression},
+		token.PIPE:         {nil, p.parseInfixExpression},
+		token.AMP:          {nil, p.parseInfixExpression},
+		token.SHL:          {nil, p.parseInfixExpression},
+		token.SHR:          {nil, p.parseInfixExpression},
+		token.UNLESS:       {nil, p.parseUnlessExpression},
+		token.QUESTION:     {nil, p.parseQuestion},
+		token.THEN:         {nil, p.parsePipelineExpression},
+		token.DOTDOT:       {nil, p.parseRangeExpression},
+		token.DOTDOTEQ:     {nil, p.parseRangeExpression},
+		token.AND:          {nil, p.parseInfixExpression},
+		token.OR:           {nil, p.parseInfixExpression},
 	}
 
+	p.Reset(lexer)
+
+	return p
+}
+
+// Readies the parser for the source of another lexer, keeping its
+// options. Errors of earlier runs are dropped from Errors, though
+// slices it returned before stay as they were.
+func (p *Parser) Reset(lexer *lexer.Lexer) {
+	p.lexer = lexer
+	p.errors = []ParseError{}
+	p.valueBlock = false
+	p.unterminated = nil
+	p.labels, p.loops, p.jumps = nil, 0, nil
+	p.functions = 0
+	p.declared = map[string]*ast.FunctionGroup{}
+	p.depth = 0
+	p.tokens = nil
+	p.ahead = nil
+	p.brackets = 0
+	p.braces = 0
+	p.closedAtEOF = false
+
 	// Read two tokens, to set currToken and nextToken
 	p.readToken()
 	p.readToken()
+	p.prevToken = token.Token{}
+}
 
-	return p
+// Parses the source of the named file in one go, returning the
+// statements that parsed cleanly along with every error found
+func Parse(file, source string) (*ast.Program, []ParseError) {
+	result := New(lexer.New(file, source)).ParseProgram()
+
+	return result.Program, result.Errors
 }
 
 func (p *Parser) Parse() *ast.Program {
+	return p.ParseProgram().Program
+}
+
+// Parses the whole input, returning the program along with
+// every error found so far by the parser
+func (p *Parser) ParseProgram() ParseResult {
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
 
 	// Read until end of file
-	for !p.hasToken(token.EOF) {
-		stmt := p.ParseStatement()
-		if reflect.ValueOf(stmt).IsNil() {
+	for {
+		stmt, eof := p.ParseStatement()
+		if group, ok := stmt.(*ast.FunctionGroup); ok {
+			placeGroup(program, group)
+		} else if stmt != nil {
+			program.Statements = append(program.Statements, stmt)
+		}
+		if eof {
+			break
+		}
+	}
+
+	program.Tokens = p.tokens
+
+	return ParseResult{Program: program, Errors: p.errors}
+}
+
+// a group takes the place of the first function of
+// its name, the later variants are not kept apart
+func placeGroup(program *ast.Program, group *ast.FunctionGroup) {
+	for i, stmt := range program.Statements {
+		if stmt == group || stmt == ast.Statement(group.Variants[0]) {
+			program.Statements[i] = group
+			return
+		}
+	}
+}
+
+// A top level function declared under the name of earlier ones joins
+// them in a group, which is returned in its place. The variants must
+// not agree on any number of arguments, so a call picks one at most.
+func (p *Parser) overload(stmt ast.Statement) ast.Statement {
+	fn, ok := stmt.(*ast.FunctionStatement)
+	if !ok {
+		return stmt
+	}
+
+	group, ok := p.declared[fn.Ident.Value]
+	if !ok {
+		p.declared[fn.Ident.Value] = &ast.FunctionGroup{Ident: fn.Ident, Variants: []*ast.FunctionStatement{fn}}
+		return fn
+	}
+
+	least, most := fn.Value.Arity()
+	for _, variant := range group.Variants {
+		if overlaps(variant.Value, fn.Value) {
+			p.reportAt(fn.Ident.Location(), fmt.Sprintf("function %q taking %s arguments clashes with the one declared on line %d",
+				fn.Ident.Value, arityString(least, most), variant.Ident.Location().Line))
 			return nil
 		}
-		program.Statements = append(program.Statements, stmt)
-		// Set parser on the first token of next statement
-		p.readToken()
 	}
+	group.Variants = append(group.Variants, fn)
+
+	return group
+}
+
+// whether some number of arguments is accepted by both functions
+func overlaps(a, b *ast.FunctionLiteral) bool {
+	aLeast, aMost := a.Arity()
+	bLeast, bMost := b.Arity()
+
+	return (aMost < 0 || bLeast <= aMost) && (bMost < 0 || aLeast <= bMost)
+}
+
+func arityString(least, most int) string {
+	switch {
+	case most < 0:
+		return fmt.Sprintf("%d or more", least)
+	case least == most:
+		return strconv.Itoa(least)
+	default:
+		return fmt.Sprintf("%d to %d", least, most)
+	}
+}
+
+// Parses the next top level statement, for tools feeding a program
+// one statement at a time, and reports whether the end of input is
+// reached. The statement is nil when it is broken or left out by cfg,
+// its errors are added to the others in Errors. A function overloading
+// earlier ones comes back as the FunctionGroup holding all of them.
+func (p *Parser) ParseStatement() (ast.Statement, bool) {
+	p.skipSemicolons()
+	if p.hasToken(token.EOF) {
+		return nil, true
+	}
+
+	braces := p.braces
+	stmt := p.parseStatement()
+	if isNil(stmt) {
+		// drop the broken statement and carry on with the next
+		p.synchronize(braces)
+		stmt = nil
+	} else if !p.included(stmt) || !p.permitted(stmt) {
+		stmt = nil
+	} else {
+		stmt = p.overload(stmt)
+	}
+	// Set parser on the first token of next statement
+	p.readToken()
+	p.skipSemicolons()
+
+	return stmt, p.hasToken(token.EOF)
+}
 
-	return program
+// stray ';' between statements, as in `;; let x = 1; ;`, are skipped
+func (p *Parser) skipSemicolons() {
+	for p.hasToken(token.SEMCOL) {
+		p.readToken()
+	}
 }
 
-func (p *Parser) ParseStatement() ast.Statement {
+func (p *Parser) parseStatement() ast.Statement {
 	switch p.currToken.Type {
-	case token.LET:
+	case token.LET, token.CONST:
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.ASSERT:
+		return p.parseAssertStatement()
+	case token.DEFER:
+		return p.parseDeferStatement()
+	case token.PRINT, token.PRINTLN:
+		return p.parsePrintStatement()
+	case token.IMPORT:
+		return p.parseImportStatement()
 	case token.IF:
 		return p.parseIfStatement()
+	case token.WHILE:
+		return p.parseWhileStatement(nil)
+	case token.FOR:
+		return p.parseForInStatement(nil)
+	case token.DO:
+		return p.parseDoStatement(nil)
+	case token.BREAK, token.CONT:
+		return p.parseJumpStatement()
+	case token.IDENT:
+		if p.peekToken(token.COLON) {
+			return p.parseLabeledStatement()
+		}
+		return p.parseExpressionStatement()
 	case token.LBRACE:
 		return p.parseBlockStatement()
+	case token.AT, token.HASH:
+		return p.parseAttributedStatement()
 	case token.FN:
 		if p.peekToken(token.LPAREN) {
 			return p.parseExpressionStatement()
@@ -122,34 +483,123 @@ func (p *Parser) ParseStatement() ast.Statement {
 }
 
 func (p *Parser) ParseExpression(precedence Precedence) ast.Expression {
-	prefix := p.table[p.currToken.Type].prefix
+	if !p.enter() {
+		return nil
+	}
+	defer p.leave()
+
+	prefix := p.entry(p.currToken.Type).prefix
 	if prefix == nil {
 		p.noPrefixFuncError(p.currToken.Type)
 		return nil
 	}
 
 	expr := prefix()
-	if expr == nil {
+	if expr == nil || !p.permitted(expr) {
 		return nil
 	}
 
+	return p.continueInfix(expr, precedence)
+}
+
+// Continues parsing from an expression built elsewhere, the current
+// token must be the operator following left. Operators binding no
+// tighter than precedence are left for the caller, as in ParseExpression.
+func (p *Parser) ParseInfixFrom(left ast.Expression, precedence Precedence) ast.Expression {
+	infix := p.entry(p.currToken.Type).infix
+	if infix == nil {
+		p.reportAt(p.currToken.Loc, fmt.Sprintf("expected an operator, got %q", p.currToken.Word))
+		return nil
+	}
+
+	if precedence >= p.precedenceAt(0) {
+		return left
+	}
+
+	expr := infix(left)
+	if expr == nil || !p.permitted(expr) {
+		return nil
+	}
+
+	return p.continueInfix(expr, precedence)
+}
+
+// the precedence of the operator peekN(k) gives, which for '?' depends
+// on the token after it. The token after is only read for a '?', so
+// the parser does not wait on input it does not need yet.
+func (p *Parser) precedenceAt(k int) Precedence {
+	tok := p.peekN(k)
+	if tok.Type != token.QUESTION {
+		return PrecedenceOf(tok.Type)
+	}
+
+	prev := p.prevToken
+	if k > 0 {
+		prev = p.peekN(k - 1)
+	}
+	if p.isTry(prev, k) {
+		return POSTFIX
+	}
+
+	return PrecedenceOf(tok.Type)
+}
+
+// the parse functions of a token type, none for the
+// types past token.TOTAL as no expression uses them
+func (p *Parser) entry(tokenType token.TokenType) Entry {
+	if tokenType >= token.TOTAL {
+		return Entry{}
+	}
+
+	return p.table[tokenType]
+}
+
+// goes one level deeper, failing once MaxDepth is reached
+func (p *Parser) enter() bool {
+	if p.options.MaxDepth > 0 && p.depth >= p.options.MaxDepth {
+		p.reportAt(p.currToken.Loc, "maximum nesting depth exceeded")
+		return false
+	}
+	p.depth++
+
+	return true
+}
+
+func (p *Parser) leave() {
+	p.depth--
+}
+
+func (p *Parser) continueInfix(expr ast.Expression, precedence Precedence) ast.Expression {
 	// keep consuming tokens until next token's precedence
 	// is greater than current token's precedence
-	for precedence < p.table[p.nextToken.Type].precedence {
-		infix := p.table[p.nextToken.Type].infix
+	for precedence < p.precedenceAt(1) {
+		// an operator starting the next line does not continue
+		// this one, `f\n(x)` is two statements
+		if p.options.AutoSemicolon && p.brackets == 0 && p.lineBreak() {
+			return expr
+		}
+
+		infix := p.entry(p.nextToken.Type).infix
 		if infix == nil { // only prefix expression
 			return expr
 		}
 
 		p.readToken()
-		expr = infix(expr)
+		if expr = infix(expr); expr == nil || !p.permitted(expr) {
+			return nil
+		}
 	}
 
 	return expr
 }
 
 func (p *Parser) Errors() []string {
-	return p.errors
+	messages := make([]string, len(p.errors))
+	for i, err := range p.errors {
+		messages[i] = err.Error()
+	}
+
+	return messages
 }
 
 func (p *Parser) parseFunctionStatement() *ast.FunctionStatement {
@@ -164,13 +614,14 @@ func (p *Parser) parseFunctionStatement() *ast.FunctionStatement {
 		Value: p.currToken.Word,
 	}
 
+	fn := &ast.FunctionLiteral{Token: stmt.Token}
+
 	// assertive check for '('
 	if !p.expectToken(token.LPAREN) {
 		return nil
 	}
 
-	parameters := p.parseFunctionParameters()
-	if parameters == nil {
+	if !p.parseFunctionParameters(fn) {
 		return nil
 	}
 
@@ -179,22 +630,44 @@ func (p *Parser) parseFunctionStatement() *ast.FunctionStatement {
 		return nil
 	}
 
-	body := p.parseBlockStatement()
+	body := p.parseFunctionBody()
 	if body == nil {
 		return nil
 	}
+	fn.Body = body
+
+	stmt.Value = fn
+
+	return stmt
+}
+
+func (p *Parser) parseImportStatement() *ast.ImportStatement {
+	stmt := &ast.ImportStatement{Token: p.currToken}
+
+	if !p.peekToken(token.STRING) {
+		p.report(fmt.Sprintf("import expects a module path string, got %q instead", p.nextToken.Word))
+		return nil
+	}
+	p.readToken()
+	stmt.Path = &ast.StringLiteral{Token: p.currToken, Value: p.currToken.Word}
+
+	if p.matchToken(token.AS) {
+		if !p.expectToken(token.IDENT) {
+			return nil
+		}
+		stmt.Alias = &ast.Identifier{Token: p.currToken, Value: p.currToken.Word}
+	}
 
-	stmt.Value = &ast.FunctionLiteral{
-		Token:      stmt.Token,
-		Parameters: parameters,
-		Body:       body,
+	if !p.expectTerminator() {
+		return nil
 	}
 
 	return stmt
 }
 
+// parses both `let` and `const`, which share a node
 func (p *Parser) parseLetStatement() *ast.LetStatement {
-	stmt := &ast.LetStatement{Token: p.currToken}
+	stmt := &ast.LetStatement{Token: p.currToken, IsConst: p.hasToken(token.CONST)}
 
 	// match and consume an identifier
 	if !p.expectToken(token.IDENT) {
@@ -206,10 +679,21 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 		Value: p.currToken.Word,
 	}
 
-	// match and consume an equals
-	if !p.expectToken(token.ASSIGN) {
+	if stmt.IsConst && !p.peekToken(token.ASSIGN) {
+		p.report(fmt.Sprintf("const %q must be initialized", stmt.Ident.Value))
 		return nil
 	}
+
+	// `let x;` declares x without a value
+	if !p.peekToken(token.ASSIGN) {
+		if !p.expectTerminator() {
+			return nil
+		}
+		return stmt
+	}
+
+	// consume ident and '=' tokens
+	p.readToken()
 	p.readToken()
 
 	initValue := p.ParseExpression(NONE)
@@ -219,37 +703,96 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	stmt.InitValue = initValue
 
-	// optional semi-colon token
-	if !p.expectToken(token.SEMCOL) {
+	if !p.expectTerminator() {
 		return nil
 	}
 
 	return stmt
 }
 
-func (p *Parser) parseIfStatement() ast.Statement {
-	stmt := &ast.IfStatement{Token: p.currToken}
-	// consume 'if'
+// parses the `let x = f()` of `if let x = f() { ... }`, where the then
+// block runs with x bound when the value is not null.
+//
+// The value is parsed at AND precedence, so it stops before any `&&`
+// or `||`. Taking them in would bind x to the result of the whole
+// condition, while `if let x = f() && g()` reads as if g() were a
+// second condition next to the binding, so neither meaning is picked
+// and the operator is an error. `if let x = (f() && g())` binds the
+// result of both.
+func (p *Parser) parseIfBinding() *ast.LetStatement {
+	stmt := &ast.LetStatement{Token: p.currToken}
+
+	if !p.expectToken(token.IDENT) {
+		return nil
+	}
+	stmt.Ident = &ast.Identifier{Token: p.currToken, Value: p.currToken.Word}
+
+	if !p.expectToken(token.ASSIGN) {
+		return nil
+	}
+
+	// consume '=' token, the value ends before `&&` and `||`
 	p.readToken()
+	if stmt.InitValue = p.ParseExpression(AND); stmt.InitValue == nil {
+		return nil
+	}
 
-	// no parenthesis is necessary we straight
-	// away parse condition expression
-	condition := p.ParseExpression(NONE)
-	if condition == nil {
+	if p.peekToken(token.AND) || p.peekToken(token.OR) {
+		p.report(fmt.Sprintf("%q cannot follow the value of an if let, put the value in parentheses", p.nextToken.Word))
 		return nil
 	}
 
-	stmt.Condition = condition
+	return stmt
+}
+
+func (p *Parser) parseIfStatement() ast.Statement {
+	stmt := &ast.IfStatement{Token: p.currToken}
+
+	if p.matchToken(token.LET) {
+		if stmt.Binding = p.parseIfBinding(); stmt.Binding == nil {
+			return nil
+		}
+	} else {
+		// consume 'if'
+		p.readToken()
+
+		// no parenthesis is necessary we straight
+		// away parse condition expression
+		condition := p.ParseExpression(NONE)
+		if condition == nil {
+			return nil
+		}
+
+		// `if x = 5` is almost always a mistyped comparison
+		if assign, ok := condition.(*ast.AssignExpression); ok && assign.Operator == "=" {
+			p.reportAt(assign.Token.Loc, "assignment used as if condition, did you mean '=='?")
+			return nil
+		}
+
+		stmt.Condition = condition
+	}
 
 	if !p.expectToken(token.LBRACE) {
 		return nil
 	}
 
 	then := p.parseBlockStatement()
+	if then == nil {
+		return nil
+	}
 	stmt.Then = then
 
-	// check for 'else'
-	if p.peekToken(token.ELSE) {
+	// `elif` is read as `else if`
+	if p.peekToken(token.ELIF) {
+		p.readToken()
+		p.currToken.Type, p.currToken.Word = token.IF, "if"
+
+		elze := p.parseIfStatement()
+		if elze == nil {
+			return nil
+		}
+		stmt.Else = elze
+	} else if p.peekToken(token.ELSE) {
 		// consume 'else'
 		p.readToken()
 
@@ -279,112 +822,1001 @@ func (p *Parser) parseIfStatement() ast.Statement {
 
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: p.currToken}
+	if p.functions == 0 {
+		p.reportAt(stmt.Token.Loc, "return outside of a function")
+		return nil
+	}
 
-	// consume 'return' token
-	p.readToken()
-	if p.hasToken(token.SEMCOL) {
+	// return without a value
+	if p.matchToken(token.SEMCOL) || p.terminated() {
 		return stmt
 	}
 
-	returnValue := p.ParseExpression(NONE)
-	if returnValue == nil {
-		return nil
+	// consume 'return' token
+	p.readToken()
+
+	// `return a, b;` returns both
+	for {
+		value := p.ParseExpression(NONE)
+		if value == nil {
+			return nil
+		}
+		stmt.ReturnValues = append(stmt.ReturnValues, value)
+
+		if !p.matchToken(token.COMMA) {
+			break
+		}
+		p.readToken()
 	}
 
-	stmt.ReturnValue = returnValue
-	if !p.expectToken(token.SEMCOL) {
+	if len(stmt.ReturnValues) == 1 {
+		stmt.ReturnValue = stmt.ReturnValues[0]
+	}
+	if !p.expectTerminator() {
 		return nil
 	}
 
 	return stmt
 }
 
-func (p *Parser) parseBlockStatement() *ast.BlockStatement {
-	block := &ast.BlockStatement{Token: p.currToken}
-	block.Statements = []ast.Statement{}
+func (p *Parser) parseAssertStatement() *ast.AssertStatement {
+	stmt := &ast.AssertStatement{Token: p.currToken}
 
-	// consume '{' token
+	if p.peekToken(token.SEMCOL) || p.peekToken(token.EOF) {
+		p.report(fmt.Sprintf("assert expects a condition, got %q", p.nextToken.Word))
+		return nil
+	}
+
+	// consume 'assert' token
 	p.readToken()
+	if stmt.Condition = p.ParseExpression(NONE); stmt.Condition == nil {
+		return nil
+	}
 
-	for !p.hasToken(token.RBRACE) && !p.hasToken(token.EOF) {
-		stmt := p.ParseStatement()
-		if stmt == nil {
+	if p.matchToken(token.COMMA) {
+		p.readToken()
+		if stmt.Message = p.ParseExpression(NONE); stmt.Message == nil {
 			return nil
 		}
-		block.Statements = append(block.Statements, stmt)
-
-		p.readToken() // read next statement's token
 	}
 
-	if p.hasToken(token.EOF) {
-		p.report("expect '}' at end of block reached end of file")
+	if !p.expectTerminator() {
 		return nil
 	}
 
-	return block
+	return stmt
 }
 
-func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
-	stmt := &ast.ExpressionStatement{Token: p.currToken}
+func (p *Parser) parseDeferStatement() *ast.DeferStatement {
+	stmt := &ast.DeferStatement{Token: p.currToken}
 
+	// consume 'defer' token
+	p.readToken()
 	expr := p.ParseExpression(NONE)
 	if expr == nil {
 		return nil
 	}
 
-	stmt.Expression = expr
+	// only a call has something to run later
+	call, ok := expr.(*ast.CallExpression)
+	if !ok {
+		p.reportAt(expr.Location(), fmt.Sprintf("defer expects a function call, got %s", expr))
+		return nil
+	}
+	stmt.Call = call
 
-	if !p.expectToken(token.SEMCOL) {
+	if !p.expectTerminator() {
 		return nil
 	}
 
 	return stmt
 }
 
-func (p *Parser) parsePrefixExpression() ast.Expression {
-	expr := &ast.PrefixExpression{
-		Token:    p.currToken,
-		Operator: p.currToken.Word,
+func (p *Parser) parsePrintStatement() *ast.PrintStatement {
+	stmt := &ast.PrintStatement{Token: p.currToken, Newline: p.hasToken(token.PRINTLN)}
+
+	// `println;` prints just the newline
+	if p.matchToken(token.SEMCOL) || p.terminated() {
+		return stmt
 	}
-	p.readToken()
-	right := p.ParseExpression(PREFIX)
-	expr.Right = right
 
-	return expr
-}
+	// consume 'print' token
+	p.readToken()
+	for {
+		value := p.ParseExpression(NONE)
+		if value == nil {
+			return nil
+		}
+		stmt.Values = append(stmt.Values, value)
 
-func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
-	expr := &ast.InfixExpression{
-		Token:    p.currToken,
-		Operator: p.currToken.Word,
-		Left:     left,
+		if !p.matchToken(token.COMMA) {
+			break
+		}
+		p.readToken()
 	}
 
-	// get current token's precedence
-	precedence := p.table[p.currToken.Type].precedence
-	// consume current token
-	p.readToken()
-	// start parsing the next token and use current token's precedence
-	right := p.ParseExpression(precedence)
-	if right == nil {
+	if !p.expectTerminator() {
 		return nil
 	}
-	expr.Right = right
 
-	return expr
+	return stmt
 }
 
-func (p *Parser) parseGroupedExpression() ast.Expression {
-	p.readToken()
+// labels and loops outside a function cannot be jumped to from inside
+func (p *Parser) parseFunctionBody() *ast.BlockStatement {
+	defer func(labels []label, loops int, jumps []token.Token) {
+		p.labels, p.loops, p.jumps = labels, loops, jumps
+		p.functions--
+	}(p.labels, p.loops, p.jumps)
+	p.labels, p.loops, p.jumps = nil, 0, nil
+	p.functions++
 
-	expr := p.ParseExpression(NONE)
-	if expr == nil {
-		return nil
-	}
+	return p.parseBlockStatement()
+}
+
+// parses `name: while ...` and `name: { ... }`
+func (p *Parser) parseLabeledStatement() ast.Statement {
+	name := &ast.Identifier{Token: p.currToken, Value: p.currToken.Word}
+
+	for _, l := range p.labels {
+		if l.name == name.Value {
+			p.reportAt(name.Location(), fmt.Sprintf("label %q is already in use", name.Value))
+			return nil
+		}
+	}
+
+	// consume the label and ':'
+	p.readToken()
+	p.readToken()
+
+	switch p.currToken.Type {
+	case token.WHILE:
+		return p.parseWhileStatement(name)
+	case token.FOR:
+		return p.parseForInStatement(name)
+	case token.DO:
+		return p.parseDoStatement(name)
+	case token.LBRACE:
+		p.labels = append(p.labels, label{name: name.Value})
+		defer func() { p.labels = p.labels[:len(p.labels)-1] }()
+
+		block := p.parseBlockStatement()
+		if block == nil {
+			return nil
+		}
+		block.Label = name
+
+		return block
+	default:
+		p.reportAt(p.currToken.Loc, fmt.Sprintf("label %q must be followed by a loop or a block", name.Value))
+		return nil
+	}
+}
+
+func (p *Parser) parseWhileStatement(name *ast.Identifier) ast.Statement {
+	stmt := &ast.WhileStatement{Token: p.currToken, Label: name}
+
+	// consume 'while'
+	p.readToken()
+	if stmt.Condition = p.ParseExpression(NONE); stmt.Condition == nil {
+		return nil
+	}
+
+	if !p.expectToken(token.LBRACE) {
+		return nil
+	}
+
+	if name != nil {
+		p.labels = append(p.labels, label{name: name.Value, loop: true})
+		defer func() { p.labels = p.labels[:len(p.labels)-1] }()
+	}
+	defer p.enterLoop()()
+
+	if stmt.Body = p.parseBlockStatement(); stmt.Body == nil {
+		return nil
+	}
+
+	return stmt
+}
+
+// parses `for x in xs { ... }` and `for i, x in xs { ... }`
+func (p *Parser) parseForInStatement(name *ast.Identifier) ast.Statement {
+	stmt := &ast.ForInStatement{Token: p.currToken, Label: name}
+
+	vars := []*ast.Identifier{}
+	for {
+		if !p.expectToken(token.IDENT) {
+			return nil
+		}
+		ident := &ast.Identifier{Token: p.currToken, Value: p.currToken.Word}
+
+		if len(vars) == 2 {
+			p.reportAt(ident.Location(), "for loops take at most two variables")
+			return nil
+		}
+		vars = append(vars, ident)
+
+		if !p.matchToken(token.COMMA) {
+			break
+		}
+	}
+
+	if len(vars) == 2 {
+		stmt.IndexVar = vars[0]
+	}
+	stmt.Var = vars[len(vars)-1]
+
+	if !p.expectToken(token.IN) {
+		return nil
+	}
+
+	// consume 'in'
+	p.readToken()
+	if stmt.Iterable = p.ParseExpression(NONE); stmt.Iterable == nil {
+		return nil
+	}
+
+	if !p.expectToken(token.LBRACE) {
+		return nil
+	}
+
+	if name != nil {
+		p.labels = append(p.labels, label{name: name.Value, loop: true})
+		defer func() { p.labels = p.labels[:len(p.labels)-1] }()
+	}
+	defer p.enterLoop()()
+
+	if stmt.Body = p.parseBlockStatement(); stmt.Body == nil {
+		return nil
+	}
+
+	return stmt
+}
+
+// a statement starting with 'do' is a do-while loop when 'while'
+// follows the block, otherwise the block is a do expression
+func (p *Parser) parseDoStatement(name *ast.Identifier) ast.Statement {
+	tok := p.currToken
+
+	if !p.expectToken(token.LBRACE) {
+		return nil
+	}
+
+	if name != nil {
+		p.labels = append(p.labels, label{name: name.Value, loop: true})
+		defer func() { p.labels = p.labels[:len(p.labels)-1] }()
+	}
+	leave := p.enterLoop()
+	body := p.parseBlock(true)
+	jumps := p.jumps
+	leave()
+	if body == nil {
+		return nil
+	}
+
+	closing := p.currToken
+	if p.matchToken(token.WHILE) {
+		stmt := &ast.DoWhileStatement{Token: tok, Label: name, Body: body}
+
+		// a loop body needs its statements terminated, as in a while loop
+		if n := len(body.Statements); n > 0 && body.Statements[n-1] == p.unterminated {
+			p.reportAt(closing.Loc, `expected next token to be ";", got "}" instead`)
+			return nil
+		}
+
+		// consume 'while'
+		p.readToken()
+		if stmt.Condition = p.ParseExpression(NONE); stmt.Condition == nil {
+			return nil
+		}
+
+		if !p.expectTerminator() {
+			return nil
+		}
+
+		return stmt
+	}
+
+	if name != nil {
+		p.reportAt(tok.Loc, fmt.Sprintf("label %q must be followed by a loop or a block", name.Value))
+		return nil
+	}
+
+	// not a loop after all, so the jumps in it belong to the loop
+	// around it, if there is one
+	if len(jumps) > 0 && p.loops == 0 {
+		p.reportAt(jumps[0].Loc, fmt.Sprintf("%s outside of a loop", jumps[0].Word))
+		return nil
+	}
+	p.jumps = append(p.jumps, jumps...)
+
+	if !p.checkValueBlock(body, "do block") {
+		return nil
+	}
+
+	expr := p.continueInfix(&ast.DoExpression{Token: tok, Body: body}, NONE)
+	if expr == nil {
+		return nil
+	}
+
+	return p.finishExpressionStatement(tok, expr)
+}
+
+// counts a loop around the statements parsed until the returned
+// function is called
+func (p *Parser) enterLoop() func() {
+	jumps := p.jumps
+	p.loops++
+	p.jumps = nil
+
+	return func() {
+		p.loops--
+		p.jumps = jumps
+	}
+}
+
+// parses break and continue, which must be inside a loop or,
+// for break, inside the block named by their label
+func (p *Parser) parseJumpStatement() ast.Statement {
+	tok := p.currToken
+	keyword := tok.Word
+
+	var name *ast.Identifier
+	if p.matchToken(token.IDENT) {
+		name = &ast.Identifier{Token: p.currToken, Value: p.currToken.Word}
+
+		target := p.lookUpLabel(name.Value)
+		switch {
+		case target == nil:
+			p.reportAt(name.Location(), fmt.Sprintf("unknown label %q", name.Value))
+			return nil
+		case tok.Type == token.CONT && !target.loop:
+			p.reportAt(name.Location(), fmt.Sprintf("cannot continue %q, it does not label a loop", name.Value))
+			return nil
+		}
+	} else if p.loops == 0 {
+		p.reportAt(tok.Loc, fmt.Sprintf("%s outside of a loop", keyword))
+		return nil
+	} else {
+		p.jumps = append(p.jumps, tok)
+	}
+
+	if !p.expectTerminator() {
+		return nil
+	}
+
+	if tok.Type == token.BREAK {
+		return &ast.BreakStatement{Token: tok, Label: name}
+	}
+	return &ast.ContinueStatement{Token: tok, Label: name}
+}
+
+func (p *Parser) lookUpLabel(name string) *label {
+	for i := len(p.labels) - 1; i >= 0; i-- {
+		if p.labels[i].name == name {
+			return &p.labels[i]
+		}
+	}
+
+	return nil
+}
+
+func (p *Parser) parseBlockStatement() *ast.BlockStatement {
+	// nested blocks need their statements terminated
+	return p.parseBlock(false)
+}
+
+// parses the statements between '{' and '}', in a value block
+// the final expression may leave out its ';'
+func (p *Parser) parseBlock(valueBlock bool) *ast.BlockStatement {
+	if !p.enter() {
+		return nil
+	}
+	defer p.leave()
+
+	block := &ast.BlockStatement{Token: p.currToken}
+	block.Statements = []ast.Statement{}
+
+	defer func(valueBlock bool) { p.valueBlock = valueBlock }(p.valueBlock)
+	p.valueBlock = valueBlock
+
+	// the statements of the block end at line breaks again
+	defer func(brackets int) { p.brackets = brackets }(p.brackets)
+	p.brackets = 0
+
+	// consume '{' token
+	p.readToken()
+	p.skipSemicolons()
+
+	for !p.hasToken(token.RBRACE) && !p.hasToken(token.EOF) {
+		stmt := p.parseStatement()
+		if isNil(stmt) {
+			return nil
+		}
+		if p.included(stmt) && p.permitted(stmt) {
+			block.Statements = append(block.Statements, stmt)
+		}
+
+		p.readToken() // read next statement's token
+		p.skipSemicolons()
+	}
+
+	if p.hasToken(token.EOF) {
+		// point back at the '{' as the end of the file says little
+		// about where the '}' went missing
+		open := block.Token.Loc
+		p.reportAt(p.currToken.Loc, fmt.Sprintf("unclosed block opened at %d:%d", open.Line, open.Col))
+		return nil
+	}
+
+	return block
+}
+
+// parses a block whose last statement must be an expression
+// statement, giving the value of the block
+func (p *Parser) parseValueBlock(kind string) *ast.BlockStatement {
+	block := p.parseBlock(true)
+	if block == nil || !p.checkValueBlock(block, kind) {
+		return nil
+	}
+
+	return block
+}
+
+func (p *Parser) checkValueBlock(block *ast.BlockStatement, kind string) bool {
+	if len(block.Statements) == 0 {
+		p.reportAt(block.Token.Loc, fmt.Sprintf("%s must end with an expression", kind))
+		return false
+	}
+
+	last := block.Statements[len(block.Statements)-1]
+	if _, ok := last.(*ast.ExpressionStatement); !ok {
+		p.reportAt(last.Location(), fmt.Sprintf("%s must end with an expression", kind))
+		return false
+	}
+
+	return true
+}
+
+func (p *Parser) parseAttributedStatement() ast.Statement {
+	stmt := &ast.AttributedStatement{}
+
+	// `@name(args)` and `#[name(args)]` may be mixed
+	for p.hasToken(token.AT) || p.hasToken(token.HASH) {
+		attr := &ast.Attribute{Token: p.currToken}
+
+		if !p.expectToken(token.IDENT) {
+			return nil
+		}
+		attr.Name = &ast.Identifier{Token: p.currToken, Value: p.currToken.Word}
+
+		if p.matchToken(token.LPAREN) {
+			if attr.Args = p.parseCallArguments(nil); attr.Args == nil {
+				return nil
+			}
+		}
+
+		if attr.Token.Type == token.HASH && !p.expectToken(token.RBRACKET) {
+			return nil
+		}
+
+		stmt.Attributes = append(stmt.Attributes, attr)
+		// move on to the next attribute or the statement
+		p.readToken()
+	}
+
+	if stmt.Stmt = p.parseStatement(); isNil(stmt.Stmt) {
+		return nil
+	}
+
+	for _, attr := range stmt.Attributes {
+		if attr.Name.Value != "cfg" {
+			continue
+		}
+		if len(attr.Args) != 1 {
+			p.reportAt(attr.Token.Loc, "cfg expects a single name")
+			return nil
+		}
+		if _, ok := attr.Args[0].(*ast.Identifier); !ok {
+			p.reportAt(attr.Args[0].Location(), "cfg expects a single name")
+			return nil
+		}
+	}
+
+	return stmt
+}
+
+// statements are left out of the tree when
+// one of their cfg attributes is not defined
+func (p *Parser) included(stmt ast.Statement) bool {
+	attributed, ok := stmt.(*ast.AttributedStatement)
+	if !ok {
+		return true
+	}
+
+	for _, attr := range attributed.Attributes {
+		if attr.Name.Value == "cfg" && !p.options.Defines[attr.Args[0].TokenWord()] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// nodes of a kind in Disallow are reported where they are written
+func (p *Parser) permitted(node ast.Node) bool {
+	kind := node.Kind()
+	if str, ok := node.(*ast.StringLiteral); ok && str.Token.Raw {
+		kind = "RawStringLiteral"
+	}
+
+	if !p.options.Disallow[kind] {
+		return true
+	}
+	p.reportAt(node.Location(), fmt.Sprintf("feature not permitted: %q", kind))
+
+	return false
+}
+
+func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
+	tok := p.currToken
+
+	expr := p.ParseExpression(NONE)
+	if expr == nil {
+		return nil
+	}
+
+	return p.finishExpressionStatement(tok, expr)
+}
+
+// ends the statement whose expression was parsed up to the current token
+func (p *Parser) finishExpressionStatement(tok token.Token, expr ast.Expression) *ast.ExpressionStatement {
+	stmt := &ast.ExpressionStatement{Token: tok, Expression: expr}
+
+	// the value of a do block may leave out the ';'
+	if p.valueBlock && p.peekToken(token.RBRACE) {
+		if !p.terminated() {
+			p.unterminated = stmt
+		}
+		return stmt
+	}
+
+	if !p.expectTerminator() {
+		return nil
+	}
+
+	if p.options.RejectUselessExpressions && isOperation(expr) {
+		p.reportAt(expr.Location(), fmt.Sprintf("result of %s is not used", expr))
+	}
+
+	return stmt
+}
+
+func (p *Parser) parsePrefixExpression() ast.Expression {
+	expr := &ast.PrefixExpression{
+		Token:    p.currToken,
+		Operator: p.currToken.Word,
+	}
+	p.readToken()
+	right := p.ParseExpression(PREFIX)
+	if right == nil {
+		return nil
+	}
+	expr.Right = right
+
+	if p.options.FoldNegativeLiterals && expr.Token.Type == token.MINUS {
+		// operators binding tighter than '-' are already inside
+		// right, so `-2 ** 2` keeps its prefix expression, and a
+		// literal folded before is left alone so `--5` stays as it is
+		tok := token.Token{Loc: expr.Token.Loc, Type: token.INT}
+
+		switch lit := right.(type) {
+		case *ast.IntegerLiteral:
+			if !strings.HasPrefix(lit.Token.Word, "-") {
+				tok.Word = "-" + lit.Token.Word
+				return &ast.IntegerLiteral{Token: tok, Value: -lit.Value}
+			}
+		case *ast.FloatLiteral:
+			if !strings.HasPrefix(lit.Token.Word, "-") {
+				tok.Type, tok.Word = token.FLOAT, "-"+lit.Token.Word
+				return &ast.FloatLiteral{Token: tok, Value: -lit.Value}
+			}
+		}
+	}
+
+	return expr
+}
+
+func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
+	expr := &ast.InfixExpression{
+		Token:    p.currToken,
+		Operator: p.currToken.Word,
+		OpType:   p.currToken.Type,
+		Left:     left,
+	}
+
+	// get current token's precedence
+	precedence := PrecedenceOf(p.currToken.Type)
+	// powers are right associative, `a ** b ** c` is `a ** (b ** c)`
+	if p.hasToken(token.POW) {
+		precedence--
+	}
+	// consume current token
+	p.readToken()
+	// start parsing the next token and use current token's precedence
+	right := p.ParseExpression(precedence)
+	if right == nil {
+		return nil
+	}
+	expr.Right = right
+
+	return expr
+}
+
+// parses a comparison, with ChainComparisons each further comparison
+// operator compares the previous operand and the results are joined
+// with '&&', the shared operands are evaluated again for each
+func (p *Parser) parseComparison(left ast.Expression) ast.Expression {
+	expr := p.parseInfixExpression(left)
+	if expr == nil || !p.options.ChainComparisons {
+		return expr
+	}
+
+	chain := expr
+	for isComparison(p.nextToken.Type) {
+		p.readToken()
+		loc := p.currToken.Loc
+		next := p.parseInfixExpression(expr.(*ast.InfixExpression).Right)
+		if next == nil {
+			return nil
+		}
+
+		chain = &ast.InfixExpression{
+			Token:    token.Token{Loc: loc, Type: token.AND, Word: "&&"},
+			Operator: "&&",
+			OpType:   token.AND,
+			Left:     chain,
+			Right:    next,
+		}
+		expr = next
+	}
+
+	return chain
+}
+
+func isComparison(tokType token.TokenType) bool {
+	switch tokType {
+	case token.LT, token.LE, token.GT, token.GE:
+		return true
+	}
+
+	return false
+}
+
+// parses `x between a and b`, the bounds bind tighter than
+// the `and` connector so it cannot end up inside them
+func (p *Parser) parseBetweenExpression(value ast.Expression) ast.Expression {
+	expr := &ast.BetweenExpression{
+		Token: p.currToken,
+		Value: value,
+	}
+
+	// consume 'between' token
+	p.readToken()
+	if expr.Low = p.ParseExpression(COMPARE); expr.Low == nil {
+		return nil
+	}
+
+	if !p.peekToken(token.AND) || p.nextToken.Word != "and" {
+		p.report(fmt.Sprintf("expected \"and\" after the lower bound of between, got %q instead",
+			p.nextToken.Word))
+		return nil
+	}
+	p.readToken()
+
+	// consume 'and' token
+	p.readToken()
+	if expr.High = p.ParseExpression(COMPARE); expr.High == nil {
+		return nil
+	}
+
+	return expr
+}
+
+// parses `a unless cond else b` into an if expression
+// choosing b when cond holds
+func (p *Parser) parseUnlessExpression(value ast.Expression) ast.Expression {
+	expr := &ast.IfExpression{Token: p.currToken, Then: value}
+
+	// consume 'unless' token
+	p.readToken()
+	condition := p.ParseExpression(GUARD)
+	if condition == nil {
+		return nil
+	}
+	expr.Condition = &ast.PrefixExpression{
+		Token:    token.Token{Loc: expr.Token.Loc, Type: token.BANG, Word: "not"},
+		Operator: "not",
+		Right:    condition,
+	}
+
+	if !p.peekToken(token.ELSE) {
+		p.report(fmt.Sprintf("expected 'else' after the unless condition, got %q", p.nextToken.Word))
+		return nil
+	}
+	p.readToken()
+
+	// consume 'else' token, guards nest to the right
+	p.readToken()
+	if expr.Else = p.ParseExpression(ASSIGN); expr.Else == nil {
+		return nil
+	}
+
+	return expr
+}
+
+// a '?' followed by an expression is a ternary `c ? a : b`, which
+// becomes an IfExpression, anything else after it makes it the postfix
+// try operator as in `f()?.g()?`, see isTry for where it is decided
+func (p *Parser) parseQuestion(left ast.Expression) ast.Expression {
+	if p.isTry(p.prevToken, 0) {
+		return &ast.TryExpression{Token: p.currToken, Value: left}
+	}
+
+	expr := &ast.IfExpression{Token: p.currToken, Condition: left}
+
+	// consume '?' token
+	p.readToken()
+	if expr.Then = p.ParseExpression(ASSIGN); expr.Then == nil {
+		return nil
+	}
+
+	if !p.expectToken(token.COLON) {
+		return nil
+	}
+
+	// consume ':' token, ternaries nest to the right
+	p.readToken()
+	if expr.Else = p.ParseExpression(ASSIGN); expr.Else == nil {
+		return nil
+	}
+
+	return expr
+}
+
+// whether the '?' at peekN(k), after the token prev, is the postfix
+// try operator rather than the start of a ternary. It is the ternary
+// only when an expression starts after it, where a '{' is taken as the
+// start of a block, so `if x? { ... }` does not read the block as a
+// value. Tokens that may also continue the expression before, `-` `+`
+// `(` and `[`, are told apart by the space before the '?', so `a? - 1`
+// and `f()?[0]` continue a try while `a ? -1 : 0` is a ternary. The
+// space is found from the position and length of prev, so the try
+// operator right after a string literal, whose word is not its source,
+// reads as spaced. After a line break the ternary needs its ':' ahead,
+// see ternaryAhead.
+func (p *Parser) isTry(prev token.Token, k int) bool {
+	question, next := p.peekN(k), p.peekN(k+1)
+
+	switch {
+	case next.Type == token.LBRACE || p.entry(next.Type).prefix == nil:
+		return true
+	case next.Loc.Line != question.Loc.Line:
+		return !p.ternaryAhead(k + 1)
+	case p.entry(next.Type).infix != nil:
+		return prev.Loc.Line == question.Loc.Line &&
+			prev.Loc.Col+uint(len(prev.Word)) == question.Loc.Col
+	}
+
+	return false
+}
+
+// whether a ':' follows peekN(k) before the statement ends, outside of
+// any brackets opened after it. With AutoSemicolon the search stops at
+// the end of the line, as the ':' would start a line of its own.
+func (p *Parser) ternaryAhead(k int) bool {
+	line := p.peekN(k).Loc.Line
+	depth := 0
+
+	for ; ; k++ {
+		tok := p.peekN(k)
+		if tok.Loc.Line != line {
+			if p.options.AutoSemicolon && !tok.Joined {
+				return false
+			}
+			line = tok.Loc.Line
+		}
+
+		switch tok.Type {
+		case token.COLON:
+			if depth == 0 {
+				return true
+			}
+		case token.LPAREN, token.LBRACKET, token.LBRACE:
+			depth++
+		case token.RPAREN, token.RBRACKET, token.RBRACE:
+			if depth == 0 {
+				return false
+			}
+			depth--
+		case token.SEMCOL:
+			if depth == 0 {
+				return false
+			}
+		case token.EOF:
+			return false
+		}
+	}
+}
+
+// `value then |x| body` passes value through a lambda, taking a single
+// parameter between '|' and the expression it returns. It becomes a
+// call of a function literal, so `a then |x| f(x)` is
+// `fn(x) { return f(x); }(a)`. Pipelines chain from the left.
+func (p *Parser) parsePipelineExpression(value ast.Expression) ast.Expression {
+	call := &ast.CallExpression{Token: p.currToken, Arguments: []ast.Expression{value}}
+
+	if !p.expectToken(token.PIPE) {
+		return nil
+	}
+	fn := &ast.FunctionLiteral{Token: p.currToken}
+
+	if !p.expectToken(token.IDENT) {
+		return nil
+	}
+	fn.Parameters = []*ast.Param{{Ident: &ast.Identifier{Token: p.currToken, Value: p.currToken.Word}}}
+
+	if !p.expectToken(token.PIPE) {
+		return nil
+	}
+
+	// consume the closing '|'
+	p.readToken()
+	tok := p.currToken
+	body := p.ParseExpression(PIPELINE)
+	if body == nil {
+		return nil
+	}
+
+	fn.Body = returnBlock(tok, body)
+	call.Callee = fn
+
+	return call
+}
+
+func (p *Parser) parseRangeExpression(low ast.Expression) ast.Expression {
+	expr := &ast.RangeExpression{
+		Token:     p.currToken,
+		Low:       low,
+		Inclusive: p.hasToken(token.DOTDOTEQ),
+	}
+
+	// consume '..' token
+	p.readToken()
+	if expr.High = p.ParseExpression(RANGE); expr.High == nil {
+		return nil
+	}
+
+	// `a..b..c` has no meaning either way round
+	if p.peekToken(token.DOTDOT) || p.peekToken(token.DOTDOTEQ) {
+		p.report("ranges cannot be chained")
+		return nil
+	}
+
+	return expr
+}
+
+// parses `typeof x`, binding as tightly as the other prefix operators
+func (p *Parser) parseTypeofExpression() ast.Expression {
+	expr := &ast.TypeofExpression{Token: p.currToken}
+
+	// consume 'typeof' token
+	p.readToken()
+	if expr.Right = p.ParseExpression(PREFIX); expr.Right == nil {
+		return nil
+	}
+
+	return expr
+}
+
+// parses `x is T` and `x is not T`
+func (p *Parser) parseTypeTestExpression(value ast.Expression) ast.Expression {
+	expr := &ast.TypeTestExpression{
+		Token: p.currToken,
+		Value: value,
+	}
+
+	if p.peekToken(token.BANG) && p.nextToken.Word == "not" {
+		expr.Negated = true
+		p.readToken()
+	}
+
+	if !p.expectToken(token.IDENT) {
+		return nil
+	}
+	expr.Type = &ast.Identifier{Token: p.currToken, Value: p.currToken.Word}
+
+	return expr
+}
+
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	expr := &ast.AssignExpression{
+		Token:    p.currToken,
+		Operator: p.currToken.Word,
+		Target:   target,
+	}
+
+	if !isAssignable(target) {
+		p.reportAt(target.Location(), fmt.Sprintf("cannot assign to %s", target))
+		return nil
+	}
+
+	// consume assignment operator
+	p.readToken()
+	// assignments are right associative, `a = b = c` assigns to b first
+	value := p.ParseExpression(NONE)
+	if value == nil {
+		return nil
+	}
+	expr.Value = value
 
-	if !p.expectToken(token.RPAREN) {
+	return expr
+}
+
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	expr := &ast.IndexExpression{Token: p.currToken, Left: left}
+
+	// consume '['
+	p.readToken()
+
+	index := p.ParseExpression(NONE)
+	if index == nil {
+		return nil
+	}
+	expr.Index = index
+
+	p.expectClosing(token.RBRACKET)
+
+	return expr
+}
+
+func (p *Parser) parseMemberExpression(left ast.Expression) ast.Expression {
+	expr := &ast.MemberExpression{Token: p.currToken, Object: left}
+
+	if !p.expectToken(token.IDENT) {
 		return nil
 	}
+	expr.Property = &ast.Identifier{Token: p.currToken, Value: p.currToken.Word}
+
+	return expr
+}
+
+// commas only form a sequence inside parentheses, so they never
+// clash with argument lists and `a, b;` alone is not a statement
+func (p *Parser) parseGroupedExpression() ast.Expression {
+	tok := p.currToken
+	p.readToken()
+
+	expr := p.ParseExpression(NONE)
+	if expr == nil {
+		return nil
+	}
+
+	if p.peekToken(token.COMMA) {
+		seq := &ast.SequenceExpression{Token: tok, Expressions: []ast.Expression{expr}}
+		for p.matchToken(token.COMMA) {
+			p.readToken()
+			if expr = p.ParseExpression(NONE); expr == nil {
+				return nil
+			}
+			seq.Expressions = append(seq.Expressions, expr)
+		}
+		expr = seq
+	} else if p.options.PreserveParens {
+		expr = &ast.GroupExpression{Token: tok, Inner: expr}
+	}
+
+	p.expectClosing(token.RPAREN)
 
 	return expr
 }
@@ -392,7 +1824,7 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 func (p *Parser) parseCallExpression(callee ast.Expression) ast.Expression {
 	expr := &ast.CallExpression{Token: p.currToken, Callee: callee}
 
-	args := p.parseCallArguments()
+	args := p.parseCallArguments(&expr.NamedArgs)
 	if args == nil {
 		return nil
 	}
@@ -401,33 +1833,136 @@ func (p *Parser) parseCallExpression(callee ast.Expression) ast.Expression {
 	return expr
 }
 
-func (p *Parser) parseCallArguments() []ast.Expression {
-	// p.readToken() // consume '('
-
+// parses the arguments up to ')', `name: value` arguments are
+// gathered into named when it is not nil and must come last
+func (p *Parser) parseCallArguments(named *[]*ast.NamedArg) []ast.Expression {
 	args := []ast.Expression{}
-	for {
-		if p.peekToken(token.RPAREN) {
+
+	if p.peekToken(token.COMMA) {
+		p.report("unexpected ',' before the first argument")
+		return nil
+	}
+
+	for !p.peekToken(token.RPAREN) {
+		p.readToken()
+
+		if named != nil && p.hasToken(token.IDENT) && p.peekToken(token.COLON) {
+			if !p.parseNamedArgument(named) {
+				return nil
+			}
+		} else if named != nil && len(*named) != 0 {
+			p.reportAt(p.currToken.Loc, "positional argument after named arguments")
+			return nil
+		} else {
+			arg := p.ParseExpression(NONE)
+			if arg == nil {
+				return nil
+			}
+			args = append(args, arg)
+		}
+
+		if !p.matchToken(token.COMMA) {
+			// another expression means the ',' was left out, anything
+			// else is treated as the end of the call
+			if p.entry(p.nextToken.Type).prefix != nil {
+				p.report(fmt.Sprintf("expected ',' between arguments, got %q", p.nextToken.Word))
+				return nil
+			}
 			break
 		}
+
+		switch {
+		case p.peekToken(token.COMMA):
+			p.report("empty argument between ','")
+			return nil
+		case p.peekToken(token.RPAREN) && !p.options.TrailingCommas:
+			p.reportAt(p.currToken.Loc, "trailing ',' after the last argument")
+			return nil
+		}
+	}
+
+	p.expectClosing(token.RPAREN)
+
+	return args
+}
+
+func (p *Parser) parseNamedArgument(named *[]*ast.NamedArg) bool {
+	arg := &ast.NamedArg{Name: &ast.Identifier{Token: p.currToken, Value: p.currToken.Word}}
+
+	for _, other := range *named {
+		if other.Name.Value == arg.Name.Value {
+			p.reportAt(arg.Name.Location(), fmt.Sprintf("argument %q given more than once", arg.Name.Value))
+			return false
+		}
+	}
+
+	// consume the name and ':'
+	p.readToken()
+	p.readToken()
+	if arg.Value = p.ParseExpression(NONE); arg.Value == nil {
+		return false
+	}
+	*named = append(*named, arg)
+
+	return true
+}
+
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.currToken, Elements: []*ast.Element{}}
+
+	for !p.peekToken(token.RBRACKET) {
 		p.readToken()
 
-		arg := p.ParseExpression(NONE)
-		if arg == nil {
+		elem := &ast.Element{}
+		if p.hasToken(token.ELLIPSIS) {
+			elem.Spread = true
+			p.readToken()
+		}
+
+		if elem.Value = p.ParseExpression(NONE); elem.Value == nil {
 			return nil
 		}
 
-		args = append(args, arg)
+		// `[value; count]` repeats a single element
+		if len(array.Elements) == 0 && !elem.Spread && p.peekToken(token.SEMCOL) {
+			return p.parseArrayRepeat(array.Token, elem.Value)
+		}
+
+		array.Elements = append(array.Elements, elem)
 		if !p.peekToken(token.COMMA) {
 			break
 		}
 		p.readToken()
 	}
 
-	if !p.expectToken(token.RPAREN) {
+	p.expectClosing(token.RBRACKET)
+
+	return array
+}
+
+func (p *Parser) parseArrayRepeat(tok token.Token, value ast.Expression) ast.Expression {
+	expr := &ast.ArrayRepeatExpression{Token: tok, Value: value}
+
+	// consume ';' token
+	p.readToken()
+	p.readToken()
+	if expr.Count = p.ParseExpression(NONE); expr.Count == nil {
 		return nil
 	}
 
-	return args
+	count, ok := ast.ConstantInt(expr.Count)
+	if !ok {
+		p.reportAt(expr.Count.Location(), "array repeat count must be a constant integer")
+		return nil
+	}
+	if count < 0 {
+		p.reportAt(expr.Count.Location(), fmt.Sprintf("array repeat count must not be negative, got %d", count))
+		return nil
+	}
+
+	p.expectClosing(token.RBRACKET)
+
+	return expr
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
@@ -444,18 +1979,22 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	parameters := p.parseFunctionParameters()
-	if parameters == nil {
+	if !p.parseFunctionParameters(fn) {
 		return nil
 	}
 
-	fn.Parameters = parameters
+	if p.matchToken(token.ARROW) {
+		if fn.Body = p.parseArrowBody(); fn.Body == nil {
+			return nil
+		}
+		return fn
+	}
 
 	if !p.expectToken(token.LBRACE) {
 		return nil
 	}
 
-	body := p.parseBlockStatement()
+	body := p.parseFunctionBody()
 	if body == nil {
 		return nil
 	}
@@ -464,32 +2003,134 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return fn
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	idents := []*ast.Identifier{}
+// parses the expression after '=>' into a body returning it
+func (p *Parser) parseArrowBody() *ast.BlockStatement {
+	defer func(labels []label, loops int, jumps []token.Token) {
+		p.labels, p.loops, p.jumps = labels, loops, jumps
+		p.functions--
+	}(p.labels, p.loops, p.jumps)
+	p.labels, p.loops, p.jumps = nil, 0, nil
+	p.functions++
+
+	tok := p.currToken
+	// consume '=>' token
+	p.readToken()
+	expr := p.ParseExpression(NONE)
+	if expr == nil {
+		return nil
+	}
+
+	return returnBlock(tok, expr)
+}
+
+// a function body made of `return value;`
+func returnBlock(tok token.Token, value ast.Expression) *ast.BlockStatement {
+	return &ast.BlockStatement{
+		Token: tok,
+		Statements: []ast.Statement{
+			&ast.ReturnStatement{Token: tok, ReturnValue: value, ReturnValues: []ast.Expression{value}},
+		},
+	}
+}
+
+func (p *Parser) parseDoExpression() ast.Expression {
+	expr := &ast.DoExpression{Token: p.currToken}
+
+	if !p.expectToken(token.LBRACE) {
+		return nil
+	}
+
+	body := p.parseValueBlock("do block")
+	if body == nil {
+		return nil
+	}
+	expr.Body = body
+
+	return expr
+}
+
+// a '{' where an expression is expected opens a block expression,
+// at the start of a statement it stays a block statement
+func (p *Parser) parseBlockExpression() ast.Expression {
+	expr := &ast.BlockExpression{Token: p.currToken}
+
+	body := p.parseValueBlock("block expression")
+	if body == nil {
+		return nil
+	}
+	expr.Body = body
+
+	return expr
+}
+
+func (p *Parser) parseFunctionParameters(fn *ast.FunctionLiteral) bool {
+	fn.Parameters = []*ast.Param{}
+	hasDefault := false
 
 	for {
 		if p.peekToken(token.RPAREN) {
 			break
 		}
 
+		// '...' marks the parameter collecting remaining arguments
+		if p.matchToken(token.ELLIPSIS) {
+			fn.Variadic = true
+		}
+
 		if !p.expectToken(token.IDENT) {
-			return nil
+			return false
 		}
 
-		ident := &ast.Identifier{Token: p.currToken, Value: p.currToken.Word}
-		idents = append(idents, ident)
+		param := &ast.Param{
+			Ident: &ast.Identifier{Token: p.currToken, Value: p.currToken.Word},
+		}
+
+		for _, other := range fn.Parameters {
+			if other.Ident.Value == param.Ident.Value {
+				p.reportAt(param.Ident.Location(), fmt.Sprintf("duplicate parameter %q", param.Ident.Value))
+				return false
+			}
+		}
+		fn.Parameters = append(fn.Parameters, param)
+
+		if p.peekToken(token.ASSIGN) {
+			if fn.Variadic {
+				p.report(fmt.Sprintf(
+					"variadic parameter %q cannot have a default value", param.Ident.Value))
+				return false
+			}
+			p.readToken() // read '='
+			p.readToken()
+
+			param.Default = p.ParseExpression(ASSIGN)
+			if param.Default == nil {
+				return false
+			}
+			hasDefault = true
+		} else if hasDefault && !fn.Variadic {
+			p.reportAt(param.Ident.Location(), fmt.Sprintf(
+				"required parameter %q cannot follow a parameter with a default value",
+				param.Ident.Value))
+			return false
+		}
 
 		if !p.peekToken(token.COMMA) {
 			break
 		}
+
+		if fn.Variadic {
+			p.reportAt(param.Ident.Location(), fmt.Sprintf(
+				"variadic parameter %q must be the last parameter", param.Ident.Value))
+			return false
+		}
 		p.readToken() // read ','
 	}
 
 	if !p.expectToken(token.RPAREN) {
-		return nil
+		return false
 	}
 
-	return idents
+	return true
 }
 
 func (p *Parser) parseStringLiteral() ast.Expression {
@@ -499,14 +2140,88 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 	}
 }
 
+// splits the template into its text and the interpolated expressions,
+// each expression is parsed by its own parser placed at its location
+func (p *Parser) parseTemplateLiteral() ast.Expression {
+	tmpl := &ast.TemplateLiteral{Token: p.currToken}
+	word := p.currToken.Word
+
+	// the word starts after the opening backtick
+	loc := p.currToken.Loc
+	loc.Col++
+
+	text, failed := "", false
+	for i := 0; i < len(word); {
+		if !strings.HasPrefix(word[i:], "${") {
+			text += word[i : i+1]
+			loc = p.advanceLoc(loc, word[i:i+1])
+			i++
+			continue
+		}
+
+		loc = p.advanceLoc(loc, "${")
+		end := i + 2 + interpolationLen(word[i+2:])
+		source := word[i+2 : end]
+
+		value := p.parseInterpolation(loc, source)
+		if value == nil {
+			failed = true
+		}
+
+		tmpl.Strings = append(tmpl.Strings, text)
+		tmpl.Values = append(tmpl.Values, value)
+
+		text = ""
+		loc = p.advanceLoc(loc, source+"}")
+		i = end + 1
+	}
+	tmpl.Strings = append(tmpl.Strings, text)
+
+	if failed {
+		return nil
+	}
+
+	return tmpl
+}
+
+// parses the source of a single interpolation found at loc
+func (p *Parser) parseInterpolation(loc token.SrcLoc, source string) ast.Expression {
+	// located and keyworded as the template around it, the tokens
+	// the parser has no use for are left to the outer lexer
+	options := p.lexer.Options()
+	options.EmitNewlines, options.CollapseNewlines = false, false
+	options.Trivia, options.CheckIndentation = false, false
+
+	sub := NewWithOptions(lexer.NewAt(loc, source, options), p.options)
+	// `${` and `}` enclose the expression like brackets
+	sub.brackets = 1
+
+	value := sub.ParseExpression(NONE)
+	if value != nil && !sub.peekToken(token.EOF) {
+		sub.report(fmt.Sprintf("unexpected %q in interpolation", sub.nextToken.Word))
+		value = nil
+	}
+
+	p.errors = append(p.errors, sub.errors...)
+	return value
+}
+
+// the lexer only produces an error token with its message as the word
+func (p *Parser) parseLexError() ast.Expression {
+	p.reportAt(p.currToken.Loc, p.currToken.Word)
+	return nil
+}
+
 func (p *Parser) parseIntegerLiteral() ast.Expression {
 	l := &ast.IntegerLiteral{Token: p.currToken}
 
 	value, err := strconv.ParseInt(p.currToken.Word, 0, 64)
+	if errors.Is(err, strconv.ErrRange) && p.options.IntOverflow != OverflowError {
+		return p.parseOverflowedInteger()
+	}
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer. %s",
-			p.currToken.Word, err)
-		p.errors = append(p.errors, msg)
+		p.reportAt(p.currToken.Loc, fmt.Sprintf("could not parse %q as integer. %s",
+			p.currToken.Word, err))
 		return nil
 	}
 
@@ -515,14 +2230,25 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return l
 }
 
+// the word of the current token is a valid integer, only too large
+func (p *Parser) parseOverflowedInteger() ast.Expression {
+	value, _ := new(big.Int).SetString(p.currToken.Word, 0)
+
+	if p.options.IntOverflow == OverflowToBigInt {
+		return &ast.BigIntLiteral{Token: p.currToken, Value: value}
+	}
+
+	float, _ := new(big.Float).SetInt(value).Float64()
+	return &ast.FloatLiteral{Token: p.currToken, Value: float}
+}
+
 func (p *Parser) parseFloatLiteral() ast.Expression {
 	l := &ast.FloatLiteral{Token: p.currToken}
 
 	value, err := strconv.ParseFloat(p.currToken.Word, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as float. %s",
-			p.currToken.Word, err)
-		p.errors = append(p.errors, msg)
+		p.reportAt(p.currToken.Loc, fmt.Sprintf("could not parse %q as float. %s",
+			p.currToken.Word, err))
 		return nil
 	}
 
@@ -556,6 +2282,45 @@ func (p *Parser) expectToken(tokenType token.TokenType) bool {
 	return true
 }
 
+// a missing closing bracket is reported and then assumed
+// to be there, so the rest of the expression still parses
+func (p *Parser) expectClosing(tokenType token.TokenType) {
+	if !p.matchToken(tokenType) {
+		p.report(fmt.Sprintf("missing '%s'", token.TokenString[tokenType]))
+		p.closedAtEOF = p.closedAtEOF || p.peekToken(token.EOF)
+	}
+}
+
+// statements end with ';' unless one is implied
+func (p *Parser) expectTerminator() bool {
+	if p.terminated() {
+		return true
+	}
+
+	return p.expectToken(token.SEMCOL)
+}
+
+// checks whether the statement ending at the current token is
+// terminated without a ';', by the end of REPL input or by a line
+// break or the end of input when automatic semicolons are on. The
+// end of input after an assumed closing bracket is only reported once.
+func (p *Parser) terminated() bool {
+	if (p.options.REPL || p.options.AutoSemicolon || p.closedAtEOF) && p.peekToken(token.EOF) {
+		return true
+	}
+
+	if p.options.AutoSemicolon && p.lineBreak() {
+		return true
+	}
+
+	return false
+}
+
+// the next token starts a new line not joined to this one with '\'
+func (p *Parser) lineBreak() bool {
+	return p.nextToken.Loc.Line > p.currToken.Loc.Line && !p.nextToken.Joined
+}
+
 func (p *Parser) hasToken(tokenType token.TokenType) bool {
 	return p.currToken.Type == tokenType
 }
@@ -564,22 +2329,200 @@ func (p *Parser) peekToken(tokType token.TokenType) bool {
 	return p.nextToken.Type == tokType
 }
 
+// the k-th token after currToken without consuming any, peekN(1) is
+// nextToken, the ones after it are held until readToken gets to them
+func (p *Parser) peekN(k int) token.Token {
+	if k <= 0 {
+		return p.currToken
+	}
+	if k == 1 {
+		return p.nextToken
+	}
+
+	for len(p.ahead) < k-1 {
+		p.ahead = append(p.ahead, p.lexToken())
+	}
+	return p.ahead[k-2]
+}
+
+func (p *Parser) lexToken() token.Token {
+	tok := p.lexer.NextToken()
+
+	// the EOF is read again and again at the end, kept once
+	if p.options.KeepTokens && (len(p.tokens) == 0 || p.tokens[len(p.tokens)-1].Type != token.EOF) {
+		p.tokens = append(p.tokens, tok)
+	}
+
+	return tok
+}
+
 func (p *Parser) readToken() {
-	p.currToken = p.nextToken
-	p.nextToken = p.lexer.NextToken()
+	p.prevToken, p.currToken = p.currToken, p.nextToken
+	if len(p.ahead) > 0 {
+		p.nextToken, p.ahead = p.ahead[0], p.ahead[1:]
+	} else {
+		p.nextToken = p.lexToken()
+	}
+
+	switch p.currToken.Type {
+	case token.LPAREN, token.LBRACKET:
+		p.brackets++
+	case token.RPAREN, token.RBRACKET:
+		p.brackets = max(p.brackets-1, 0)
+	case token.LBRACE:
+		p.braces++
+	case token.RBRACE:
+		p.braces = max(p.braces-1, 0)
+	}
 }
 
 func (p *Parser) peekError(tokenType token.TokenType) {
+	if p.peekToken(token.EOF) {
+		p.report(fmt.Sprintf("unexpected end of input, expected %q", token.TokenString[tokenType]))
+		return
+	}
+
 	p.report(fmt.Sprintf("expected next token to be %q, got %q instead",
 		token.TokenString[tokenType], p.nextToken.Word))
 }
 
 func (p *Parser) noPrefixFuncError(tokenType token.TokenType) {
-	p.report(fmt.Sprintf("no prefix parse function for %q found",
+	if tokenType == token.EOF {
+		p.reportAt(p.currToken.Loc, "unexpected end of input")
+		return
+	}
+	if tokenType >= token.TOTAL {
+		p.reportAt(p.currToken.Loc, fmt.Sprintf("unexpected token %q", p.currToken.Word))
+		return
+	}
+
+	p.reportAt(p.currToken.Loc, fmt.Sprintf("no prefix parse function for %q found",
 		token.TokenString[tokenType]))
 }
 
 func (p *Parser) report(message string) {
-	message = fmt.Sprintf("%s %s", p.nextToken.Loc, message)
-	p.errors = append(p.errors, message)
+	p.reportAt(p.nextToken.Loc, message)
+}
+
+func (p *Parser) reportAt(loc token.SrcLoc, message string) {
+	p.errors = append(p.errors, ParseError{Loc: loc, Message: message})
+}
+
+// skips the rest of a broken statement that started with braces
+// '{' open, leaving the parser on its last token. The blocks opened
+// inside it are skipped whole, the statement ends at a ';' or line
+// break outside of them or at the '}' closing the last of them,
+// unless an `else` carries it on.
+func (p *Parser) synchronize(braces int) {
+	for !p.hasToken(token.EOF) {
+		if p.braces == braces {
+			if p.hasToken(token.SEMCOL) || p.terminated() {
+				return
+			}
+		}
+
+		p.readToken()
+		if p.hasToken(token.RBRACE) && p.braces <= braces &&
+			!p.peekToken(token.ELSE) && !p.peekToken(token.ELIF) {
+			return
+		}
+	}
+}
+
+// operator expressions only compute a value, unlike
+// calls and assignments which may have an effect
+func isOperation(expr ast.Expression) bool {
+	switch e := expr.(type) {
+	case *ast.InfixExpression, *ast.PrefixExpression, *ast.BetweenExpression, *ast.TypeTestExpression,
+		*ast.TypeofExpression, *ast.RangeExpression:
+		return true
+	case *ast.GroupExpression:
+		return isOperation(e.Inner)
+	default:
+		return false
+	}
+}
+
+// names and elements reached by indexing can be assigned to,
+// as long as the chain of indexes starts from a name or a call
+func isAssignable(expr ast.Expression) bool {
+	switch e := expr.(type) {
+	case *ast.Identifier:
+		return true
+	case *ast.IndexExpression:
+		return isIndexable(e.Left)
+	case *ast.MemberExpression:
+		return isIndexable(e.Object)
+	default:
+		return false
+	}
+}
+
+func isIndexable(expr ast.Expression) bool {
+	switch e := expr.(type) {
+	case *ast.Identifier, *ast.CallExpression:
+		return true
+	case *ast.IndexExpression:
+		return isIndexable(e.Left)
+	case *ast.MemberExpression:
+		return isIndexable(e.Object)
+	default:
+		return false
+	}
+}
+
+// parse functions return typed nil pointers on failure,
+// which are not equal to nil once wrapped in an interface
+func isNil(node ast.Node) bool {
+	return node == nil || reflect.ValueOf(node).IsNil()
+}
+
+// the length of an interpolation up to its closing '}', the
+// lexer already made sure the braces inside are balanced
+func interpolationLen(source string) int {
+	depth := 1
+
+	for i := 0; i < len(source); i++ {
+		switch source[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		case '"':
+			// skip the string the way the lexer did, escapes and all
+			for i++; i < len(source) && source[i] != '"'; i++ {
+				if source[i] == '\\' {
+					i++
+				}
+			}
+		}
+	}
+
+	return len(source)
+}
+
+// moves loc past text, following its line breaks and tabs
+// the way the lexer counts them
+func (p *Parser) advanceLoc(loc token.SrcLoc, text string) token.SrcLoc {
+	options := p.lexer.Options()
+
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\n':
+			loc.Line++
+			loc.Col = 1
+			if options.ZeroBased {
+				loc.Col = 0
+			}
+		case '\t':
+			loc.Col += uint(options.TabWidth)
+		default:
+			loc.Col++
+		}
+	}
+
+	return loc
 }
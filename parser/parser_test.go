@@ -6,8 +6,12 @@ import (
 	"RoLang/token"
 
 	"fmt"
+	"math"
+	"math/big"
+	"reflect"
 	"regexp"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -79,6 +83,50 @@ let calladd = fn (x, y) { x + y; };
 	}
 }
 
+func TestLetWithoutValue(t *testing.T) {
+	l := lexer.New("parser_test_let", "let x; x = 1;")
+	p := New(l)
+
+	program := p.Parse()
+	checkErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] not *ast.LetStatement. got=%T", program.Statements[0])
+	}
+
+	testIdentifier(t, stmt.Ident, "x")
+	if stmt.InitValue != nil {
+		t.Errorf("stmt.InitValue not nil. got=%s", stmt.InitValue)
+	}
+	if found := stmt.String(); found != "let x;" {
+		t.Errorf("stmt.String() wrong. got=%q", found)
+	}
+
+	tests := []struct {
+		input  string
+		expect string
+	}{
+		{"let = 5;", `parser_test_let:1:5: expected next token to be "identifier", got "=" instead`},
+		{"let;", `parser_test_let:1:4: expected next token to be "identifier", got ";" instead`},
+		{"let x 5;", `parser_test_let:1:7: expected next token to be ";", got "5" instead`},
+		{"const x;", `parser_test_let:1:8: const "x" must be initialized`},
+	}
+
+	for _, test := range tests {
+		p := New(lexer.New("parser_test_let", test.input))
+		p.Parse()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected an error for %q", test.input)
+			continue
+		}
+		if msg := p.Errors()[0]; msg != test.expect {
+			t.Errorf("wrong error message for %q. expect=%q, got=%q", test.input, test.expect, msg)
+		}
+	}
+}
+
 func TestFunctionStatement(t *testing.T) {
 	input := "fn add(x, y) { x + y; }"
 
@@ -116,8 +164,144 @@ func TestFunctionStatement(t *testing.T) {
 	}
 }
 
+func TestVariadicParameters(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectParams []string
+		expectString string
+	}{
+		{"fn sum(...nums) { }", []string{"nums"}, "fn sum(...nums) {  }"},
+		{"fn log(level, ...args) { }", []string{"level", "args"}, "fn log(level, ...args) {  }"},
+		{"let f = fn(a, ...b) { };", []string{"a", "b"}, "let f = fn (a, ...b) {  };"},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_variadic", test.input)
+		p := New(l)
+
+		program := p.Parse()
+		checkErrors(t, p)
+
+		if n := len(program.Statements); n != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", n)
+		}
+
+		var fn *ast.FunctionLiteral
+		switch stmt := program.Statements[0].(type) {
+		case *ast.FunctionStatement:
+			fn = stmt.Value
+		case *ast.LetStatement:
+			fn = stmt.InitValue.(*ast.FunctionLiteral)
+		}
+
+		if !fn.Variadic {
+			t.Errorf("fn.Variadic is false for %q", test.input)
+		}
+
+		if !testFunctionParameterParsing(t, fn.Parameters, test.expectParams) {
+			return
+		}
+
+		if str := program.String(); str != test.expectString {
+			t.Errorf("program.String() wrong. got=%q, expect=%q", str, test.expectString)
+		}
+	}
+}
+
+func TestMisplacedVariadicParameter(t *testing.T) {
+	l := lexer.New("parser_test_variadic", "fn f(...a, b) { }")
+	p := New(l)
+
+	p.Parse()
+
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected an error for a variadic parameter that is not last")
+	}
+
+	expect := `parser_test_variadic:1:9: variadic parameter "a" must be the last parameter`
+	if msg := p.Errors()[0]; msg != expect {
+		t.Errorf("wrong error message. expect=%q, got=%q", expect, msg)
+	}
+}
+
+func TestDuplicateParameter(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect string
+	}{
+		{"fn f(x, x) { }", `parser_test_params:1:9: duplicate parameter "x"`},
+		{"let g = fn(a, b = 1, ...a) { };", `parser_test_params:1:25: duplicate parameter "a"`},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_params", test.input)
+		p := New(l)
+
+		p.Parse()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected an error for %q", test.input)
+			continue
+		}
+
+		if msg := p.Errors()[0]; msg != test.expect {
+			t.Errorf("wrong error message for %q. expect=%q, got=%q", test.input, test.expect, msg)
+		}
+	}
+}
+
+func TestDefaultParameters(t *testing.T) {
+	input := `fn greet(name, greeting = "hello", times = 1 + 1) { }`
+
+	l := lexer.New("parser_test_default", input)
+	p := New(l)
+
+	program := p.Parse()
+	checkErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.FunctionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] not *ast.FunctionStatement. got=%T", program.Statements[0])
+	}
+
+	params := stmt.Value.Parameters
+	if !testFunctionParameterParsing(t, params, []string{"name", "greeting", "times"}) {
+		return
+	}
+
+	if params[0].Default != nil {
+		t.Errorf("params[0].Default is not nil. got=%s", params[0].Default)
+	}
+
+	if !testPrimaryExpression(t, params[1].Default, "str(hello)") ||
+		!testInfixExpression(t, params[2].Default, 1, "+", 1) {
+		return
+	}
+
+	expect := `fn greet(name, greeting = "hello", times = (1 + 1)) {  }`
+	if str := program.String(); str != expect {
+		t.Errorf("program.String() wrong. got=%q, expect=%q", str, expect)
+	}
+}
+
+func TestRequiredAfterDefaultParameter(t *testing.T) {
+	l := lexer.New("parser_test_default", "fn f(a = 1, b) { }")
+	p := New(l)
+
+	p.Parse()
+
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected an error for a required parameter after a default")
+	}
+
+	expect := `parser_test_default:1:13: required parameter "b" cannot follow a parameter with a default value`
+	if msg := p.Errors()[0]; msg != expect {
+		t.Errorf("wrong error message. expect=%q, got=%q", expect, msg)
+	}
+}
+
 func TestReturnStatement(t *testing.T) {
-	input := `
+	input := `fn f() {
 return 5;
 return 10;
 return 10.233;
@@ -125,15 +309,16 @@ return x;
 return -2;
 return 1 + 2;
 return "hello";
-`
+}`
 	l := lexer.New("parser_test_return", input)
 	p := New(l)
 
 	program := p.Parse()
 	checkErrors(t, p)
 
-	if n := len(program.Statements); n != 7 {
-		t.Fatalf("program.Statements does not contain 7 statements. got=%d", n)
+	body := program.Statements[0].(*ast.FunctionStatement).Value.Body
+	if n := len(body.Statements); n != 7 {
+		t.Fatalf("body.Statements does not contain 7 statements. got=%d", n)
 	}
 
 	tests := []struct {
@@ -149,9 +334,9 @@ return "hello";
 	}
 
 	for i, test := range tests {
-		stmt, ok := program.Statements[i].(*ast.ReturnStatement)
+		stmt, ok := body.Statements[i].(*ast.ReturnStatement)
 		if !ok {
-			t.Fatalf("program.Statements[%d] not *ast.ReturnStatement. got=%T", i, program.Statements[i])
+			t.Fatalf("body.Statements[%d] not *ast.ReturnStatement. got=%T", i, body.Statements[i])
 		}
 
 		if !test.expectReturn(t, stmt.ReturnValue) {
@@ -160,6 +345,93 @@ return "hello";
 	}
 }
 
+func TestReturnValues(t *testing.T) {
+	tests := []struct {
+		input  string
+		values []string
+		expect string
+	}{
+		{"return a;", []string{"a"}, "return a;"},
+		{"return a, b;", []string{"a", "b"}, "return a, b;"},
+		{"return a + 1, b, c;", []string{"(a + 1)", "b", "c"}, "return (a + 1), b, c;"},
+		{"return (a, b);", []string{"(a, b)"}, "return (a, b);"},
+		{"return;", nil, "return;"},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_return_values", "fn f() { "+test.input+" }")
+		p := New(l)
+
+		program := p.Parse()
+		checkErrors(t, p)
+
+		body := program.Statements[0].(*ast.FunctionStatement).Value.Body
+		stmt, ok := body.Statements[0].(*ast.ReturnStatement)
+		if !ok {
+			t.Fatalf("body.Statements[0] not *ast.ReturnStatement. got=%T", body.Statements[0])
+		}
+
+		if len(stmt.ReturnValues) != len(test.values) {
+			t.Fatalf("%q: wrong number of values. expect=%d, got=%d", test.input, len(test.values), len(stmt.ReturnValues))
+		}
+		for i, value := range stmt.ReturnValues {
+			if value.String() != test.values[i] {
+				t.Errorf("%q: wrong value %d. expect=%q, got=%q", test.input, i, test.values[i], value.String())
+			}
+		}
+
+		// the single value stays in ReturnValue
+		if len(test.values) == 1 && stmt.ReturnValue != stmt.ReturnValues[0] {
+			t.Errorf("%q: ReturnValue not set", test.input)
+		}
+		if len(test.values) != 1 && stmt.ReturnValue != nil {
+			t.Errorf("%q: ReturnValue set to %s", test.input, stmt.ReturnValue)
+		}
+
+		if s := stmt.String(); s != test.expect {
+			t.Errorf("%q: wrong String. expect=%q, got=%q", test.input, test.expect, s)
+		}
+	}
+}
+
+func TestReturnOutsideFunction(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect string
+	}{
+		{"return 5;", "parser_test_return_outside:1:1: return outside of a function"},
+		{"return;", "parser_test_return_outside:1:1: return outside of a function"},
+		{"while a { return; }", "parser_test_return_outside:1:11: return outside of a function"},
+		{"fn f() { } return f;", "parser_test_return_outside:1:12: return outside of a function"},
+		{"let x = do { return 1; };", "parser_test_return_outside:1:14: return outside of a function"},
+		{"fn f() { return 5; }", ""},
+		{"fn f() { if a { return; } while b { return 1, 2; } }", ""},
+		{"let g = fn() { let h = fn() { return 1; }; return h; };", ""},
+		{"let k = fn(x) => do { if x { return x; } 0 };", ""},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_return_outside", test.input)
+		p := New(l)
+
+		p.Parse()
+
+		if test.expect == "" {
+			checkErrors(t, p)
+			continue
+		}
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected an error for %q", test.input)
+			continue
+		}
+
+		if msg := p.Errors()[0]; msg != test.expect {
+			t.Errorf("wrong error message for %q. expect=%q, got=%q", test.input, test.expect, msg)
+		}
+	}
+}
+
 func TestIfStatement(t *testing.T) {
 	input := `if x < y { x; }`
 
@@ -434,6 +706,8 @@ func TestPrefixExpression(t *testing.T) {
 		{"-15;", "-", 15},
 		{"!5.223;", "!", 5.223},
 		{"-10.23;", "-", 10.23},
+		{"+5;", "+", 5},
+		{"+a;", "+", "a"},
 	}
 
 	for _, test := range prefixIntTests {
@@ -659,6 +933,142 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestPrecedenceOf(t *testing.T) {
+	// each operator binds tighter than the one before it
+	order := []token.TokenType{token.ASSIGN, token.EQ, token.PLUS, token.STAR, token.LPAREN}
+
+	for i := 1; i < len(order); i++ {
+		lower, higher := PrecedenceOf(order[i-1]), PrecedenceOf(order[i])
+		if lower >= higher {
+			t.Errorf("%q does not bind tighter than %q. got=%d and %d",
+				token.TokenString[order[i]], token.TokenString[order[i-1]], higher, lower)
+		}
+	}
+
+	if prec := PrecedenceOf(token.SEMCOL); prec != NONE {
+		t.Errorf("';' does not have precedence NONE. got=%d", prec)
+	}
+
+	for _, tokenType := range []token.TokenType{token.TOTAL, token.TOTAL + 1, 1000} {
+		if prec := PrecedenceOf(tokenType); prec != NONE {
+			t.Errorf("%s does not have precedence NONE. got=%d", tokenType, prec)
+		}
+	}
+}
+
+func TestSignsAndExponents(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect string
+	}{
+		{"-1e-3", "(-0.001)"},
+		{"2 ** -3", "(2 ** (-3))"},
+		{"a - -b", "(a - (-b))"},
+		{"a--b", "(a - (-b))"},
+		{"--a", "(-(-a))"},
+		{"-2 ** 2", "(-(2 ** 2))"},
+		{"2 ** 3 ** 2", "(2 ** (3 ** 2))"},
+		{"2 * 3 ** 2", "(2 * (3 ** 2))"},
+		{"a ** b[0]", "(a ** (b[0]))"},
+		{"1.5e+2 - 2E2", "(150.0 - 200.0)"},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_signs", test.input+";")
+		p := New(l)
+
+		program := p.Parse()
+		checkErrors(t, p)
+
+		if found := program.String(); found != test.expect {
+			t.Errorf("%q - wrong tree. expect=%q, got=%q", test.input, test.expect, found)
+		}
+	}
+
+	// the shapes behind the strings
+	exprOf := func(input string) ast.Expression {
+		program, errs := Parse("parser_test_signs", input+";")
+		if len(errs) != 0 {
+			t.Fatalf("parser has errors: %v", errs)
+		}
+		return program.Statements[0].(*ast.ExpressionStatement).Expression
+	}
+
+	neg := exprOf("-1e-3").(*ast.PrefixExpression)
+	if lit, ok := neg.Right.(*ast.FloatLiteral); !ok || lit.Value != 0.001 {
+		t.Errorf("neg.Right not the float 0.001. got=%T %s", neg.Right, neg.Right)
+	}
+
+	pow := exprOf("2 ** -3").(*ast.InfixExpression)
+	testIntLiteral(t, pow.Left, 2)
+	testPrefixExpression(t, pow.Right, "-", 3)
+
+	sub := exprOf("a - -b").(*ast.InfixExpression)
+	testIdentifier(t, sub.Left, "a")
+	testPrefixExpression(t, sub.Right, "-", "b")
+}
+
+func TestFoldNegativeLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		folded   string
+		unfolded string
+	}{
+		{"-5", "-5", "(-5)"},
+		{"-1.5", "-1.5", "(-1.5)"},
+		{"a - -5", "(a - -5)", "(a - (-5))"},
+		{"--5", "(--5)", "(-(-5))"},
+		{"-2 ** 2", "(-(2 ** 2))", "(-(2 ** 2))"},
+		{"-x", "(-x)", "(-x)"},
+		{"!5", "(!5)", "(!5)"},
+	}
+
+	for _, test := range tests {
+		for _, fold := range []bool{true, false} {
+			l := lexer.New("parser_test_fold", test.input+";")
+			p := NewWithOptions(l, Options{FoldNegativeLiterals: fold})
+
+			program := p.Parse()
+			checkErrors(t, p)
+
+			expect := test.unfolded
+			if fold {
+				expect = test.folded
+			}
+
+			if found := program.String(); found != expect {
+				t.Errorf("%q, FoldNegativeLiterals %t - wrong tree. expect=%q, got=%q",
+					test.input, fold, expect, found)
+			}
+		}
+	}
+
+	program, errs := Parse("parser_test_fold", "-5;")
+	if len(errs) != 0 {
+		t.Fatalf("parser has errors: %v", errs)
+	}
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	testPrefixExpression(t, stmt.Expression, "-", 5)
+
+	l := lexer.New("parser_test_fold", "-5; -2.5;")
+	p := NewWithOptions(l, Options{FoldNegativeLiterals: true})
+	program = p.Parse()
+	checkErrors(t, p)
+
+	stmt = program.Statements[0].(*ast.ExpressionStatement)
+	testIntLiteral(t, stmt.Expression, -5)
+
+	stmt = program.Statements[1].(*ast.ExpressionStatement)
+	if lit, ok := stmt.Expression.(*ast.FloatLiteral); !ok || lit.Value != -2.5 {
+		t.Errorf("stmt.Expression not the float -2.5. got=%T %s", stmt.Expression, stmt.Expression)
+	}
+
+	// the literal starts at the '-'
+	if loc := stmt.Expression.Location(); loc.Col != 5 {
+		t.Errorf("folded literal at the wrong column. expect=5, got=%d", loc.Col)
+	}
+}
+
 func TestOperatorPrecedenceParsing(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -672,6 +1082,22 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"!-a",
 			"(!(-a))",
 		},
+		{
+			"+a * b",
+			"((+a) * b)",
+		},
+		{
+			"-+a",
+			"(-(+a))",
+		},
+		{
+			"+(a - b)",
+			"(+(a - b))",
+		},
+		{
+			"a + +b",
+			"(a + (+b))",
+		},
 		{
 			"a + b + c",
 			"((a + b) + c)",
@@ -748,59 +1174,2689 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"!(true == true)",
 			"(!(true == true))",
 		},
-	}
-
-	for _, test := range tests {
-		l := lexer.New("parser_test_operator_precedence", test.input)
-		p := New(l)
-
-		expr := p.ParseExpression(NONE)
-		checkErrors(t, p)
-
-		if found := expr.String(); found != test.expected {
-			t.Errorf("expected=%q, got=%q", test.expected, found)
-		}
-	}
-}
-
-func TestString(t *testing.T) {
-	program := &ast.Program{
-		Statements: []ast.Statement{
-			// let myVar = anotherVar;
-			// return myVar;
-			&ast.LetStatement{
-				Token: token.Token{
-					Type: token.LET,
-					Word: "let",
-				},
-				Ident: &ast.Identifier{
-					Token: token.Token{
-						Type: token.IDENT,
-						Word: "myVar",
-					},
-					Value: "myVar",
-				},
-				InitValue: &ast.Identifier{
-					Token: token.Token{
-						Type: token.IDENT,
-						Word: "anotherVar",
-					},
-					Value: "anotherVar",
-				},
-			},
-			&ast.ReturnStatement{
-				Token: token.Token{
-					Type: token.RETURN,
-					Word: "return",
-				},
-				ReturnValue: &ast.Identifier{
-					Token: token.Token{
-						Type: token.IDENT,
-						Word: "myVar",
-					},
-					Value: "myVar",
-				},
-			},
+		{
+			"a || b && c",
+			"(a || (b && c))",
+		},
+		{
+			"a == b && c < d",
+			"((a == b) && (c < d))",
+		},
+		{
+			"a or b and not c",
+			"(a or (b and (not c)))",
+		},
+		{
+			"x is int && y is not float || z",
+			"(((x is int) && (y is not float)) || z)",
+		},
+		{
+			"a + 1 is int == true",
+			"(((a + 1) is int) == true)",
+		},
+		{
+			"a unless b or c else d unless e else f",
+			"(if (not (b or c)) then a else (if (not e) then d else f))",
+		},
+		{
+			"a | b & c",
+			"(a | (b & c))",
+		},
+		{
+			"a & b | c",
+			"((a & b) | c)",
+		},
+		{
+			"a << 1 + b & c >> 2",
+			"((a << (1 + b)) & (c >> 2))",
+		},
+		{
+			"a | b == c",
+			"((a | b) == c)",
+		},
+		{
+			"~a & -b",
+			"((~a) & (-b))",
+		},
+		{
+			"x between 1 and 10 and y",
+			"((x between 1 and 10) and y)",
+		},
+		{
+			"a + 1 between b and c * 2 == d",
+			"(((a + 1) between b and (c * 2)) == d)",
+		},
+		{
+			`"a" + b + "c" == d`,
+			`((("a" + b) + "c") == d)`,
+		},
+		{
+			"`x${a + b}` + c * d",
+			"(`x${(a + b)}` + (c * d))",
+		},
+		{
+			"a+1 .. b*2",
+			"((a + 1) .. (b * 2))",
+		},
+		{
+			"0..=n - 1 == r",
+			"((0 ..= (n - 1)) == r)",
+		},
+		{
+			"a << 1 .. b | c",
+			"((a << 1) .. (b | c))",
+		},
+		{
+			"a <= b",
+			"(a <= b)",
+		},
+		{
+			"a <= b == c >= d",
+			"((a <= b) == (c >= d))",
+		},
+		{
+			"a + 1 >= b * 2 != c <= -d",
+			"(((a + 1) >= (b * 2)) != (c <= (-d)))",
+		},
+		{
+			"a.b + c",
+			"((a.b) + c)",
+		},
+		{
+			"a.b.c",
+			"((a.b).c)",
+		},
+		{
+			"a.b.c()",
+			"((a.b).c)()",
+		},
+		{
+			"-a.b[0].c(x).d",
+			"(-((((a.b)[0]).c)(x).d))",
+		},
+		{
+			"a + 1 <=> b * 2",
+			"((a + 1) <=> (b * 2))",
+		},
+		{
+			"a <=> b == 0",
+			"((a <=> b) == 0)",
+		},
+		{
+			"a <=> b < c",
+			"((a <=> b) < c)",
+		},
+		{
+			"a <=> b && c",
+			"((a <=> b) && c)",
+		},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_operator_precedence", test.input)
+		p := New(l)
+
+		expr := p.ParseExpression(NONE)
+		checkErrors(t, p)
+
+		if found := expr.String(); found != test.expected {
+			t.Errorf("expected=%q, got=%q", test.expected, found)
+		}
+	}
+}
+
+func TestREPLTerminator(t *testing.T) {
+	l := lexer.New("parser_test_repl", "let x = 5")
+	p := NewWithOptions(l, Options{REPL: true})
+
+	program := p.Parse()
+	checkErrors(t, p)
+
+	if n := len(program.Statements); n != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", n)
+	}
+
+	stmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] not *ast.LetStatement. got=%T", program.Statements[0])
+	}
+
+	if !testIdentifier(t, stmt.Ident, "x") || !testPrimaryExpression(t, stmt.InitValue, 5) {
+		return
+	}
+}
+
+func TestREPLMissingSeparator(t *testing.T) {
+	l := lexer.New("parser_test_repl", "let x = 5 let y")
+	p := NewWithOptions(l, Options{REPL: true})
+
+	p.Parse()
+
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected an error for the missing separator")
+	}
+
+	expect := `parser_test_repl:1:11: expected next token to be ";", got "let" instead`
+	if msg := p.Errors()[0]; msg != expect {
+		t.Errorf("wrong error message. expect=%q, got=%q", expect, msg)
+	}
+}
+
+func TestAutoSemicolon(t *testing.T) {
+	input := `let a = 1
+let b = a +
+	2
+fn f() {
+	return
+}
+a; b
+`
+	l := lexer.New("parser_test_asi", input)
+	p := NewWithOptions(l, Options{AutoSemicolon: true})
+
+	program := p.Parse()
+	checkErrors(t, p)
+
+	expects := []string{
+		"let a = 1;",
+		"let b = (a + 2);",
+		"fn f() { return; }",
+		"a",
+		"b",
+	}
+
+	if n := len(program.Statements); n != len(expects) {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d", len(expects), n)
+	}
+
+	for i, expect := range expects {
+		if found := program.Statements[i].String(); found != expect {
+			t.Errorf("program.Statements[%d] wrong. expect=%q, got=%q", i, expect, found)
+		}
+	}
+}
+
+func TestAutoSemicolonAtEOF(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect string
+	}{
+		{"a\nb", "ab"},
+		{"let x = 1", "let x = 1;"},
+		{"fn f() {\n\treturn\n}\nf()", "fn f() { return; }f()"},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_asi", test.input)
+		p := NewWithOptions(l, Options{AutoSemicolon: true})
+
+		program := p.Parse()
+		checkErrors(t, p)
+
+		if found := program.String(); found != test.expect {
+			t.Errorf("%q: wrong program. expect=%q, got=%q", test.input, test.expect, found)
+		}
+	}
+}
+
+func TestAutoSemicolonContinuation(t *testing.T) {
+	input := "let a = \"x\"\\\n\t+ \"y\"\nlet b = a\n"
+
+	l := lexer.New("parser_test_asi", input)
+	p := NewWithOptions(l, Options{AutoSemicolon: true})
+
+	program := p.Parse()
+	checkErrors(t, p)
+
+	if found := program.String(); found != `let a = ("x" + "y");let b = a;` {
+		t.Errorf("wrong program. got=%q", found)
+	}
+}
+
+func TestAutoSemicolonBrackets(t *testing.T) {
+	input := `let xs = [
+	1,
+	2 +
+	3
+	, f(
+		a
+		* b
+	)
+]
+let y = xs
+[0]
+let z = (
+	y
+	- 1
+)
+fn g() {
+	let w = [fn() {
+		return
+	}, 1]
+	w
+	(2)
+}
+let t = ` + "`${a\n+ b}`\n"
+
+	l := lexer.New("parser_test_asi", input)
+	p := NewWithOptions(l, Options{AutoSemicolon: true})
+
+	program := p.Parse()
+	checkErrors(t, p)
+
+	expects := []string{
+		"let xs = [1, (2 + 3), f((a * b))];",
+		"let y = xs;",
+		"[0]",
+		"let z = (y - 1);",
+		// w and (2) are statements of their own in the block
+		"fn g() { let w = [fn () { return; }, 1];w2 }",
+		"let t = `${(a + b)}`;",
+	}
+
+	if n := len(program.Statements); n != len(expects) {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d: %q", len(expects), n, program)
+	}
+
+	for i, expect := range expects {
+		if found := program.Statements[i].String(); found != expect {
+			t.Errorf("program.Statements[%d] wrong. expect=%q, got=%q", i, expect, found)
+		}
+	}
+
+	body := program.Statements[4].(*ast.FunctionStatement).Value.Body
+	if n := len(body.Statements); n != 3 {
+		t.Errorf("body.Statements does not contain 3 statements. got=%d", n)
+	}
+}
+
+func TestAutoSemicolonSameLine(t *testing.T) {
+	l := lexer.New("parser_test_asi", "let a = 1 let b = 2")
+	p := NewWithOptions(l, Options{AutoSemicolon: true})
+
+	p.Parse()
+
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected an error for the missing separator")
+	}
+
+	expect := `parser_test_asi:1:11: expected next token to be ";", got "let" instead`
+	if msg := p.Errors()[0]; msg != expect {
+		t.Errorf("wrong error message. expect=%q, got=%q", expect, msg)
+	}
+}
+
+func TestDisallow(t *testing.T) {
+	input := `let x = do { 1 };
+defer f();
+let s = """raw""";
+while x { defer g(); x = "ok"; }
+let y = x * 2;`
+
+	l := lexer.New("parser_test_disallow", input)
+	p := NewWithOptions(l, Options{Disallow: map[string]bool{
+		"DoExpression":     true,
+		"DeferStatement":   true,
+		"RawStringLiteral": true,
+	}})
+
+	program := p.Parse()
+
+	expects := []string{
+		`parser_test_disallow:1:9: feature not permitted: "DoExpression"`,
+		`parser_test_disallow:2:1: feature not permitted: "DeferStatement"`,
+		`parser_test_disallow:3:9: feature not permitted: "RawStringLiteral"`,
+		`parser_test_disallow:4:11: feature not permitted: "DeferStatement"`,
+	}
+
+	errors := p.Errors()
+	if len(errors) != len(expects) {
+		t.Fatalf("wrong number of errors. expect=%d, got=%d: %v", len(expects), len(errors), errors)
+	}
+
+	for i, expect := range expects {
+		if errors[i] != expect {
+			t.Errorf("wrong error message. expect=%q, got=%q", expect, errors[i])
+		}
+	}
+
+	// the banned statements are dropped, everything else is kept
+	found := []string{}
+	for _, stmt := range program.Statements {
+		found = append(found, stmt.String())
+	}
+	expect := []string{`while x { (x = "ok") }`, "let y = (x * 2);"}
+	if !reflect.DeepEqual(found, expect) {
+		t.Errorf("wrong statements. expect=%q, got=%q", expect, found)
+	}
+}
+
+func TestTemplateLiteral(t *testing.T) {
+	input := "`hello ${name}, you are ${ do { f(1) } + 1 }`;"
+
+	l := lexer.New("parser_test_template", input)
+	p := New(l)
+
+	program := p.Parse()
+	checkErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	tmpl, ok := stmt.Expression.(*ast.TemplateLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression not *ast.TemplateLiteral. got=%T", stmt.Expression)
+	}
+
+	expectStrings := []string{"hello ", ", you are ", ""}
+	if len(tmpl.Strings) != len(expectStrings) {
+		t.Fatalf("tmpl.Strings wrong. expect=%q, got=%q", expectStrings, tmpl.Strings)
+	}
+	for i, expect := range expectStrings {
+		if tmpl.Strings[i] != expect {
+			t.Errorf("tmpl.Strings[%d] wrong. expect=%q, got=%q", i, expect, tmpl.Strings[i])
+		}
+	}
+
+	if n := len(tmpl.Values); n != 2 {
+		t.Fatalf("tmpl.Values does not contain 2 expressions. got=%d", n)
+	}
+
+	testIdentifier(t, tmpl.Values[0], "name")
+	if found := tmpl.Values[1].String(); found != "(do { f(1) } + 1)" {
+		t.Errorf("tmpl.Values[1] wrong. got=%q", found)
+	}
+
+	// interpolated expressions are located inside the template
+	expectLoc := token.SrcLoc{File: "parser_test_template", Line: 1, Col: 10}
+	if loc := tmpl.Values[0].Location(); loc != expectLoc {
+		t.Errorf("tmpl.Values[0] wrong location. expect=%s, got=%s", expectLoc, loc)
+	}
+}
+
+func TestFreeKeywordType(t *testing.T) {
+	// keywords of a language variant the parser knows nothing about
+	extra := map[string]token.TokenType{"loop": token.TOTAL, "until": token.TOTAL + 1}
+
+	tests := []struct {
+		input  string
+		expect string
+	}{
+		{"loop { x; }", `parser_test_free:1:1: unexpected token "loop"`},
+		{"let x = 1 + until;", `parser_test_free:1:13: unexpected token "until"`},
+		{"f(1 until);", `parser_test_free:1:5: missing ')'`},
+		{"let y = a ? until : b;", `parser_test_free:1:13: expected next token to be ";", got "until" instead`},
+		{"x until;", `parser_test_free:1:3: expected next token to be ";", got "until" instead`},
+	}
+
+	for _, test := range tests {
+		p := New(lexer.NewWithKeywords("parser_test_free", test.input, extra))
+		p.Parse()
+
+		if errors := p.Errors(); len(errors) == 0 || errors[0] != test.expect {
+			t.Errorf("%q: wrong errors. expect=%q first, got=%q", test.input, test.expect, errors)
+		}
+	}
+}
+
+func TestTemplateEscapedQuote(t *testing.T) {
+	l := lexer.New("parser_test_template", "let x = `a ${\"\\\"}\" + b} c`;")
+	p := New(l)
+
+	program := p.Parse()
+	checkErrors(t, p)
+
+	tmpl := program.Statements[0].(*ast.LetStatement).InitValue.(*ast.TemplateLiteral)
+	if n := len(tmpl.Values); n != 1 {
+		t.Fatalf("tmpl.Values does not contain 1 expression. got=%d", n)
+	}
+
+	// the escaped quote neither ends the string nor the interpolation
+	if found := tmpl.Values[0].String(); found != `("\"}" + b)` {
+		t.Errorf("tmpl.Values[0] wrong. got=%q", found)
+	}
+	if expect := []string{"a ", " c"}; !reflect.DeepEqual(tmpl.Strings, expect) {
+		t.Errorf("tmpl.Strings wrong. expect=%q, got=%q", expect, tmpl.Strings)
+	}
+}
+
+func TestTemplateLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect string
+	}{
+		{"`a ${b + }`;", `parser_test_template:1:10: unexpected end of input`},
+		{"`a ${b c}`;", `parser_test_template:1:8: unexpected "c" in interpolation`},
+		{"`a ${b;", "parser_test_template:1:1: unterminated interpolation in template string"},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_template", test.input)
+		p := New(l)
+
+		p.Parse()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected an error for %q", test.input)
+			continue
+		}
+
+		if msg := p.Errors()[0]; msg != test.expect {
+			t.Errorf("wrong error message. expect=%q, got=%q", test.expect, msg)
+		}
+	}
+}
+
+func TestTemplateLiteralLocations(t *testing.T) {
+	// the interpolations count lines and columns as the lexer does
+	tests := []struct {
+		input   string
+		options lexer.Options
+		expect  string
+	}{
+		{"`a ${b c}`;", lexer.Options{ZeroBased: true}, `parser_test_template:0:7: unexpected "c" in interpolation`},
+		{"`a\n${b c}`;", lexer.Options{ZeroBased: true}, `parser_test_template:1:4: unexpected "c" in interpolation`},
+		{"`\t${b c}`;", lexer.Options{TabWidth: 4}, `parser_test_template:1:10: unexpected "c" in interpolation`},
+		{"`${\tb c}`;", lexer.Options{TabWidth: 4}, `parser_test_template:1:10: unexpected "c" in interpolation`},
+	}
+
+	for _, test := range tests {
+		p := New(lexer.NewWithOptions("parser_test_template", test.input, test.options))
+		p.Parse()
+
+		if errors := p.Errors(); len(errors) != 1 || errors[0] != test.expect {
+			t.Errorf("%q: wrong errors. expect=[%q], got=%q", test.input, test.expect, errors)
+		}
+	}
+}
+
+func TestBetweenExpression(t *testing.T) {
+	l := lexer.New("parser_test_between", "x between 1 and 10;")
+	p := New(l)
+
+	program := p.Parse()
+	checkErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	expr, ok := stmt.Expression.(*ast.BetweenExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression not *ast.BetweenExpression. got=%T", stmt.Expression)
+	}
+
+	testIdentifier(t, expr.Value, "x")
+	testPrimaryExpression(t, expr.Low, 1)
+	testPrimaryExpression(t, expr.High, 10)
+}
+
+func TestBetweenMissingAnd(t *testing.T) {
+	l := lexer.New("parser_test_between", "x between 1 10;")
+	p := New(l)
+
+	p.Parse()
+
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected an error for the missing and")
+	}
+
+	expect := `parser_test_between:1:13: expected "and" after the lower bound of between, got "10" instead`
+	if msg := p.Errors()[0]; msg != expect {
+		t.Errorf("wrong error message. expect=%q, got=%q", expect, msg)
+	}
+}
+
+func TestArrayLiteral(t *testing.T) {
+	tests := []struct {
+		input  string
+		spread []bool
+		expect string
+	}{
+		{"[1, ...xs, 2];", []bool{false, true, false}, "[1, ...xs, 2]"},
+		{"[...a, ...b];", []bool{true, true}, "[...a, ...b]"},
+		{"[];", []bool{}, "[]"},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_array", test.input)
+		p := New(l)
+
+		program := p.Parse()
+		checkErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		array, ok := stmt.Expression.(*ast.ArrayLiteral)
+		if !ok {
+			t.Fatalf("stmt.Expression not *ast.ArrayLiteral. got=%T", stmt.Expression)
+		}
+
+		if len(array.Elements) != len(test.spread) {
+			t.Fatalf("array.Elements does not contain %d elements. got=%d",
+				len(test.spread), len(array.Elements))
+		}
+
+		for i, spread := range test.spread {
+			if array.Elements[i].Spread != spread {
+				t.Errorf("array.Elements[%d].Spread wrong. expect=%t, got=%t",
+					i, spread, array.Elements[i].Spread)
+			}
+		}
+
+		if found := array.String(); found != test.expect {
+			t.Errorf("array.String() wrong. expect=%q, got=%q", test.expect, found)
+		}
+	}
+}
+
+func TestCfgAttribute(t *testing.T) {
+	input := `
+@cfg(DEBUG) let log = true;
+let x = 1;
+fn f() {
+	@cfg(DEBUG) log;
+	return x;
+}`
+
+	tests := []struct {
+		defines map[string]bool
+		expect  []string
+	}{
+		{
+			map[string]bool{"DEBUG": true},
+			[]string{"@cfg(DEBUG) let log = true;", "let x = 1;", "fn f() { @cfg(DEBUG) logreturn x; }"},
+		},
+		{
+			map[string]bool{"RELEASE": true},
+			[]string{"let x = 1;", "fn f() { return x; }"},
+		},
+		{
+			nil,
+			[]string{"let x = 1;", "fn f() { return x; }"},
+		},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_cfg", input)
+		p := NewWithOptions(l, Options{Defines: test.defines})
+
+		program := p.Parse()
+		checkErrors(t, p)
+
+		if len(program.Statements) != len(test.expect) {
+			t.Fatalf("program.Statements does not contain %d statements. got=%d",
+				len(test.expect), len(program.Statements))
+		}
+
+		for i, expect := range test.expect {
+			if found := program.Statements[i].String(); found != expect {
+				t.Errorf("program.Statements[%d] wrong. expect=%q, got=%q", i, expect, found)
+			}
+		}
+	}
+}
+
+func TestCfgAttributeErrors(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect string
+	}{
+		{"@cfg let x = 1;", "parser_test_cfg:1:1: cfg expects a single name"},
+		{"@cfg(A, B) let x = 1;", "parser_test_cfg:1:1: cfg expects a single name"},
+		{`@cfg("A") let x = 1;`, "parser_test_cfg:1:6: cfg expects a single name"},
+		{"@ let x = 1;", `parser_test_cfg:1:3: expected next token to be "identifier", got "let" instead`},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_cfg", test.input)
+		p := New(l)
+
+		p.Parse()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected an error for %q", test.input)
+			continue
+		}
+
+		if msg := p.Errors()[0]; msg != test.expect {
+			t.Errorf("wrong error message. expect=%q, got=%q", test.expect, msg)
+		}
+	}
+}
+
+func TestTypeTestExpression(t *testing.T) {
+	tests := []struct {
+		input   string
+		value   string
+		ty      string
+		negated bool
+	}{
+		{"x is int;", "x", "int", false},
+		{"x is not string;", "x", "string", true},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_is", test.input)
+		p := New(l)
+
+		program := p.Parse()
+		checkErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		expr, ok := stmt.Expression.(*ast.TypeTestExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression not *ast.TypeTestExpression. got=%T", stmt.Expression)
+		}
+
+		testIdentifier(t, expr.Value, test.value)
+		testIdentifier(t, expr.Type, test.ty)
+		if expr.Negated != test.negated {
+			t.Errorf("expr.Negated wrong. expect=%t, got=%t", test.negated, expr.Negated)
+		}
+	}
+}
+
+func TestTypeofExpression(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect string
+	}{
+		{"typeof x;", "(typeof x)"},
+		{"typeof (1 + 2);", "(typeof (1 + 2))"},
+		{"typeof x + 1;", "((typeof x) + 1)"},
+		{"typeof x * 2;", "((typeof x) * 2)"},
+		{"typeof -x;", "(typeof (-x))"},
+		{"typeof f(x) == \"int\";", "((typeof f(x)) == \"int\")"},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_typeof", test.input)
+		p := New(l)
+
+		program := p.Parse()
+		checkErrors(t, p)
+
+		if found := program.String(); found != test.expect {
+			t.Errorf("wrong program for %q. expect=%q, got=%q", test.input, test.expect, found)
+		}
+	}
+
+	l := lexer.New("parser_test_typeof", "typeof x + 1;")
+	program := New(l).Parse()
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	infix, ok := stmt.Expression.(*ast.InfixExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression not *ast.InfixExpression. got=%T", stmt.Expression)
+	}
+	expr, ok := infix.Left.(*ast.TypeofExpression)
+	if !ok {
+		t.Fatalf("infix.Left not *ast.TypeofExpression. got=%T", infix.Left)
+	}
+	testIdentifier(t, expr.Right, "x")
+}
+
+func TestElif(t *testing.T) {
+	tests := []struct {
+		elif   string
+		elseIf string
+		// String writes every elif as else if
+		text string
+	}{
+		{"if a { x; } elif b { y; } else { z; }", "if a { x; } else if b { y; } else { z; }", "if a { x }else if b { y }else { z }"},
+		{"if a { x; } elif b { y; } elif c { z; }", "if a { x; } else if b { y; } else if c { z; }", "if a { x }else if b { y }else if c { z }"},
+		{"if a { x; } elif b { y; } else if c { z; }", "if a { x; } else if b { y; } elif c { z; }", "if a { x }else if b { y }else if c { z }"},
+	}
+
+	// the node types and words of the tree, in order
+	shape := func(input string) []string {
+		p := New(lexer.New("parser_test_elif", input))
+		program := p.Parse()
+		checkErrors(t, p)
+
+		nodes := []string{}
+		ast.Inspect(program, func(node ast.Node) bool {
+			nodes = append(nodes, fmt.Sprintf("%T %s", node, node.TokenWord()))
+			return true
+		})
+		nodes = append(nodes, program.String())
+
+		return nodes
+	}
+
+	for _, test := range tests {
+		elif, elseIf := shape(test.elif), shape(test.elseIf)
+		if len(elif) != len(elseIf) {
+			t.Fatalf("%q: different trees. expect=%v, got=%v", test.elif, elseIf, elif)
+		}
+		for i := range elif {
+			if elif[i] != elseIf[i] {
+				t.Errorf("%q: different trees. expect=%q, got=%q", test.elif, elseIf[i], elif[i])
+			}
+		}
+		if found := elif[len(elif)-1]; found != test.text {
+			t.Errorf("%q: wrong String. expect=%q, got=%q", test.elif, test.text, found)
+		}
+	}
+}
+
+func TestIfLet(t *testing.T) {
+	tests := []struct {
+		input string
+		dump  string
+	}{
+		{
+			"if let x = f() { use(x); }",
+			`(program
+  (if (let x (call f))
+    (block
+      (expr (call use x)))))`,
+		},
+		{
+			"if let x = (f() && g()) { } else if let y = h(x)? { } else { }",
+			`(program
+  (if (let x (infix && (call f) (call g))) (block) (if (let y (try (call h x))) (block) (block))))`,
+		},
+		{
+			"if a { } elif let b = c.d { }",
+			`(program
+  (if a (block) (if (let b (member c d)) (block))))`,
+		},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_if_let", test.input)
+		p := New(l)
+
+		program := p.Parse()
+		checkErrors(t, p)
+
+		if dump := DumpAST(program); dump != test.dump {
+			t.Errorf("%q: wrong tree. expect=\n%s\ngot=\n%s", test.input, test.dump, dump)
+		}
+	}
+
+	l := lexer.New("parser_test_if_let", "if let x = f() { }")
+	p := New(l)
+
+	program := p.Parse()
+	checkErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.IfStatement)
+	if stmt.Condition != nil {
+		t.Errorf("stmt.Condition not nil. got=%s", stmt.Condition)
+	}
+	if stmt.Binding == nil || stmt.Binding.Ident.Value != "x" || stmt.Binding.IsConst {
+		t.Fatalf("wrong binding. got=%+v", stmt.Binding)
+	}
+	if loc := stmt.Binding.Location(); loc.Col != 4 {
+		t.Errorf("wrong binding location. expect column 4, got=%s", loc)
+	}
+
+	// the binding is part of the text written back
+	l = lexer.New("parser_test_if_let", "if let x = f() { g(x); } else if let y = (a && b) { }")
+	p = New(l)
+
+	program = p.Parse()
+	checkErrors(t, p)
+
+	expect := "if let x = f() { g(x) }else if let y = (a && b) {  }"
+	if found := program.String(); found != expect {
+		t.Errorf("wrong String. expect=%q, got=%q", expect, found)
+	}
+
+	// and parses back to the same tree
+	l = lexer.New("parser_test_if_let", "if let y = (a && b) { } else { }")
+	p = New(l)
+	program = p.Parse()
+	checkErrors(t, p)
+
+	p = New(lexer.New("parser_test_if_let", program.String()))
+	again := p.Parse()
+	checkErrors(t, p)
+	if diff := ast.Diff(program, again); diff != "" {
+		t.Errorf("written text parses to another tree: %s", diff)
+	}
+
+	errors := []struct {
+		input  string
+		expect string
+	}{
+		{"if let x = f() && g() { }", `parser_test_if_let:1:16: "&&" cannot follow the value of an if let, put the value in parentheses`},
+		{"if let x = f() or g() { }", `parser_test_if_let:1:16: "or" cannot follow the value of an if let, put the value in parentheses`},
+		{"if let x { }", `parser_test_if_let:1:10: expected next token to be "=", got "{" instead`},
+		{"if let 1 = x { }", `parser_test_if_let:1:8: expected next token to be "identifier", got "1" instead`},
+	}
+
+	for _, test := range errors {
+		l := lexer.New("parser_test_if_let", test.input)
+		p := New(l)
+		p.Parse()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != test.expect {
+			t.Errorf("%q: wrong errors. expect=%q, got=%q", test.input, test.expect, p.Errors())
+		}
+	}
+}
+
+func TestAssignInIfCondition(t *testing.T) {
+	l := lexer.New("parser_test_if", "if x = 5 { }")
+	p := New(l)
+
+	p.Parse()
+
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected an error for the assignment in the condition")
+	}
+
+	expect := "parser_test_if:1:6: assignment used as if condition, did you mean '=='?"
+	if msg := p.Errors()[0]; msg != expect {
+		t.Errorf("wrong error message. expect=%q, got=%q", expect, msg)
+	}
+}
+
+func TestBlockExpression(t *testing.T) {
+	l := lexer.New("parser_test_block", "let y = { 1; 2; 3 };")
+	p := New(l)
+
+	program := p.Parse()
+	checkErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] not *ast.LetStatement. got=%T", program.Statements[0])
+	}
+
+	block, ok := stmt.InitValue.(*ast.BlockExpression)
+	if !ok {
+		t.Fatalf("stmt.InitValue not *ast.BlockExpression. got=%T", stmt.InitValue)
+	}
+
+	if n := len(block.Body.Statements); n != 3 {
+		t.Fatalf("block.Body.Statements does not contain 3 statements. got=%d", n)
+	}
+
+	for i, expect := range []int{1, 2, 3} {
+		inner, ok := block.Body.Statements[i].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("block.Body.Statements[%d] not *ast.ExpressionStatement. got=%T",
+				i, block.Body.Statements[i])
+		}
+		testPrimaryExpression(t, inner.Expression, expect)
+	}
+}
+
+func TestArrayRepeatExpression(t *testing.T) {
+	tests := []struct {
+		input string
+		value any
+		count string
+	}{
+		{"[0; 5];", 0, "5"},
+		{"[x; 2 * 4];", "x", "(2 * 4)"},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_repeat", test.input)
+		p := New(l)
+
+		program := p.Parse()
+		checkErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		expr, ok := stmt.Expression.(*ast.ArrayRepeatExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression not *ast.ArrayRepeatExpression. got=%T", stmt.Expression)
+		}
+
+		testPrimaryExpression(t, expr.Value, test.value)
+		if found := expr.Count.String(); found != test.count {
+			t.Errorf("expr.Count wrong. expect=%q, got=%q", test.count, found)
+		}
+	}
+}
+
+func TestArrayRepeatCountErrors(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect string
+	}{
+		{"[0; n + 1];", "parser_test_repeat:1:7: array repeat count must be a constant integer"},
+		{"[0; -5];", "parser_test_repeat:1:5: array repeat count must not be negative, got -5"},
+		{"[0; 2 - 3];", "parser_test_repeat:1:7: array repeat count must not be negative, got -1"},
+	}
+
+	for _, test := range tests {
+		p := New(lexer.New("parser_test_repeat", test.input))
+		p.Parse()
+
+		if errors := p.Errors(); len(errors) != 1 || errors[0] != test.expect {
+			t.Errorf("%q: wrong errors. expect=[%q], got=%q", test.input, test.expect, errors)
+		}
+	}
+
+	// an empty array is fine
+	p := New(lexer.New("parser_test_repeat", "[0; 0];"))
+	p.Parse()
+	checkErrors(t, p)
+}
+
+func TestImportStatement(t *testing.T) {
+	tests := []struct {
+		input  string
+		path   string
+		alias  string
+		expect string
+	}{
+		{`import "math";`, "math", "", `import "math";`},
+		{`import "math" as m;`, "math", "m", `import "math" as m;`},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_import", test.input)
+		p := New(l)
+
+		program := p.Parse()
+		checkErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ImportStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] not *ast.ImportStatement. got=%T", program.Statements[0])
+		}
+
+		testStringLiteral(t, stmt.Path, test.path)
+
+		if test.alias == "" {
+			if stmt.Alias != nil {
+				t.Errorf("stmt.Alias not nil. got=%s", stmt.Alias)
+			}
+		} else {
+			testIdentifier(t, stmt.Alias, test.alias)
+		}
+
+		if found := stmt.String(); found != test.expect {
+			t.Errorf("stmt.String() wrong. expect=%q, got=%q", test.expect, found)
+		}
+	}
+}
+
+func TestImportMissingPath(t *testing.T) {
+	l := lexer.New("parser_test_import", "import;")
+	p := New(l)
+
+	p.Parse()
+
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected an error for the missing path")
+	}
+
+	expect := `parser_test_import:1:7: import expects a module path string, got ";" instead`
+	if msg := p.Errors()[0]; msg != expect {
+		t.Errorf("wrong error message. expect=%q, got=%q", expect, msg)
+	}
+}
+
+func TestDisallowImport(t *testing.T) {
+	input := `import "x";
+let y = 1;`
+
+	l := lexer.New("parser_test_disallow", input)
+	p := NewWithOptions(l, Options{Disallow: map[string]bool{"ImportStatement": true}})
+
+	program := p.Parse()
+
+	expect := `parser_test_disallow:1:1: feature not permitted: "ImportStatement"`
+	if errors := p.Errors(); len(errors) != 1 || errors[0] != expect {
+		t.Fatalf("wrong errors. expect=[%q], got=%q", expect, errors)
+	}
+
+	if len(program.Statements) != 1 || program.Statements[0].String() != "let y = 1;" {
+		t.Errorf("wrong statements. got=%v", program.Statements)
+	}
+}
+
+func TestConstStatement(t *testing.T) {
+	l := lexer.New("parser_test_const", "const PI = 3.14;")
+	p := New(l)
+
+	program := p.Parse()
+	checkErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] not *ast.LetStatement. got=%T", program.Statements[0])
+	}
+
+	if !stmt.IsConst {
+		t.Errorf("stmt.IsConst not true")
+	}
+
+	testIdentifier(t, stmt.Ident, "PI")
+	testPrimaryExpression(t, stmt.InitValue, 3.14)
+
+	if found := stmt.String(); found != "const PI = 3.14;" {
+		t.Errorf("stmt.String() wrong. got=%q", found)
+	}
+}
+
+func TestConstMissingInitializer(t *testing.T) {
+	l := lexer.New("parser_test_const", "const x;")
+	p := New(l)
+
+	p.Parse()
+
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected an error for the missing initializer")
+	}
+
+	expect := `parser_test_const:1:8: const "x" must be initialized`
+	if msg := p.Errors()[0]; msg != expect {
+		t.Errorf("wrong error message. expect=%q, got=%q", expect, msg)
+	}
+}
+
+func TestMissingClosingBracket(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect string
+		error  string
+	}{
+		{"f(1, 2;", "f(1, 2)", "parser_test_closing:1:7: missing ')'"},
+		{"(a + b;", "(a + b)", "parser_test_closing:1:7: missing ')'"},
+		{"xs[0;", "(xs[0])", "parser_test_closing:1:5: missing ']'"},
+		{"[1, 2 + f(3;", "[1, (2 + f(3))]", "parser_test_closing:1:12: missing ')'"},
+		{"f(1, 2", "f(1, 2)", "parser_test_closing:1:7: missing ')'"},
+		{"let x = (a + b", "let x = (a + b);", "parser_test_closing:1:15: missing ')'"},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_closing", test.input)
+		p := New(l)
+
+		program := p.Parse()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != test.error {
+			t.Errorf("wrong errors for %q. expect=%q first, got=%q", test.input, test.error, errors)
+			continue
+		}
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+
+		if found := program.Statements[0].String(); found != test.expect {
+			t.Errorf("wrong statement for %q. expect=%q, got=%q", test.input, test.expect, found)
+		}
+	}
+
+	// the call keeps its arguments and only one diagnostic is
+	// recorded, also when the input ends where the ')' should be
+	l := lexer.New("parser_test_closing", "f(1, 2")
+	p := New(l)
+
+	program := p.Parse()
+	if n := len(p.Errors()); n != 1 {
+		t.Fatalf("expected 1 error. got=%d: %q", n, p.Errors())
+	}
+
+	call := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	if n := len(call.Arguments); n != 2 {
+		t.Fatalf("call.Arguments does not contain 2 arguments. got=%d", n)
+	}
+	testPrimaryExpression(t, call.Arguments[0], 1)
+	testPrimaryExpression(t, call.Arguments[1], 2)
+}
+
+func TestSequenceExpression(t *testing.T) {
+	l := lexer.New("parser_test_sequence", "x = (a, b, c);")
+	p := New(l)
+
+	program := p.Parse()
+	checkErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	assign, ok := stmt.Expression.(*ast.AssignExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression not *ast.AssignExpression. got=%T", stmt.Expression)
+	}
+
+	seq, ok := assign.Value.(*ast.SequenceExpression)
+	if !ok {
+		t.Fatalf("assign.Value not *ast.SequenceExpression. got=%T", assign.Value)
+	}
+
+	if n := len(seq.Expressions); n != 3 {
+		t.Fatalf("seq.Expressions does not contain 3 expressions. got=%d", n)
+	}
+
+	for i, expect := range []string{"a", "b", "c"} {
+		testIdentifier(t, seq.Expressions[i], expect)
+	}
+
+	// arguments are still separate, only the parenthesized one is a sequence
+	l = lexer.New("parser_test_sequence", "f(a, (b, c));")
+	p = New(l)
+
+	program = p.Parse()
+	checkErrors(t, p)
+
+	call := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	if n := len(call.Arguments); n != 2 {
+		t.Fatalf("call.Arguments does not contain 2 arguments. got=%d", n)
+	}
+	if _, ok := call.Arguments[1].(*ast.SequenceExpression); !ok {
+		t.Errorf("call.Arguments[1] not *ast.SequenceExpression. got=%T", call.Arguments[1])
+	}
+}
+
+func TestSequenceOutsideParentheses(t *testing.T) {
+	l := lexer.New("parser_test_sequence", "a, b;")
+	p := New(l)
+
+	p.Parse()
+
+	expect := `parser_test_sequence:1:2: expected next token to be ";", got "," instead`
+	if len(p.Errors()) == 0 || p.Errors()[0] != expect {
+		t.Errorf("wrong errors. expect=%q first, got=%q", expect, p.Errors())
+	}
+}
+
+func TestFunctionGroup(t *testing.T) {
+	input := `
+fn f(x) {}
+let a = 1;
+fn f(x, y) {}
+fn g() {}`
+
+	l := lexer.New("parser_test_overload", input)
+	p := New(l)
+
+	program := p.Parse()
+	checkErrors(t, p)
+
+	if n := len(program.Statements); n != 3 {
+		t.Fatalf("program.Statements does not contain 3 statements. got=%d", n)
+	}
+
+	group, ok := program.Statements[0].(*ast.FunctionGroup)
+	if !ok {
+		t.Fatalf("program.Statements[0] not *ast.FunctionGroup. got=%T", program.Statements[0])
+	}
+
+	testIdentifier(t, group.Ident, "f")
+	if n := len(group.Variants); n != 2 {
+		t.Fatalf("group.Variants does not contain 2 variants. got=%d", n)
+	}
+
+	for i, arity := range []int{1, 2} {
+		if n := len(group.Variants[i].Value.Parameters); n != arity {
+			t.Errorf("group.Variants[%d] wrong arity. expect=%d, got=%d", i, arity, n)
+		}
+	}
+
+	// a function declared once is left alone
+	if _, ok := program.Statements[2].(*ast.FunctionStatement); !ok {
+		t.Errorf("program.Statements[2] not *ast.FunctionStatement. got=%T", program.Statements[2])
+	}
+}
+
+func TestFunctionGroupArityCollision(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect string
+	}{
+		{"fn f(x) {}\nfn f(y) {}", `parser_test_overload:2:4: function "f" taking 1 arguments clashes with the one declared on line 1`},
+		{"fn f(x, y = 1) {}\nfn f(a, b) {}", `parser_test_overload:2:4: function "f" taking 2 arguments clashes with the one declared on line 1`},
+		{"fn f(x) {}\nfn f(a = 1) {}", `parser_test_overload:2:4: function "f" taking 0 to 1 arguments clashes with the one declared on line 1`},
+		{"fn f(x, y) {}\nfn f(...r) {}", `parser_test_overload:2:4: function "f" taking 0 or more arguments clashes with the one declared on line 1`},
+		{"fn f() {}\nfn f(x, y) {}\nfn f(a, ...r) {}", `parser_test_overload:3:4: function "f" taking 1 or more arguments clashes with the one declared on line 2`},
+		// ranges that do not meet are told apart
+		{"fn f() {}\nfn f(x, y = 1) {}\nfn f(a, b, c, ...r) {}", ""},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_overload", test.input)
+		p := New(l)
+
+		program := p.Parse()
+
+		if test.expect == "" {
+			checkErrors(t, p)
+			if group, ok := program.Statements[0].(*ast.FunctionGroup); !ok || len(group.Variants) != 3 {
+				t.Errorf("%q: not grouped into 3 variants. got=%s", test.input, program.Statements[0])
+			}
+			continue
+		}
+
+		if errors := p.Errors(); len(errors) != 1 || errors[0] != test.expect {
+			t.Errorf("wrong errors. expect=[%q], got=%q", test.expect, errors)
+		}
+	}
+}
+
+func TestFunctionGroupByStatement(t *testing.T) {
+	l := lexer.New("parser_test_overload", "fn f(x) {}\nlet a = 1;\nfn f(x, y) {}\nfn f(z) {}")
+	p := New(l)
+
+	first, _ := p.ParseStatement()
+	if _, ok := first.(*ast.FunctionStatement); !ok {
+		t.Fatalf("first statement not *ast.FunctionStatement. got=%T", first)
+	}
+	p.ParseStatement()
+
+	// the second variant comes back as the group of both
+	second, _ := p.ParseStatement()
+	group, ok := second.(*ast.FunctionGroup)
+	if !ok {
+		t.Fatalf("third statement not *ast.FunctionGroup. got=%T", second)
+	}
+	if len(group.Variants) != 2 || group.Variants[0] != first {
+		t.Fatalf("wrong variants. got=%v", group.Variants)
+	}
+
+	// a clashing variant is dropped
+	third, eof := p.ParseStatement()
+	if third != nil || !eof {
+		t.Errorf("clashing function not dropped. got=%v, eof=%t", third, eof)
+	}
+
+	expect := `parser_test_overload:4:4: function "f" taking 1 arguments clashes with the one declared on line 1`
+	if errors := p.Errors(); len(errors) != 1 || errors[0] != expect {
+		t.Errorf("wrong errors. expect=[%q], got=%q", expect, errors)
+	}
+}
+
+func TestCallArgumentErrors(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect string
+	}{
+		{"f(,);", "parser_test_args:1:3: unexpected ',' before the first argument"},
+		{"f(, 1);", "parser_test_args:1:3: unexpected ',' before the first argument"},
+		{"f(1,, 2);", "parser_test_args:1:5: empty argument between ','"},
+		{"f(1,);", "parser_test_args:1:4: trailing ',' after the last argument"},
+		{"f(1 2);", `parser_test_args:1:5: expected ',' between arguments, got "2"`},
+		{"f(1, 2;", "parser_test_args:1:7: missing ')'"},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_args", test.input)
+		p := New(l)
+
+		p.Parse()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected an error for %q", test.input)
+			continue
+		}
+
+		if msg := p.Errors()[0]; msg != test.expect {
+			t.Errorf("wrong error message for %q. expect=%q, got=%q", test.input, test.expect, msg)
+		}
+	}
+}
+
+func TestTrailingCommas(t *testing.T) {
+	l := lexer.New("parser_test_args", "f(1, 2,);")
+	p := NewWithOptions(l, Options{TrailingCommas: true})
+
+	program := p.Parse()
+	checkErrors(t, p)
+
+	call := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	if n := len(call.Arguments); n != 2 {
+		t.Fatalf("call.Arguments does not contain 2 arguments. got=%d", n)
+	}
+}
+
+func TestUnlessExpression(t *testing.T) {
+	l := lexer.New("parser_test_unless", "let x = a + 1 unless c > 0 else b;")
+	p := New(l)
+
+	program := p.Parse()
+	checkErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.LetStatement)
+	expr, ok := stmt.InitValue.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("stmt.InitValue not *ast.IfExpression. got=%T", stmt.InitValue)
+	}
+
+	if found := expr.Condition.String(); found != "(not (c > 0))" {
+		t.Errorf("expr.Condition wrong. got=%q", found)
+	}
+	if found := expr.Then.String(); found != "(a + 1)" {
+		t.Errorf("expr.Then wrong. got=%q", found)
+	}
+	testIdentifier(t, expr.Else, "b")
+}
+
+func TestUnlessMissingElse(t *testing.T) {
+	l := lexer.New("parser_test_unless", "let x = a unless c;")
+	p := New(l)
+
+	p.Parse()
+
+	expect := `parser_test_unless:1:19: expected 'else' after the unless condition, got ";"`
+	if len(p.Errors()) == 0 || p.Errors()[0] != expect {
+		t.Errorf("wrong errors. expect=%q first, got=%q", expect, p.Errors())
+	}
+}
+
+func TestRejectUselessExpressions(t *testing.T) {
+	input := `f(1 + 2);
+x = 3;
+1 + 2;
+let y = do { !x; x * 2 };
+typeof x;
+a..b;
+a..=b;
+x between 1 and 2;
+x is int;
+f()?;`
+
+	l := lexer.New("parser_test_useless", input)
+	p := NewWithOptions(l, Options{RejectUselessExpressions: true})
+
+	p.Parse()
+
+	expects := []string{
+		"parser_test_useless:3:3: result of (1 + 2) is not used",
+		"parser_test_useless:4:14: result of (!x) is not used",
+		"parser_test_useless:5:1: result of (typeof x) is not used",
+		"parser_test_useless:6:2: result of (a .. b) is not used",
+		"parser_test_useless:7:2: result of (a ..= b) is not used",
+		"parser_test_useless:8:3: result of (x between 1 and 2) is not used",
+		"parser_test_useless:9:3: result of (x is int) is not used",
+	}
+
+	errors := p.Errors()
+	if len(errors) != len(expects) {
+		t.Fatalf("wrong number of errors. expect=%d, got=%q", len(expects), errors)
+	}
+
+	for i, expect := range expects {
+		if errors[i] != expect {
+			t.Errorf("wrong error message. expect=%q, got=%q", expect, errors[i])
+		}
+	}
+}
+
+func TestAtAttributes(t *testing.T) {
+	tests := []struct {
+		input  string
+		names  []string
+		args   []string // each attribute's arguments, "" without parentheses
+		expect string
+	}{
+		{"@deprecated fn f() {}", []string{"deprecated"}, []string{""}, "@deprecated fn f() {  }"},
+		{"@inline @pure fn f() {}", []string{"inline", "pure"}, []string{"", ""}, "@inline @pure fn f() {  }"},
+		{`@since("1.2", 3) fn f(x) { return x; }`, []string{"since"}, []string{`"1.2", 3`},
+			`@since("1.2", 3) fn f(x) { return x; }`},
+		{"@a() @b(n + 1) fn f() {}", []string{"a", "b"}, []string{"", "(n + 1)"}, "@a() @b((n + 1)) fn f() {  }"},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_at", test.input)
+		p := New(l)
+
+		program := p.Parse()
+		checkErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.AttributedStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] not *ast.AttributedStatement. got=%T", program.Statements[0])
+		}
+		if _, ok := stmt.Stmt.(*ast.FunctionStatement); !ok {
+			t.Errorf("stmt.Stmt not *ast.FunctionStatement. got=%T", stmt.Stmt)
+		}
+
+		if len(stmt.Attributes) != len(test.names) {
+			t.Fatalf("stmt.Attributes does not contain %d attributes. got=%d",
+				len(test.names), len(stmt.Attributes))
+		}
+
+		for i, name := range test.names {
+			attr := stmt.Attributes[i]
+			testIdentifier(t, attr.Name, name)
+
+			args := make([]string, len(attr.Args))
+			for j, arg := range attr.Args {
+				args[j] = arg.String()
+			}
+			if found := strings.Join(args, ", "); found != test.args[i] {
+				t.Errorf("wrong arguments for @%s. expect=%q, got=%q", name, test.args[i], found)
+			}
+		}
+
+		if found := stmt.String(); found != test.expect {
+			t.Errorf("stmt.String() wrong. expect=%q, got=%q", test.expect, found)
+		}
+	}
+}
+
+func TestHashAttributes(t *testing.T) {
+	tests := []struct {
+		input  string
+		names  []string
+		expect string
+	}{
+		{"#[inline] fn f() {}", []string{"inline"}, "#[inline] fn f() {  }"},
+		{"#[a] #[b(1, x)] @c let x = 1;", []string{"a", "b", "c"}, "#[a] #[b(1, x)] @c let x = 1;"},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_hash", test.input)
+		p := New(l)
+
+		program := p.Parse()
+		checkErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.AttributedStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] not *ast.AttributedStatement. got=%T", program.Statements[0])
+		}
+
+		if len(stmt.Attributes) != len(test.names) {
+			t.Fatalf("stmt.Attributes does not contain %d attributes. got=%d",
+				len(test.names), len(stmt.Attributes))
+		}
+
+		for i, name := range test.names {
+			testIdentifier(t, stmt.Attributes[i].Name, name)
+		}
+
+		if found := stmt.String(); found != test.expect {
+			t.Errorf("stmt.String() wrong. expect=%q, got=%q", test.expect, found)
+		}
+	}
+}
+
+func TestHashAttributeUnclosed(t *testing.T) {
+	l := lexer.New("parser_test_hash", "#[inline fn f() {}")
+	p := New(l)
+
+	p.Parse()
+
+	expect := `parser_test_hash:1:10: expected next token to be "]", got "fn" instead`
+	if len(p.Errors()) == 0 || p.Errors()[0] != expect {
+		t.Errorf("wrong errors. expect=%q first, got=%q", expect, p.Errors())
+	}
+}
+
+func TestParseInfixFrom(t *testing.T) {
+	tests := []struct {
+		input      string
+		precedence Precedence
+		expect     string
+	}{
+		{"+ 5", NONE, "(x + 5)"},
+		{"+ 5 * 2 == y", NONE, "((x + (5 * 2)) == y)"},
+		{"* 2 + 1", SUM, "(x * 2)"},
+		{"+ 1", PRODUCT, "x"},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_infix", test.input)
+		p := New(l)
+
+		left := &ast.Identifier{Token: token.Token{Type: token.IDENT, Word: "x"}, Value: "x"}
+
+		expr := p.ParseInfixFrom(left, test.precedence)
+		checkErrors(t, p)
+
+		if found := expr.String(); found != test.expect {
+			t.Errorf("wrong expression for %q. expect=%q, got=%q", test.input, test.expect, found)
+		}
+	}
+}
+
+func TestLabeledLoops(t *testing.T) {
+	input := `
+outer: while a {
+	while b {
+		if c { break outer; }
+		continue outer;
+	}
+	continue;
+}
+done: {
+	break done;
+}`
+
+	l := lexer.New("parser_test_labels", input)
+	p := New(l)
+
+	program := p.Parse()
+	checkErrors(t, p)
+
+	if n := len(program.Statements); n != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", n)
+	}
+
+	loop, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] not *ast.WhileStatement. got=%T", program.Statements[0])
+	}
+	testIdentifier(t, loop.Label, "outer")
+	testIdentifier(t, loop.Condition, "a")
+
+	inner := loop.Body.Statements[0].(*ast.WhileStatement)
+	if inner.Label != nil {
+		t.Errorf("inner.Label not nil. got=%s", inner.Label)
+	}
+
+	brk := inner.Body.Statements[0].(*ast.IfStatement).Then.Statements[0].(*ast.BreakStatement)
+	testIdentifier(t, brk.Label, "outer")
+
+	expect := "outer: while a { while b { if c { break outer; }continue outer; }continue; }"
+	if found := loop.String(); found != expect {
+		t.Errorf("loop.String() wrong. expect=%q, got=%q", expect, found)
+	}
+
+	block := program.Statements[1].(*ast.BlockStatement)
+	testIdentifier(t, block.Label, "done")
+}
+
+func TestLabelErrors(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect string
+	}{
+		{"while a { break outer; }", `parser_test_labels:1:17: unknown label "outer"`},
+		{"outer: while a { } break outer;", `parser_test_labels:1:26: unknown label "outer"`},
+		{"done: { continue done; }", `parser_test_labels:1:18: cannot continue "done", it does not label a loop`},
+		{"break;", "parser_test_labels:1:1: break outside of a loop"},
+		{"while a { fn f() { continue; } }", "parser_test_labels:1:20: continue outside of a loop"},
+		{"a: while x { a: while y { } }", `parser_test_labels:1:14: label "a" is already in use`},
+		{"a: let x = 1;", `parser_test_labels:1:4: label "a" must be followed by a loop or a block`},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_labels", test.input)
+		p := New(l)
+
+		p.Parse()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected an error for %q", test.input)
+			continue
+		}
+
+		if msg := p.Errors()[0]; msg != test.expect {
+			t.Errorf("wrong error message for %q. expect=%q, got=%q", test.input, test.expect, msg)
+		}
+	}
+}
+
+func TestDoWhileStatement(t *testing.T) {
+	input := `
+do {
+	f(x);
+	if y { break; }
+} while x < 10;
+again: do { continue again; } while g();
+do { 1 } + 2;`
+
+	l := lexer.New("parser_test_do_while", input)
+	p := New(l)
+
+	program := p.Parse()
+	checkErrors(t, p)
+
+	if n := len(program.Statements); n != 3 {
+		t.Fatalf("program.Statements does not contain 3 statements. got=%d", n)
+	}
+
+	loop, ok := program.Statements[0].(*ast.DoWhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] not *ast.DoWhileStatement. got=%T", program.Statements[0])
+	}
+
+	if n := len(loop.Body.Statements); n != 2 {
+		t.Fatalf("loop.Body does not contain 2 statements. got=%d", n)
+	}
+	if _, ok := loop.Body.Statements[0].(*ast.ExpressionStatement); !ok {
+		t.Errorf("loop.Body.Statements[0] not *ast.ExpressionStatement. got=%T", loop.Body.Statements[0])
+	}
+	testInfixExpression(t, loop.Condition, "x", "<", 10)
+
+	expect := "do { f(x)if y { break; } } while (x < 10);"
+	if found := loop.String(); found != expect {
+		t.Errorf("loop.String() wrong. expect=%q, got=%q", expect, found)
+	}
+
+	labeled := program.Statements[1].(*ast.DoWhileStatement)
+	testIdentifier(t, labeled.Label, "again")
+
+	// without 'while' the block stays a do expression
+	stmt := program.Statements[2].(*ast.ExpressionStatement)
+	infix, ok := stmt.Expression.(*ast.InfixExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression not *ast.InfixExpression. got=%T", stmt.Expression)
+	}
+	if _, ok := infix.Left.(*ast.DoExpression); !ok {
+		t.Errorf("infix.Left not *ast.DoExpression. got=%T", infix.Left)
+	}
+}
+
+func TestDoBlockJumps(t *testing.T) {
+	// a do block that is not a loop leaves its jumps to the loop around it
+	tests := []string{
+		"while a { do { break; 1 }; }",
+		"while a { let y = do { break; 1 }; }",
+		"do { do { continue; 1 }; } while a;",
+		"do { x; } while c;",
+	}
+
+	for _, input := range tests {
+		p := New(lexer.New("parser_test_do_while", input))
+		p.Parse()
+		checkErrors(t, p)
+	}
+}
+
+func TestDoWhileErrors(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect string
+	}{
+		{"do { f(); } while x", `parser_test_do_while:1:20: unexpected end of input, expected ";"`},
+		{"do { break; }", "parser_test_do_while:1:6: break outside of a loop"},
+		{"do { while a { break; } 1 }; break;", "parser_test_do_while:1:30: break outside of a loop"},
+		{"a: do { 1 };", `parser_test_do_while:1:4: label "a" must be followed by a loop or a block`},
+		{"do { let x = 1; } + 1;", "parser_test_do_while:1:6: do block must end with an expression"},
+		// the loop body needs its ';' like the body of a while loop
+		{"do { x } while c;", `parser_test_do_while:1:8: expected next token to be ";", got "}" instead`},
+		{"while c { x }", `parser_test_do_while:1:13: expected next token to be ";", got "}" instead`},
+		{"do { do { break; 1 }; } + 1;", "parser_test_do_while:1:11: break outside of a loop"},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_do_while", test.input)
+		p := New(l)
+
+		p.Parse()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected an error for %q", test.input)
+			continue
+		}
+
+		if msg := p.Errors()[0]; msg != test.expect {
+			t.Errorf("wrong error message for %q. expect=%q, got=%q", test.input, test.expect, msg)
+		}
+	}
+}
+
+func TestFoldKeywords(t *testing.T) {
+	input := "IF x { }"
+
+	l := lexer.NewWithOptions("parser_test_fold", input, lexer.Options{FoldKeywords: true})
+	p := New(l)
+
+	program := p.Parse()
+	checkErrors(t, p)
+
+	if _, ok := program.Statements[0].(*ast.IfStatement); !ok {
+		t.Errorf("with FoldKeywords, program.Statements[0] not *ast.IfStatement. got=%T", program.Statements[0])
+	}
+
+	// without the option `IF` is a plain name, followed by a block it cannot take
+	l = lexer.New("parser_test_fold", input)
+	p = New(l)
+
+	p.Parse()
+
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected an error without FoldKeywords")
+	}
+
+	tok := lexer.New("parser_test_fold", input).NextToken()
+	if tok.Type != token.IDENT {
+		t.Errorf("without FoldKeywords, `IF` not an identifier. got=%s", token.TokenString[tok.Type])
+	}
+}
+
+func TestParseStatement(t *testing.T) {
+	input := `
+let x = 1;
+fn f() { return x; }
+f() + 1;`
+
+	l := lexer.New("parser_test_statement", input)
+	p := New(l)
+
+	expect := []string{"*ast.LetStatement", "*ast.FunctionStatement", "*ast.ExpressionStatement"}
+
+	for i, typ := range expect {
+		stmt, eof := p.ParseStatement()
+		checkErrors(t, p)
+
+		if found := fmt.Sprintf("%T", stmt); found != typ {
+			t.Errorf("statement %d - wrong type. expect=%s, got=%s", i, typ, found)
+		}
+
+		if last := i == len(expect)-1; eof != last {
+			t.Errorf("statement %d - wrong end of input. expect=%t, got=%t", i, last, eof)
+		}
+	}
+
+	if stmt, eof := p.ParseStatement(); stmt != nil || !eof {
+		t.Errorf("expected no statement past the end. got=%v, %t", stmt, eof)
+	}
+}
+
+func TestParseStatementErrors(t *testing.T) {
+	input := "let = 1; let y = 2; let 3;"
+
+	l := lexer.New("parser_test_statement", input)
+	p := New(l)
+
+	stmts := []ast.Statement{}
+	for {
+		stmt, eof := p.ParseStatement()
+		stmts = append(stmts, stmt)
+		if eof {
+			break
+		}
+	}
+
+	if n := len(stmts); n != 3 {
+		t.Fatalf("expected 3 statements. got=%d", n)
+	}
+
+	if stmts[0] != nil || stmts[2] != nil {
+		t.Errorf("broken statements not nil. got=%v, %v", stmts[0], stmts[2])
+	}
+
+	if _, ok := stmts[1].(*ast.LetStatement); !ok {
+		t.Errorf("stmts[1] not *ast.LetStatement. got=%T", stmts[1])
+	}
+
+	// errors of every call are kept
+	if n := len(p.Errors()); n != 2 {
+		t.Errorf("expected 2 errors. got=%d: %v", n, p.Errors())
+	}
+}
+
+// a broken statement is skipped to its end, blocks inside it
+// included, so each error is only reported once
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect string
+		after  string
+	}{
+		{
+			"fn f() { while a { break nope; } }\nlet y = 1;",
+			`parser_test_recovery:1:26: unknown label "nope"`,
+			"let y = 1;",
+		},
+		{
+			"let f = fn() { if a { 1 +; } };\nlet z = 2;",
+			`parser_test_recovery:1:26: no prefix parse function for ";" found`,
+			"let z = 2;",
+		},
+		{
+			"if a { let = 1; } else { b; }\nlet c = 3;",
+			`parser_test_recovery:1:12: expected next token to be "identifier", got "=" instead`,
+			"let c = 3;",
+		},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_recovery", test.input)
+		p := New(l)
+
+		program := p.Parse()
+
+		if errors := p.Errors(); len(errors) != 1 || errors[0] != test.expect {
+			t.Errorf("%q: expected a single error %q. got=%q", test.input, test.expect, errors)
+		}
+		if found := program.String(); found != test.after {
+			t.Errorf("%q: wrong program. expect=%q, got=%q", test.input, test.after, found)
+		}
+	}
+}
+
+func TestPipelineExpression(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect string
+	}{
+		{"let r = compute() then |x| transform(x);", "let r = fn (x) { return transform(x); }(compute());"},
+		{"let r = a then |x| f(x) then |y| g(y);", "let r = fn (y) { return g(y); }(fn (x) { return f(x); }(a));"},
+		{"let r = a + 1 then |x| x * 2;", "let r = fn (x) { return (x * 2); }((a + 1));"},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_pipeline", test.input)
+		p := New(l)
+
+		program := p.Parse()
+		checkErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.LetStatement)
+		call, ok := stmt.InitValue.(*ast.CallExpression)
+		if !ok {
+			t.Fatalf("stmt.InitValue not *ast.CallExpression. got=%T", stmt.InitValue)
+		}
+
+		fn, ok := call.Callee.(*ast.FunctionLiteral)
+		if !ok {
+			t.Fatalf("call.Callee not *ast.FunctionLiteral. got=%T", call.Callee)
+		}
+
+		if n := len(fn.Parameters); n != 1 {
+			t.Errorf("lambda does not take 1 parameter. got=%d", n)
+		}
+
+		if found := stmt.String(); found != test.expect {
+			t.Errorf("wrong string. expect=%q, got=%q", test.expect, found)
+		}
+	}
+}
+
+func TestPipelineErrors(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect string
+	}{
+		{"a then f;", `parser_test_pipeline:1:8: expected next token to be "|", got "f" instead`},
+		{"a then |x, y| f(x);", `parser_test_pipeline:1:10: expected next token to be "|", got "," instead`},
+		{"a then || f();", `parser_test_pipeline:1:8: expected next token to be "|", got "||" instead`},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_pipeline", test.input)
+		p := New(l)
+
+		p.Parse()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected an error for %q", test.input)
+			continue
+		}
+
+		if msg := p.Errors()[0]; msg != test.expect {
+			t.Errorf("wrong error message for %q. expect=%q, got=%q", test.input, test.expect, msg)
+		}
+	}
+}
+
+func TestReset(t *testing.T) {
+	p := New(lexer.New("parser_test_reset", "let x = ;"))
+
+	p.Parse()
+	first := p.Errors()
+	if len(first) == 0 {
+		t.Fatalf("expected an error for the first source")
+	}
+
+	p.Reset(lexer.New("parser_test_reset", "fn f(a) { return a; } f(1);"))
+	program := p.Parse()
+	checkErrors(t, p)
+
+	if n := len(program.Statements); n != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", n)
+	}
+	if _, ok := program.Statements[0].(*ast.FunctionStatement); !ok {
+		t.Errorf("program.Statements[0] not *ast.FunctionStatement. got=%T", program.Statements[0])
+	}
+
+	// errors of the first run are not touched by the second
+	if n := len(first); n != 1 {
+		t.Errorf("errors of the first run changed. got=%v", first)
+	}
+
+	// options survive the reset
+	p = NewWithOptions(lexer.New("parser_test_reset", "1"), Options{REPL: true})
+	p.Reset(lexer.New("parser_test_reset", "let y = 2"))
+	program = p.Parse()
+	checkErrors(t, p)
+
+	if found := program.String(); found != "let y = 2;" {
+		t.Errorf("wrong program after reset. got=%q", found)
+	}
+}
+
+func TestNamedArguments(t *testing.T) {
+	input := `connect(conf, 1 + 2, host: "x", port: 80);`
+
+	l := lexer.New("parser_test_named", input)
+	p := New(l)
+
+	program := p.Parse()
+	checkErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	call, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression not *ast.CallExpression. got=%T", stmt.Expression)
+	}
+
+	if n := len(call.Arguments); n != 2 {
+		t.Fatalf("call does not have 2 positional arguments. got=%d", n)
+	}
+	testIdentifier(t, call.Arguments[0], "conf")
+	testInfixExpression(t, call.Arguments[1], 1, "+", 2)
+
+	if n := len(call.NamedArgs); n != 2 {
+		t.Fatalf("call does not have 2 named arguments. got=%d", n)
+	}
+	testIdentifier(t, call.NamedArgs[0].Name, "host")
+	testStringLiteral(t, call.NamedArgs[0].Value, "x")
+	testIdentifier(t, call.NamedArgs[1].Name, "port")
+	testIntLiteral(t, call.NamedArgs[1].Value, 80)
+
+	expect := `connect(conf, (1 + 2), host: "x", port: 80)`
+	if found := call.String(); found != expect {
+		t.Errorf("call.String() wrong. expect=%q, got=%q", expect, found)
+	}
+
+	program, errs := Parse("parser_test_named", "f(a: 1);")
+	if len(errs) != 0 {
+		t.Fatalf("parser has errors: %v", errs)
+	}
+	if found := program.String(); found != "f(a: 1)" {
+		t.Errorf("wrong program. expect=%q, got=%q", "f(a: 1)", found)
+	}
+}
+
+func TestNamedArgumentErrors(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect string
+	}{
+		{"f(a: 1, 2);", "parser_test_named:1:9: positional argument after named arguments"},
+		{"f(x, a: 1, b: 2, x);", "parser_test_named:1:18: positional argument after named arguments"},
+		{"f(a: 1, a: 2);", `parser_test_named:1:9: argument "a" given more than once`},
+		{"f(a: );", `parser_test_named:1:6: no prefix parse function for ")" found`},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_named", test.input)
+		p := New(l)
+
+		p.Parse()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected an error for %q", test.input)
+			continue
+		}
+
+		if msg := p.Errors()[0]; msg != test.expect {
+			t.Errorf("wrong error message for %q. expect=%q, got=%q", test.input, test.expect, msg)
+		}
+	}
+}
+
+func TestAssertStatement(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectMessage bool
+		expectString  string
+	}{
+		{"assert x > 0;", false, "assert (x > 0);"},
+		{`assert len(xs) == 2, "expected " + 2;`, true, `assert (len(xs) == 2), ("expected " + 2);`},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_assert", test.input)
+		p := New(l)
+
+		program := p.Parse()
+		checkErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.AssertStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] not *ast.AssertStatement. got=%T", program.Statements[0])
+		}
+
+		if _, ok := stmt.Condition.(*ast.InfixExpression); !ok {
+			t.Errorf("stmt.Condition not *ast.InfixExpression. got=%T", stmt.Condition)
+		}
+
+		if (stmt.Message != nil) != test.expectMessage {
+			t.Errorf("wrong message for %q. got=%v", test.input, stmt.Message)
+		}
+
+		if found := stmt.String(); found != test.expectString {
+			t.Errorf("stmt.String() wrong. expect=%q, got=%q", test.expectString, found)
+		}
+	}
+}
+
+func TestAssertErrors(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect string
+	}{
+		{"assert;", `parser_test_assert:1:7: assert expects a condition, got ";"`},
+		{"assert x,;", `parser_test_assert:1:10: no prefix parse function for ";" found`},
+		{"assert x y;", `parser_test_assert:1:10: expected next token to be ";", got "y" instead`},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_assert", test.input)
+		p := New(l)
+
+		p.Parse()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected an error for %q", test.input)
+			continue
+		}
+
+		if msg := p.Errors()[0]; msg != test.expect {
+			t.Errorf("wrong error message for %q. expect=%q, got=%q", test.input, test.expect, msg)
+		}
+	}
+}
+
+func TestDeferStatement(t *testing.T) {
+	l := lexer.New("parser_test_defer", "defer close(f, 1 + 2);")
+	p := New(l)
+
+	program := p.Parse()
+	checkErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.DeferStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] not *ast.DeferStatement. got=%T", program.Statements[0])
+	}
+
+	testIdentifier(t, stmt.Call.Callee, "close")
+	if n := len(stmt.Call.Arguments); n != 2 {
+		t.Fatalf("wrong number of arguments. got=%d", n)
+	}
+	if found := stmt.String(); found != "defer close(f, (1 + 2));" {
+		t.Errorf("stmt.String() wrong. got=%q", found)
+	}
+}
+
+func TestDeferErrors(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect string
+	}{
+		{"defer 5;", "parser_test_defer:1:7: defer expects a function call, got 5"},
+		{"defer  f;", "parser_test_defer:1:8: defer expects a function call, got f"},
+		{"defer f() + 1;", "parser_test_defer:1:11: defer expects a function call, got (f() + 1)"},
+		{"defer f()", `parser_test_defer:1:10: unexpected end of input, expected ";"`},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_defer", test.input)
+		p := New(l)
+
+		p.Parse()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected an error for %q", test.input)
+			continue
+		}
+
+		if msg := p.Errors()[0]; msg != test.expect {
+			t.Errorf("wrong error message for %q. expect=%q, got=%q", test.input, test.expect, msg)
+		}
+	}
+}
+
+func TestPrintStatement(t *testing.T) {
+	tests := []struct {
+		input   string
+		values  int
+		newline bool
+		expect  string
+	}{
+		{"print a, b;", 2, false, "print a, b;"},
+		{"println a + 1;", 1, true, "println (a + 1);"},
+		{"println;", 0, true, "println;"},
+		{"print;", 0, false, "print;"},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_print", test.input)
+		p := New(l)
+
+		program := p.Parse()
+		checkErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.PrintStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] not *ast.PrintStatement. got=%T", program.Statements[0])
+		}
+
+		if len(stmt.Values) != test.values {
+			t.Errorf("wrong number of values for %q. got=%d", test.input, len(stmt.Values))
+		}
+		if stmt.Newline != test.newline {
+			t.Errorf("stmt.Newline wrong for %q. got=%t", test.input, stmt.Newline)
+		}
+		if found := stmt.String(); found != test.expect {
+			t.Errorf("stmt.String() wrong. got=%q", found)
+		}
+	}
+}
+
+func TestIndexExpression(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect string
+		check  func(ast.Expression) bool
+	}{
+		{"arr[-1];", "(arr[(-1)])", func(index ast.Expression) bool {
+			return testPrefixExpression(t, index, "-", 1)
+		}},
+		{"arr[i-1];", "(arr[(i - 1)])", func(index ast.Expression) bool {
+			return testInfixExpression(t, index, "i", "-", 1)
+		}},
+		{"arr[f(x)];", "(arr[f(x)])", func(index ast.Expression) bool {
+			call, ok := index.(*ast.CallExpression)
+			if !ok {
+				t.Errorf("index not *ast.CallExpression. got=%T", index)
+				return false
+			}
+			return testIdentifier(t, call.Callee, "f") && testIdentifier(t, call.Arguments[0], "x")
+		}},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_index", test.input)
+		p := New(l)
+
+		program := p.Parse()
+		checkErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		expr, ok := stmt.Expression.(*ast.IndexExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression not *ast.IndexExpression. got=%T", stmt.Expression)
+		}
+
+		testIdentifier(t, expr.Left, "arr")
+		if !test.check(expr.Index) {
+			t.Logf("input %q", test.input)
+		}
+		if found := expr.String(); found != test.expect {
+			t.Errorf("expr.String() wrong. expect=%q, got=%q", test.expect, found)
+		}
+	}
+
+	// a folded '-' leaves a negative literal as the index
+	l := lexer.New("parser_test_index", "arr[-1];")
+	p := NewWithOptions(l, Options{FoldNegativeLiterals: true})
+
+	program := p.Parse()
+	checkErrors(t, p)
+
+	expr := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IndexExpression)
+	testIntLiteral(t, expr.Index, -1)
+}
+
+func TestPostfixChains(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect string
+		// node types from the outermost in, down the left side
+		nesting []string
+	}{
+		{"a()[0]", "(a()[0])", []string{"Index", "Call", "a"}},
+		{"a[0]()", "(a[0])()", []string{"Call", "Index", "a"}},
+		{"a.b()[1]", "((a.b)()[1])", []string{"Index", "Call", "Member", "a"}},
+		{"a().b.c", "((a().b).c)", []string{"Member", "Member", "Call", "a"}},
+		{"get(x)[0].field()", "((get(x)[0]).field)()", []string{"Call", "Member", "Index", "Call", "get"}},
+		// prefix operators take the whole chain
+		{"-a.b()[1]", "(-((a.b)()[1]))", []string{"Prefix", "Index", "Call", "Member", "a"}},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_chains", test.input)
+		p := NewWithOptions(l, Options{REPL: true})
+
+		program := p.Parse()
+		checkErrors(t, p)
+
+		if found := program.String(); found != test.expect {
+			t.Errorf("%q: wrong program. expect=%q, got=%q", test.input, test.expect, found)
+		}
+
+		nesting := []string{}
+		for expr := program.Statements[0].(*ast.ExpressionStatement).Expression; expr != nil; {
+			switch e := expr.(type) {
+			case *ast.CallExpression:
+				nesting, expr = append(nesting, "Call"), e.Callee
+			case *ast.IndexExpression:
+				nesting, expr = append(nesting, "Index"), e.Left
+			case *ast.MemberExpression:
+				nesting, expr = append(nesting, "Member"), e.Object
+			case *ast.PrefixExpression:
+				nesting, expr = append(nesting, "Prefix"), e.Right
+			default:
+				nesting, expr = append(nesting, expr.String()), nil
+			}
+		}
+		if !reflect.DeepEqual(nesting, test.nesting) {
+			t.Errorf("%q: wrong nesting. expect=%v, got=%v", test.input, test.nesting, nesting)
+		}
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect string
+	}{
+		{"a?", "(a?)"},
+		{"f(x)?", "(f(x)?)"},
+		{"f()?.g()?", "(((f()?).g)()?)"},
+		{"a[0]??", "(((a[0])?)?)"},
+		{"-a? * 2", "((-(a?)) * 2)"},
+		{"x = f()?", "(x = (f()?))"},
+		{"g(a?, b?)", "g((a?), (b?))"},
+		// an expression after the '?' makes it a ternary
+		{"a ? b : c", "(if a then b else c)"},
+		{"a < b ? b - a : -1", "(if (a < b) then (b - a) else (-1))"},
+		{"a ? b : c ? d : e", "(if a then b else (if c then d else e))"},
+		{"x = a || b ? 1 : 2", "(x = (if (a || b) then 1 else 2))"},
+		{"a? ? b : c", "(if (a?) then b else c)"},
+		// operators that may also start an expression continue a try
+		// written right after its operand, with a space it is a ternary
+		{"a? + 1", "((a?) + 1)"},
+		{"x? - y", "((x?) - y)"},
+		{"f()?[0]", "((f()?)[0])"},
+		{"f()?(1)", "(f()?)(1)"},
+		{"a ? -1 : 0", "(if a then (-1) else 0)"},
+		{"a ? [1] : (2)", "(if a then [1] else 2)"},
+		// after a line break the ':' ahead tells a ternary from a try
+		{"a ?\n- b", "((a?) - b)"},
+		{"x = a ?\n  b :\n  c;", "(x = (if a then b else c))"},
+		{"x = a ?\n  f(b, c) :\n  d;", "(x = (if a then f(b, c) else d))"},
+		{"x = a ?\n  -b\n  : c;", "(x = (if a then (-b) else c))"},
+		{"x = a?\n  + f(b ? c : d);", "(x = ((a?) + f((if b then c else d))))"},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_try", test.input)
+		p := NewWithOptions(l, Options{REPL: true})
+
+		program := p.Parse()
+		checkErrors(t, p)
+
+		if found := program.String(); found != test.expect {
+			t.Errorf("%q: wrong program. expect=%q, got=%q", test.input, test.expect, found)
+		}
+	}
+
+	// with AutoSemicolon the ':' must be on the line after the '?',
+	// as a line starting with ':' does not continue the one before
+	autoTests := []struct {
+		input  string
+		expect string
+	}{
+		{"x = a ?\n  b :\n  c", "(x = (if a then b else c))"},
+		{"x = a?\n-b : c\n", "(x = (if a then (-b) else c))"},
+		{"x = a ?\n-b\n", "(x = (a?))(-b)"},
+	}
+
+	for _, test := range autoTests {
+		p := NewWithOptions(lexer.New("parser_test_try", test.input), Options{AutoSemicolon: true})
+
+		program := p.Parse()
+		checkErrors(t, p)
+
+		if found := program.String(); found != test.expect {
+			t.Errorf("%q: wrong program. expect=%q, got=%q", test.input, test.expect, found)
+		}
+	}
+
+	l := lexer.New("parser_test_try", "if f(x)? { }")
+	p := New(l)
+
+	program := p.Parse()
+	checkErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.IfStatement)
+	try, ok := stmt.Condition.(*ast.TryExpression)
+	if !ok {
+		t.Fatalf("condition not *ast.TryExpression. got=%T", stmt.Condition)
+	}
+	if _, ok := try.Value.(*ast.CallExpression); !ok {
+		t.Errorf("try.Value not *ast.CallExpression. got=%T", try.Value)
+	}
+	if loc := try.Location(); loc.Col != 8 {
+		t.Errorf("wrong location. expect column 8, got=%s", loc)
+	}
+
+	l = lexer.New("parser_test_try", "a ? b;")
+	p = New(l)
+	p.Parse()
+
+	expect := `parser_test_try:1:6: expected next token to be ":", got ";" instead`
+	if len(p.Errors()) == 0 || p.Errors()[0] != expect {
+		t.Errorf("wrong errors. expect=%q, got=%q", expect, p.Errors())
+	}
+}
+
+func TestRangeExpression(t *testing.T) {
+	tests := []struct {
+		input     string
+		low       interface{}
+		high      interface{}
+		inclusive bool
+	}{
+		{"0..10;", 0, 10, false},
+		{"1..=n;", 1, "n", true},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_range", test.input)
+		p := New(l)
+
+		program := p.Parse()
+		checkErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		expr, ok := stmt.Expression.(*ast.RangeExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression not *ast.RangeExpression. got=%T", stmt.Expression)
+		}
+
+		testPrimaryExpression(t, expr.Low, test.low)
+		testPrimaryExpression(t, expr.High, test.high)
+
+		if expr.Inclusive != test.inclusive {
+			t.Errorf("expr.Inclusive not %t. got=%t", test.inclusive, expr.Inclusive)
+		}
+	}
+
+	_, errs := Parse("parser_test_range", "a..b..c;")
+	expect := "parser_test_range:1:5: ranges cannot be chained"
+	if len(errs) == 0 || errs[0].Error() != expect {
+		t.Errorf("wrong errors for a chained range. expect=%q, got=%v", expect, errs)
+	}
+}
+
+func TestForInStatement(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectIndex  string
+		expectVar    string
+		expectString string
+	}{
+		{"for x in xs { f(x); }", "", "x", "for x in xs { f(x) }"},
+		{"for i, x in arr { break; }", "i", "x", "for i, x in arr { break; }"},
+		{"rows: for row in 0..n { continue rows; }", "", "row", "rows: for row in (0 .. n) { continue rows; }"},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_for", test.input)
+		p := New(l)
+
+		program := p.Parse()
+		checkErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ForInStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] not *ast.ForInStatement. got=%T", program.Statements[0])
+		}
+
+		if test.expectIndex == "" {
+			if stmt.IndexVar != nil {
+				t.Errorf("stmt.IndexVar not nil. got=%s", stmt.IndexVar)
+			}
+		} else {
+			testIdentifier(t, stmt.IndexVar, test.expectIndex)
+		}
+		testIdentifier(t, stmt.Var, test.expectVar)
+
+		if found := stmt.String(); found != test.expectString {
+			t.Errorf("stmt.String() wrong. expect=%q, got=%q", test.expectString, found)
+		}
+	}
+}
+
+func TestForInErrors(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect string
+	}{
+		{"for i, x, y in xs { }", "parser_test_for:1:11: for loops take at most two variables"},
+		{"for x xs { }", `parser_test_for:1:7: expected next token to be "in", got "xs" instead`},
+		{"for in xs { }", `parser_test_for:1:5: expected next token to be "identifier", got "in" instead`},
+		{"for x in xs { } break;", "parser_test_for:1:17: break outside of a loop"},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_for", test.input)
+		p := New(l)
+
+		p.Parse()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected an error for %q", test.input)
+			continue
+		}
+
+		if msg := p.Errors()[0]; msg != test.expect {
+			t.Errorf("wrong error message for %q. expect=%q, got=%q", test.input, test.expect, msg)
+		}
+	}
+}
+
+func TestDoExpression(t *testing.T) {
+	input := "let x = do { log(); 42 };"
+
+	l := lexer.New("parser_test_do", input)
+	p := New(l)
+
+	program := p.Parse()
+	checkErrors(t, p)
+
+	if n := len(program.Statements); n != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", n)
+	}
+
+	stmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] not *ast.LetStatement. got=%T", program.Statements[0])
+	}
+
+	do, ok := stmt.InitValue.(*ast.DoExpression)
+	if !ok {
+		t.Fatalf("stmt.InitValue not *ast.DoExpression. got=%T", stmt.InitValue)
+	}
+
+	if n := len(do.Body.Statements); n != 2 {
+		t.Fatalf("do.Body.Statements does not contain 2 statements. got=%d", n)
+	}
+
+	call, ok := do.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("do.Body.Statements[0] not *ast.ExpressionStatement. got=%T", do.Body.Statements[0])
+	}
+
+	if _, ok := call.Expression.(*ast.CallExpression); !ok {
+		t.Fatalf("call.Expression not *ast.CallExpression. got=%T", call.Expression)
+	}
+
+	value, ok := do.Body.Statements[1].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("do.Body.Statements[1] not *ast.ExpressionStatement. got=%T", do.Body.Statements[1])
+	}
+
+	if !testPrimaryExpression(t, value.Expression, 42) {
+		return
+	}
+}
+
+func TestDoExpressionEndsInStatement(t *testing.T) {
+	l := lexer.New("parser_test_do", "let x = do { log(); let y = 1; };")
+	p := New(l)
+
+	p.Parse()
+
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected an error for a do block ending in a statement")
+	}
+
+	expect := "parser_test_do:1:21: do block must end with an expression"
+	if msg := p.Errors()[0]; msg != expect {
+		t.Errorf("wrong error message. expect=%q, got=%q", expect, msg)
+	}
+}
+
+func TestParseSource(t *testing.T) {
+	input := `let a = 1;
+let = 2;
+let b = a + 3;
+`
+	program, errors := Parse("parser_test_parse", input)
+
+	if program == nil {
+		t.Fatalf("Parse returned a nil program")
+	}
+
+	if n := len(program.Statements); n != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", n)
+	}
+
+	for i, name := range []string{"a", "b"} {
+		stmt, ok := program.Statements[i].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("program.Statements[%d] not *ast.LetStatement. got=%T", i, program.Statements[i])
+		}
+
+		if !testIdentifier(t, stmt.Ident, name) {
+			return
+		}
+	}
+
+	if n := len(errors); n != 1 {
+		t.Fatalf("expected 1 error. got=%d %v", n, errors)
+	}
+
+	expectLoc := token.SrcLoc{File: "parser_test_parse", Line: 2, Col: 5}
+	if errors[0].Loc != expectLoc {
+		t.Errorf("wrong error location. expect=%s, got=%s", expectLoc, errors[0].Loc)
+	}
+
+	expectMsg := `expected next token to be "identifier", got "=" instead`
+	if errors[0].Message != expectMsg {
+		t.Errorf("wrong error message. expect=%q, got=%q", expectMsg, errors[0].Message)
+	}
+}
+
+func TestAssignExpression(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectOperator string
+		expectTarget   string
+		expectString   string
+	}{
+		{"x = 1;", "=", "x", "(x = 1)"},
+		{"a = b = c;", "=", "a", "(a = (b = c))"},
+		{`m["k"][0] += 1;`, "+=", `((m["k"])[0])`, `(((m["k"])[0]) += 1)`},
+		{"x[0][i + 1][2] -= y * 2;", "-=", "(((x[0])[(i + 1)])[2])", "((((x[0])[(i + 1)])[2]) -= (y * 2))"},
+		{"grid[row(0)][col] *= 2;", "*=", "((grid[row(0)])[col])", "(((grid[row(0)])[col]) *= 2)"},
+		{"get()[0] /= 4;", "/=", "(get()[0])", "((get()[0]) /= 4)"},
+		{"p.x = 1;", "=", "(p.x)", "((p.x) = 1)"},
+		{"a[0].b.c += 1;", "+=", "(((a[0]).b).c)", "((((a[0]).b).c) += 1)"},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_assign", test.input)
+		p := New(l)
+
+		program := p.Parse()
+		checkErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+
+		assign, ok := stmt.Expression.(*ast.AssignExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression not *ast.AssignExpression. got=%T", stmt.Expression)
+		}
+
+		if assign.Operator != test.expectOperator {
+			t.Errorf("assign.Operator not %q. got=%q", test.expectOperator, assign.Operator)
+		}
+
+		if target := assign.Target.String(); target != test.expectTarget {
+			t.Errorf("assign.Target wrong. expect=%q, got=%q", test.expectTarget, target)
+		}
+
+		if str := program.String(); str != test.expectString {
+			t.Errorf("program.String() wrong. expect=%q, got=%q", test.expectString, str)
+		}
+	}
+}
+
+func TestInvalidAssignTarget(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect string
+	}{
+		{"f() += 1;", "parser_test_assign:1:2: cannot assign to f()"},
+		{"1 = 2;", "parser_test_assign:1:1: cannot assign to 1"},
+		{"a + b = c;", "parser_test_assign:1:3: cannot assign to (a + b)"},
+		{`"s".len = c;`, `parser_test_assign:1:4: cannot assign to ("s".len)`},
+		{"a.1 = c;", `parser_test_assign:1:3: expected next token to be "identifier", got "1" instead`},
+		{`"s"[0] = c;`, `parser_test_assign:1:4: cannot assign to ("s"[0])`},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_assign", test.input)
+		p := New(l)
+
+		p.Parse()
+
+		if len(p.Errors()) == 0 {
+			t.Fatalf("expected an error for %q", test.input)
+		}
+
+		if msg := p.Errors()[0]; msg != test.expect {
+			t.Errorf("wrong error message. expect=%q, got=%q", test.expect, msg)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	program := &ast.Program{
+		Statements: []ast.Statement{
+			// let myVar = anotherVar;
+			// return myVar;
+			&ast.LetStatement{
+				Token: token.Token{
+					Type: token.LET,
+					Word: "let",
+				},
+				Ident: &ast.Identifier{
+					Token: token.Token{
+						Type: token.IDENT,
+						Word: "myVar",
+					},
+					Value: "myVar",
+				},
+				InitValue: &ast.Identifier{
+					Token: token.Token{
+						Type: token.IDENT,
+						Word: "anotherVar",
+					},
+					Value: "anotherVar",
+				},
+			},
+			&ast.ReturnStatement{
+				Token: token.Token{
+					Type: token.RETURN,
+					Word: "return",
+				},
+				ReturnValue: &ast.Identifier{
+					Token: token.Token{
+						Type: token.IDENT,
+						Word: "myVar",
+					},
+					Value: "myVar",
+				},
+			},
 		},
 	}
 
@@ -909,7 +3965,7 @@ func testPrimaryExpression(t *testing.T, expr ast.Expression, expect interface{}
 	}
 }
 
-func testFunctionParameterParsing(t *testing.T, parameters []*ast.Identifier, expectedParams []string) bool {
+func testFunctionParameterParsing(t *testing.T, parameters []*ast.Param, expectedParams []string) bool {
 	if len(parameters) != len(expectedParams) {
 		t.Errorf("parameter arity wrong. expect %d, got=%d\n",
 			len(expectedParams), len(parameters))
@@ -917,7 +3973,7 @@ func testFunctionParameterParsing(t *testing.T, parameters []*ast.Identifier, ex
 	}
 
 	for i, ident := range expectedParams {
-		if !testIdentifier(t, parameters[i], ident) {
+		if !testIdentifier(t, parameters[i].Ident, ident) {
 			return false
 		}
 	}
@@ -1036,3 +4092,571 @@ func logErrors(t *testing.T, p *Parser) {
 		t.Errorf("parser error: %s", message)
 	}
 }
+
+func TestMaxDepth(t *testing.T) {
+	input := strings.Repeat("(", 5000) + "1" + strings.Repeat(")", 5000) + ";"
+
+	p := NewWithOptions(lexer.New("parser_test_depth", input), Options{MaxDepth: 100})
+	p.Parse()
+
+	errors := p.errors
+	if len(errors) == 0 {
+		t.Fatalf("expected an error for 5000 nested parens")
+	}
+	if msg := errors[0].Message; msg != "maximum nesting depth exceeded" {
+		t.Errorf("wrong error message. got=%q", msg)
+	}
+	// at the first '(' past the limit
+	if loc := errors[0].Loc; loc.Line != 1 || loc.Col != 101 {
+		t.Errorf("wrong error location. got=%s", loc)
+	}
+
+	// blocks count too
+	input = strings.Repeat("{ ", 50) + strings.Repeat("} ", 50)
+	p = NewWithOptions(lexer.New("parser_test_depth", input), Options{MaxDepth: 10})
+	p.Parse()
+	if errors := p.errors; len(errors) == 0 || errors[0].Message != "maximum nesting depth exceeded" {
+		t.Errorf("expected the depth error for nested blocks. got=%v", errors)
+	}
+
+	// a prefix chain stops at the limit with a single error
+	// and no statement left behind with missing operands
+	for _, op := range []string{"-", "!", "~"} {
+		input = "let x = " + strings.Repeat(op, 3000) + "1;"
+		p = NewWithOptions(lexer.New("parser_test_depth", input), Options{MaxDepth: 100})
+		program := p.Parse()
+		if errors := p.Errors(); len(errors) != 1 || !strings.HasSuffix(errors[0], "maximum nesting depth exceeded") {
+			t.Errorf("%s: expected a single depth error. got=%d: %v", op, len(errors), errors[:min(len(errors), 3)])
+		}
+		if n := len(program.Statements); n != 0 {
+			t.Errorf("%s: broken statement kept. got=%s", op, program.Statements[0])
+		}
+	}
+
+	// a prefix operator without an operand is a single error
+	p = New(lexer.New("parser_test_depth", "-;"))
+	p.Parse()
+	if errors := p.Errors(); len(errors) != 1 {
+		t.Errorf("expected a single error for '-;'. got=%v", errors)
+	}
+
+	// nesting under the limit parses
+	input = strings.Repeat("(", 20) + "1" + strings.Repeat(")", 20) + ";"
+	p = NewWithOptions(lexer.New("parser_test_depth", input), Options{MaxDepth: 100})
+	p.Parse()
+	checkErrors(t, p)
+}
+
+func TestChainComparisons(t *testing.T) {
+	tests := []struct {
+		input   string
+		chained string
+		plain   string
+	}{
+		{"a < b < c", "((a < b) && (b < c))", "((a < b) < c)"},
+		{"a < b <= c > d", "(((a < b) && (b <= c)) && (c > d))", "(((a < b) <= c) > d)"},
+		{"a < b + 1 < c", "((a < (b + 1)) && ((b + 1) < c))", "((a < (b + 1)) < c)"},
+		{"(a < b) < c", "((a < b) < c)", "((a < b) < c)"},
+		{"a < b == c < d", "((a < b) == (c < d))", "((a < b) == (c < d))"},
+	}
+
+	for _, test := range tests {
+		for _, chain := range []bool{true, false} {
+			l := lexer.New("parser_test_chain", test.input)
+			p := NewWithOptions(l, Options{ChainComparisons: chain, REPL: true})
+
+			program := p.Parse()
+			checkErrors(t, p)
+
+			expect := test.plain
+			if chain {
+				expect = test.chained
+			}
+			if found := program.String(); found != expect {
+				t.Errorf("%q with ChainComparisons=%t. expect=%q, got=%q", test.input, chain, expect, found)
+			}
+		}
+	}
+
+	// the '&&' sits at the operator that extends the chain
+	l := lexer.New("parser_test_chain", "a < b < c;")
+	p := NewWithOptions(l, Options{ChainComparisons: true})
+
+	program := p.Parse()
+	checkErrors(t, p)
+
+	and := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression)
+	if and.Token.Type != token.AND || and.Token.Loc.Col != 7 {
+		t.Errorf("wrong token for the '&&'. got=%q at %s", and.Token.Word, and.Token.Loc)
+	}
+}
+
+func TestInfixOperatorType(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect token.TokenType
+	}{
+		{"a + b", token.PLUS},
+		{"a == b", token.EQ},
+		{"a and b", token.AND},
+		{"a ** b", token.POW},
+		{"a >= b", token.GE},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_optype", test.input)
+		p := NewWithOptions(l, Options{REPL: true})
+
+		program := p.Parse()
+		checkErrors(t, p)
+
+		infix := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression)
+		if infix.OpType != test.expect {
+			t.Errorf("%q: wrong OpType. expect=%s, got=%s", test.input, test.expect, infix.OpType)
+		}
+	}
+
+	// the '&&' joining a chain has its type as well
+	l := lexer.New("parser_test_optype", "a < b < c;")
+	p := NewWithOptions(l, Options{ChainComparisons: true})
+
+	program := p.Parse()
+	checkErrors(t, p)
+
+	and := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression)
+	if and.OpType != token.AND || and.Left.(*ast.InfixExpression).OpType != token.LT {
+		t.Errorf("wrong OpType in chain. got=%s", and.OpType)
+	}
+}
+
+func TestPreserveParens(t *testing.T) {
+	tests := []struct {
+		input     string
+		preserved string
+		plain     string
+	}{
+		{"(a + b) * c", "(((a + b)) * c)", "((a + b) * c)"},
+		{"a + (b * c)", "(a + ((b * c)))", "(a + (b * c))"},
+		{"((a))", "((a))", "a"},
+		{"f((1, 2))", "f((1, 2))", "f((1, 2))"},
+		{"(-a).b", "(((-a)).b)", "((-a).b)"},
+	}
+
+	for _, test := range tests {
+		for _, preserve := range []bool{true, false} {
+			l := lexer.New("parser_test_parens", test.input)
+			p := NewWithOptions(l, Options{PreserveParens: preserve, REPL: true})
+
+			program := p.Parse()
+			checkErrors(t, p)
+
+			expect := test.plain
+			if preserve {
+				expect = test.preserved
+			}
+			if found := program.String(); found != expect {
+				t.Errorf("%q with PreserveParens=%t. expect=%q, got=%q", test.input, preserve, expect, found)
+			}
+		}
+	}
+
+	// the group wraps the expression inside the parentheses
+	l := lexer.New("parser_test_parens", "(a + b) * c;")
+	p := NewWithOptions(l, Options{PreserveParens: true})
+
+	program := p.Parse()
+	checkErrors(t, p)
+
+	mul := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression)
+	group, ok := mul.Left.(*ast.GroupExpression)
+	if !ok {
+		t.Fatalf("mul.Left not *ast.GroupExpression. got=%T", mul.Left)
+	}
+	testInfixExpression(t, group.Inner, "a", "+", "b")
+	if group.Location().Col != 1 {
+		t.Errorf("group at wrong location. got=%s", group.Location())
+	}
+}
+
+func TestUnclosedBlock(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect string
+	}{
+		{"fn f() { let x = 1;", "parser_test_unclosed:1:20: unclosed block opened at 1:8"},
+		// the innermost block is reported, once
+		{"fn f() {\n\tif x {\n\t\tlet y = 1;\n", "parser_test_unclosed:4:1: unclosed block opened at 2:7"},
+		{"let x = do { f(1);", "parser_test_unclosed:1:19: unclosed block opened at 1:12"},
+	}
+
+	for _, test := range tests {
+		p := New(lexer.New("parser_test_unclosed", test.input))
+		p.Parse()
+
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Errorf("%q: expected 1 error. got=%v", test.input, errors)
+			continue
+		}
+		if errors[0] != test.expect {
+			t.Errorf("%q: wrong error. expect=%q, got=%q", test.input, test.expect, errors[0])
+		}
+	}
+}
+
+func TestArrowFunction(t *testing.T) {
+	tests := []struct {
+		input  string
+		params []string
+		expect string
+	}{
+		{"let double = fn(x) => x * 2;", []string{"x"}, "let double = fn (x) { return (x * 2); };"},
+		{"let add = fn(a, b) => a + b;", []string{"a", "b"}, "let add = fn (a, b) { return (a + b); };"},
+		{"let one = fn() => 1;", []string{}, "let one = fn () { return 1; };"},
+		{"let k = fn(x) => fn(y) => x;", []string{"x"}, "let k = fn (x) { return fn (y) { return x; }; };"},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_arrow", test.input)
+		p := New(l)
+
+		program := p.Parse()
+		checkErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.LetStatement)
+		fn, ok := stmt.InitValue.(*ast.FunctionLiteral)
+		if !ok {
+			t.Fatalf("stmt.InitValue not *ast.FunctionLiteral. got=%T", stmt.InitValue)
+		}
+
+		if len(fn.Parameters) != len(test.params) {
+			t.Fatalf("%q: wrong number of parameters. got=%d", test.input, len(fn.Parameters))
+		}
+		for i, param := range fn.Parameters {
+			testIdentifier(t, param.Ident, test.params[i])
+		}
+
+		if n := len(fn.Body.Statements); n != 1 {
+			t.Fatalf("%q: body does not contain 1 statement. got=%d", test.input, n)
+		}
+		if ret, ok := fn.Body.Statements[0].(*ast.ReturnStatement); !ok || ret.ReturnValue == nil {
+			t.Errorf("%q: body is not a return of the expression. got=%s", test.input, fn.Body.Statements[0])
+		}
+
+		if found := stmt.String(); found != test.expect {
+			t.Errorf("wrong string. expect=%q, got=%q", test.expect, found)
+		}
+	}
+
+	// the arrow body is a function body of its own
+	p := New(lexer.New("parser_test_arrow", "while x { let f = fn() => do { break; 1 }; }"))
+	p.Parse()
+
+	expect := "parser_test_arrow:1:32: break outside of a loop"
+	if errors := p.Errors(); len(errors) == 0 || errors[0] != expect {
+		t.Errorf("expected %q. got=%v", expect, errors)
+	}
+}
+
+func TestUnexpectedEnd(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect string
+	}{
+		{"let x =", "parser_test_eof:1:8: unexpected end of input"},
+		{"let x = 1;\nlet y =\n", "parser_test_eof:3:1: unexpected end of input"},
+		{"let x = f(1,", "parser_test_eof:1:13: unexpected end of input"},
+		{"let x", `parser_test_eof:1:6: unexpected end of input, expected ";"`},
+		{"let x =", `parser_test_eof:1:8: unexpected end of input`},
+		{"fn f(", `parser_test_eof:1:6: unexpected end of input, expected "identifier"`},
+	}
+
+	for _, test := range tests {
+		p := New(lexer.New("parser_test_eof", test.input))
+		p.Parse()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected an error for %q", test.input)
+			continue
+		}
+		if errors[0] != test.expect {
+			t.Errorf("wrong error for %q. expect=%q, got=%q", test.input, test.expect, errors[0])
+		}
+	}
+}
+
+func TestStraySemicolons(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect []string
+	}{
+		{";; let x = 1; ;", []string{"let x = 1;"}},
+		{";", []string{}},
+		{"let x = 1;; f(x);;;", []string{"let x = 1;", "f(x)"}},
+		{"fn f() { ;; return 1;; ; }", []string{"fn f() { return 1; }"}},
+		{"let y = do { ; 1;; };", []string{"let y = do { 1 };"}},
+	}
+
+	for _, test := range tests {
+		p := New(lexer.New("parser_test_semicolons", test.input))
+		program := p.Parse()
+		checkErrors(t, p)
+
+		if len(program.Statements) != len(test.expect) {
+			t.Fatalf("%q: wrong number of statements. expect=%d, got=%d", test.input, len(test.expect), len(program.Statements))
+		}
+		for i, stmt := range program.Statements {
+			if found := stmt.String(); found != test.expect[i] {
+				t.Errorf("%q: statement %d wrong. expect=%q, got=%q", test.input, i, test.expect[i], found)
+			}
+		}
+	}
+}
+
+func TestParseProgram(t *testing.T) {
+	input := `let x = 1;
+let = 2;
+f(x);
+let y = ;
+`
+	p := New(lexer.New("parser_test_result", input))
+	result := p.ParseProgram()
+
+	if found := result.Program.String(); found != "let x = 1;f(x)" {
+		t.Errorf("wrong program. got=%q", found)
+	}
+
+	expects := []string{
+		`parser_test_result:2:5: expected next token to be "identifier", got "=" instead`,
+		`parser_test_result:4:9: no prefix parse function for ";" found`,
+	}
+	if len(result.Errors) != len(expects) {
+		t.Fatalf("wrong number of errors. expect=%d, got=%v", len(expects), result.Errors)
+	}
+	for i, err := range result.Errors {
+		if err.Error() != expects[i] {
+			t.Errorf("error %d wrong. expect=%q, got=%q", i, expects[i], err.Error())
+		}
+	}
+
+	// the same errors the parser holds
+	if errors := p.Errors(); len(errors) != len(result.Errors) {
+		t.Errorf("result errors differ from the parser's. got=%v", errors)
+	}
+
+	expect := "parser_test_result:4:9: no prefix parse function for \";\" found\nlet y = ;\n        ^"
+	if found := result.Errors[1].Format(input); found != expect {
+		t.Errorf("wrong formatted error.\nexpect=%q\ngot=   %q", expect, found)
+	}
+}
+
+func TestProgramLocation(t *testing.T) {
+	program := New(lexer.New("parser_test_loc", "  \n\t\n")).Parse()
+	if loc := program.Location(); loc != (token.SrcLoc{}) {
+		t.Errorf("empty program has a location. got=%q", loc)
+	}
+
+	program = New(lexer.New("parser_test_loc", "\n  let x = 1;\nx;")).Parse()
+	if loc := program.Location(); loc.String() != "parser_test_loc:2:3:" {
+		t.Errorf("program not at its first statement. got=%q", loc)
+	}
+
+	// every node below it is located as well, down to the literals
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node.Location().Line == 0 {
+			t.Errorf("%T %s has no location", node, node)
+		}
+		return true
+	})
+}
+
+func TestPeekN(t *testing.T) {
+	p := NewWithOptions(lexer.New("parser_test_peek", "let x = f(1);"), Options{KeepTokens: true})
+
+	// looking further ahead first leaves the nearer tokens in place
+	peeks := []struct {
+		k      int
+		expect string
+	}{
+		{4, "("}, {0, "let"}, {1, "x"}, {2, "="}, {6, ")"}, {3, "f"}, {9, "eof"}, {12, "eof"},
+	}
+	for _, peek := range peeks {
+		if tok := p.peekN(peek.k); tok.Word != peek.expect {
+			t.Errorf("peekN(%d) wrong. expect=%q, got=%q", peek.k, peek.expect, tok.Word)
+		}
+	}
+
+	// reading goes through the tokens peeked at, in order
+	expect := []string{"let", "x", "=", "f", "(", "1", ")", ";", "eof"}
+	for i, word := range expect {
+		if p.currToken.Word != word {
+			t.Errorf("token %d wrong. expect=%q, got=%q", i, word, p.currToken.Word)
+		}
+		if i+2 < len(expect) && p.peekN(2).Word != expect[i+2] {
+			t.Errorf("peekN(2) at token %d wrong. expect=%q, got=%q", i, expect[i+2], p.peekN(2).Word)
+		}
+		p.readToken()
+	}
+
+	// peeking does not read a token twice
+	if len(p.tokens) != len(expect) {
+		t.Errorf("wrong number of tokens kept. expect=%d, got=%d", len(expect), len(p.tokens))
+	}
+}
+
+func TestNumberSuffixes(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect any
+	}{
+		{"5i", int64(5)},
+		{"5f", 5.0},
+		{"0.25f", 0.25},
+		{"1e3f", 1000.0},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_suffix", test.input)
+		p := NewWithOptions(l, Options{REPL: true})
+
+		program := p.Parse()
+		checkErrors(t, p)
+
+		expr := program.Statements[0].(*ast.ExpressionStatement).Expression
+		switch expect := test.expect.(type) {
+		case int64:
+			testIntLiteral(t, expr, expect)
+		case float64:
+			lit, ok := expr.(*ast.FloatLiteral)
+			if !ok {
+				t.Errorf("%q: not *ast.FloatLiteral. got=%T", test.input, expr)
+				continue
+			}
+			if lit.Value != expect {
+				t.Errorf("%q: wrong value. expect=%g, got=%g", test.input, expect, lit.Value)
+			}
+		}
+
+		// the word is the number without its suffix
+		if word := expr.TokenWord(); word != strings.TrimRight(test.input, "if") {
+			t.Errorf("%q: wrong TokenWord. got=%q", test.input, word)
+		}
+	}
+
+	l := lexer.New("parser_test_suffix", "let x = 5.0i;")
+	p := New(l)
+	p.Parse()
+
+	expect := "parser_test_suffix:1:9: float literal 5.0i cannot take the integer suffix"
+	if len(p.Errors()) == 0 || p.Errors()[0] != expect {
+		t.Errorf("wrong errors. expect=%q, got=%q", expect, p.Errors())
+	}
+}
+
+func TestIntOverflow(t *testing.T) {
+	big, _ := new(big.Int).SetString("99999999999999999999", 10)
+
+	p := New(lexer.New("parser_test_overflow", "let x = 99999999999999999999;"))
+	p.Parse()
+
+	expect := `parser_test_overflow:1:9: could not parse "99999999999999999999" as integer. ` +
+		`strconv.ParseInt: parsing "99999999999999999999": value out of range`
+	if len(p.Errors()) != 1 || p.Errors()[0] != expect {
+		t.Errorf("wrong errors for the default policy. expect=%q, got=%q", expect, p.Errors())
+	}
+
+	tests := []struct {
+		input  string
+		policy OverflowPolicy
+		check  func(ast.Expression) bool
+	}{
+		{"99999999999999999999", OverflowToFloat, func(expr ast.Expression) bool {
+			lit, ok := expr.(*ast.FloatLiteral)
+			if !ok {
+				t.Errorf("expr not *ast.FloatLiteral. got=%T", expr)
+				return false
+			}
+			// the nearest float, with the literal as written
+			if lit.Value != 1e20 || lit.TokenWord() != "99999999999999999999" {
+				t.Errorf("wrong float literal. got=%g from %q", lit.Value, lit.TokenWord())
+				return false
+			}
+			return true
+		}},
+		{"99999999999999999999", OverflowToBigInt, func(expr ast.Expression) bool {
+			lit, ok := expr.(*ast.BigIntLiteral)
+			if !ok {
+				t.Errorf("expr not *ast.BigIntLiteral. got=%T", expr)
+				return false
+			}
+			if lit.Value.Cmp(big) != 0 || lit.String() != "99999999999999999999" {
+				t.Errorf("wrong big literal. got=%s, printed as %q", lit.Value, lit)
+				return false
+			}
+			return true
+		}},
+		// literals that fit stay as they are
+		{"9223372036854775807", OverflowToFloat, func(expr ast.Expression) bool {
+			return testIntLiteral(t, expr, math.MaxInt64)
+		}},
+		{"9223372036854775807", OverflowToBigInt, func(expr ast.Expression) bool {
+			return testIntLiteral(t, expr, math.MaxInt64)
+		}},
+	}
+
+	for _, test := range tests {
+		l := lexer.New("parser_test_overflow", test.input)
+		p := NewWithOptions(l, Options{IntOverflow: test.policy, REPL: true})
+
+		program := p.Parse()
+		checkErrors(t, p)
+
+		if !test.check(program.Statements[0].(*ast.ExpressionStatement).Expression) {
+			t.Logf("input %q with policy %d", test.input, test.policy)
+		}
+	}
+}
+
+func TestDumpAST(t *testing.T) {
+	input := `let x = 5;
+fn add(a, b = 2) {
+	return a + b;
+}
+if x > 1 {
+	println add(x, 2);
+} else if !ok {
+	let y;
+} else {
+	print f([...xs])[0].name;
+}
+let g = fn(n) => n * 2.5;
+`
+	expect := `(program
+  (let x (int 5))
+  (fn add (params a (default b (int 2)))
+    (block
+      (return (infix + a b))))
+  (if (infix > x (int 1))
+    (block
+      (println (call add x (int 2))))
+    (if (prefix ! ok)
+      (block
+        (let y))
+      (block
+        (print (member (index (call f (array (spread xs))) (int 0)) name)))))
+  (let g
+    (fn (params n)
+      (block
+        (return (infix * n (float 2.5)))))))`
+
+	l := lexer.New("parser_test_dump", input)
+	p := New(l)
+
+	program := p.Parse()
+	checkErrors(t, p)
+
+	if dump := DumpAST(program); dump != expect {
+		t.Errorf("wrong dump. expect=\n%s\ngot=\n%s", expect, dump)
+	}
+}
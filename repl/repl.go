@@ -4,42 +4,41 @@ import (
 	"RoLang/evaluator"
 	"RoLang/lexer"
 	"RoLang/parser"
+	"RoLang/token"
 
 	"bufio"
-	"fmt"
 	"io"
 	"strings"
 )
 
-const prompt = "|> "
+const (
+	prompt       = "|> "
+	continuation = ".. "
+)
 
 func Start(in io.Reader, out io.Writer, err io.Writer) {
 	scanner := bufio.NewScanner(in)
+	// the evaluator context lives across submissions so
+	// bindings from earlier lines stay visible
 	evaluator.Init(in, out, err)
 	for {
-		fmt.Print(prompt)
+		io.WriteString(out, prompt)
 
-		scanned := scanner.Scan()
-		if !scanned {
+		input, ok := readInput(scanner, out)
+		if !ok {
 			return
 		}
 
-		line := strings.TrimSpace(scanner.Text())
-		size := len(line)
-		if size == 0 {
+		if len(strings.TrimSpace(input)) == 0 {
 			continue
 		}
 
-		l := lexer.New("repl", line)
-		p := parser.New(l)
+		l := lexer.New("repl", input)
+		p := parser.NewWithOptions(l, parser.Options{
+			REPL:          true,
+			AutoSemicolon: true,
+		})
 
-		// var node ast.Node
-		// // lines ending with semicolon are statements
-		// if c := line[size-1]; c == ';' {
-		// 	node = p.ParseStatement()
-		// } else {
-		// 	node = p.ParseExpression(parser.NONE)
-		// }
 		program := p.Parse()
 
 		if len(p.Errors()) != 0 {
@@ -49,16 +48,43 @@ func Start(in io.Reader, out io.Writer, err io.Writer) {
 			continue
 		}
 
-		evaluator.Evaluate(program)
-
-		// if result != nil {
-		// 	io.WriteString(out, fmt.Sprintf("%v", result))
-		// 	io.WriteString(out, "\n")
-		// } else if len(errors) != 0 {
-		// 	io.WriteString(err, evaluator.Errors())
-		// 	io.WriteString(err, "\n")
-		// 	io.WriteString(out, "null\n")
-		// 	evaluator.ClearErr()
-		// }
+		result := evaluator.Evaluate(program)
+		if result != nil {
+			io.WriteString(out, evaluator.Inspect(result))
+			io.WriteString(out, "\n")
+		}
+	}
+}
+
+// reads lines until every bracket opened in the submission is
+// closed, returns false once the input is exhausted
+func readInput(scanner *bufio.Scanner, out io.Writer) (string, bool) {
+	var input string
+
+	for scanner.Scan() {
+		input += scanner.Text() + "\n"
+		if unclosed(input) <= 0 {
+			return input, true
+		}
+		io.WriteString(out, continuation)
+	}
+
+	// parse whatever is left so unbalanced input still reports errors
+	return input, len(input) != 0
+}
+
+// counts the brackets left open in the input
+func unclosed(input string) int {
+	depth := 0
+
+	for _, tok := range lexer.New("repl", input).Tokens() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			depth--
+		}
 	}
+
+	return depth
 }
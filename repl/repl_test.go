@@ -0,0 +1,33 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestStart(t *testing.T) {
+	input := `let x = 1
+x + 1
+fn add(a, b) {
+	return a + b;
+}
+add(x, 2)
+let y = ;
+x == 1
+`
+	out := new(bytes.Buffer)
+	err := new(bytes.Buffer)
+
+	Start(strings.NewReader(input), out, err)
+
+	expectOut := "|> |> 2\n|> .. .. |> 3\n|> null\n|> true\n|> "
+	if found := out.String(); found != expectOut {
+		t.Errorf("wrong output. expect=%q, got=%q", expectOut, found)
+	}
+
+	expectErr := "repl:1:9: no prefix parse function for \";\" found\n"
+	if found := err.String(); found != expectErr {
+		t.Errorf("wrong error output. expect=%q, got=%q", expectErr, found)
+	}
+}
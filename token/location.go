@@ -1,6 +1,9 @@
 package token
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type SrcLoc struct {
 	File string
@@ -11,3 +14,31 @@ type SrcLoc struct {
 func (loc SrcLoc) String() string {
 	return fmt.Sprintf("%s:%d:%d:", loc.File, loc.Line, loc.Col)
 }
+
+// Renders msg the way compilers do, with the line of source at loc
+// below it and a '^' under the column. Lines and columns count from
+// 1 with a tab as one column, a line past the end of source is left out.
+func FormatError(source string, loc SrcLoc, msg string) string {
+	out := fmt.Sprintf("%s %s", loc, msg)
+
+	lines := strings.Split(source, "\n")
+	if loc.Line == 0 || loc.Line > uint(len(lines)) {
+		return out
+	}
+	line := strings.TrimSuffix(lines[loc.Line-1], "\r")
+
+	// columns count bytes, one space per character keeps the
+	// caret under characters of several bytes, and tabs stay tabs
+	var caret strings.Builder
+	prefix := line[:min(int(max(loc.Col, 1))-1, len(line))]
+	for _, r := range prefix {
+		if r == '\t' {
+			caret.WriteByte('\t')
+		} else {
+			caret.WriteByte(' ')
+		}
+	}
+	caret.WriteByte('^')
+
+	return out + "\n" + line + "\n" + caret.String()
+}
@@ -1,101 +1,319 @@
 package token
 
+import "fmt"
+
 type TokenType uint
 
 const (
-	ERR = iota
+	ERR TokenType = iota
 	EOF
 
 	// Identifiers and literals
-	IDENT  // x, y, name
-	INT    // 1032
-	FLOAT  // 5.2, 0.23
-	STRING // "hello" "world"
+	IDENT    // x, y, name
+	INT      // 1032
+	FLOAT    // 5.2, 0.23
+	STRING   // "hello" "world"
+	TEMPLATE // `hello ${name}`
 
 	// Operators
-	ASSIGN // "="
-	PLUS   // "+"
-	MINUS  // "-"
-	BANG   // "!"
-	STAR   // "*"
-	SLASH  // "/"
-	LT     // "<"
-	GT     // ">"
-
-	EQ // "=="
-	NE // "!="
-	LE // "<="
-	GE // ">="
+	ASSIGN       // "="
+	PLUS_ASSIGN  // "+="
+	MINUS_ASSIGN // "-="
+	STAR_ASSIGN  // "*="
+	SLASH_ASSIGN // "/="
+	PLUS         // "+"
+	MINUS        // "-"
+	BANG         // "!"
+	STAR         // "*"
+	SLASH        // "/"
+	POW          // "**"
+	LT           // "<"
+	GT           // ">"
+
+	EQ        // "=="
+	NE        // "!="
+	LE        // "<="
+	GE        // ">="
+	SPACESHIP // "<=>"
+	AND       // "&&" "and"
+	OR        // "||" "or"
+
+	AMP   // "&"
+	PIPE  // "|"
+	TILDE // "~"
+	SHL   // "<<"
+	SHR   // ">>"
 
 	// Delimeters
-	COMMA  // ","
-	SEMCOL // ";"
+	COMMA    // ","
+	SEMCOL   // ";"
+	NEWLINE  // "\n" only when the lexer is asked for them
+	COLON    // ":"
+	QUESTION // "?"
+	ELLIPSIS // "..."
+	ARROW    // "=>"
+	DOT      // "."
+	DOTDOT   // ".."
+	DOTDOTEQ // "..="
+	AT       // "@"
+	HASH     // "#["
 
 	// Brackets
-	LPAREN // "("
-	RPAREN // ")"
-	LBRACE // "{"
-	RBRACE // "}"
+	LPAREN   // "("
+	RPAREN   // ")"
+	LBRACE   // "{"
+	RBRACE   // "}"
+	LBRACKET // "["
+	RBRACKET // "]"
 
 	// Keywords
-	FN     // "fn"
-	RETURN // "return"
-	LET    // "let"
-	TRUE   // "true"
-	FALSE  // "false"
-	IF     // "if"
-	ELSE   // "else"
+	FN      // "fn"
+	RETURN  // "return"
+	LET     // "let"
+	CONST   // "const"
+	TRUE    // "true"
+	FALSE   // "false"
+	IF      // "if"
+	ELSE    // "else"
+	ELIF    // "elif"
+	DO      // "do"
+	WHILE   // "while"
+	FOR     // "for"
+	IN      // "in"
+	BREAK   // "break"
+	CONT    // "continue"
+	IMPORT  // "import"
+	AS      // "as"
+	ASSERT  // "assert"
+	DEFER   // "defer"
+	PRINT   // "print"
+	PRINTLN // "println"
+
+	BETWEEN // "between"
+	IS      // "is"
+	UNLESS  // "unless"
+	THEN    // "then"
+	TYPEOF  // "typeof"
 
 	TOTAL // total number of tokens
 )
 
 var TokenString = []string{
-	EOF:    "eof",
-	ERR:    "error",
-	IDENT:  "identifier",
-	INT:    "integer",
-	FLOAT:  "float",
-	ASSIGN: "=",
-	PLUS:   "+",
-	MINUS:  "-",
-	BANG:   "!",
-	STAR:   "*",
-	SLASH:  "/",
-	LT:     "<",
-	GT:     ">",
-	EQ:     "==",
-	NE:     "!=",
-	LE:     "<=",
-	GE:     ">=",
-	COMMA:  ",",
-	SEMCOL: ";",
-	LPAREN: "(",
-	RPAREN: ")",
-	LBRACE: "{",
-	RBRACE: "}",
-	FN:     "fn",
-	RETURN: "return",
-	LET:    "let",
-	TRUE:   "true",
-	FALSE:  "false",
-	IF:     "if",
-	ELSE:   "else",
+	EOF:          "eof",
+	ERR:          "error",
+	IDENT:        "identifier",
+	INT:          "integer",
+	FLOAT:        "float",
+	STRING:       "string",
+	TEMPLATE:     "template",
+	ASSIGN:       "=",
+	PLUS_ASSIGN:  "+=",
+	MINUS_ASSIGN: "-=",
+	STAR_ASSIGN:  "*=",
+	SLASH_ASSIGN: "/=",
+	PLUS:         "+",
+	MINUS:        "-",
+	BANG:         "!",
+	STAR:         "*",
+	SLASH:        "/",
+	POW:          "**",
+	LT:           "<",
+	GT:           ">",
+	EQ:           "==",
+	NE:           "!=",
+	LE:           "<=",
+	GE:           ">=",
+	SPACESHIP:    "<=>",
+	AND:          "&&",
+	OR:           "||",
+	AMP:          "&",
+	PIPE:         "|",
+	TILDE:        "~",
+	SHL:          "<<",
+	SHR:          ">>",
+	COMMA:        ",",
+	SEMCOL:       ";",
+	NEWLINE:      "newline",
+	COLON:        ":",
+	QUESTION:     "?",
+	ELLIPSIS:     "...",
+	ARROW:        "=>",
+	DOT:          ".",
+	DOTDOT:       "..",
+	DOTDOTEQ:     "..=",
+	AT:           "@",
+	HASH:         "#[",
+	LPAREN:       "(",
+	RPAREN:       ")",
+	LBRACE:       "{",
+	RBRACE:       "}",
+	LBRACKET:     "[",
+	RBRACKET:     "]",
+	FN:           "fn",
+	RETURN:       "return",
+	LET:          "let",
+	CONST:        "const",
+	TRUE:         "true",
+	FALSE:        "false",
+	IF:           "if",
+	ELSE:         "else",
+	ELIF:         "elif",
+	DO:           "do",
+	WHILE:        "while",
+	FOR:          "for",
+	IN:           "in",
+	BREAK:        "break",
+	CONT:         "continue",
+	IMPORT:       "import",
+	AS:           "as",
+	ASSERT:       "assert",
+	DEFER:        "defer",
+	PRINT:        "print",
+	PRINTLN:      "println",
+	BETWEEN:      "between",
+	IS:           "is",
+	UNLESS:       "unless",
+	THEN:         "then",
+	TYPEOF:       "typeof",
+}
+
+// the names of the constants, for debugging output
+var tokenNames = [TOTAL]string{
+	ERR:          "ERR",
+	EOF:          "EOF",
+	IDENT:        "IDENT",
+	INT:          "INT",
+	FLOAT:        "FLOAT",
+	STRING:       "STRING",
+	TEMPLATE:     "TEMPLATE",
+	ASSIGN:       "ASSIGN",
+	PLUS_ASSIGN:  "PLUS_ASSIGN",
+	MINUS_ASSIGN: "MINUS_ASSIGN",
+	STAR_ASSIGN:  "STAR_ASSIGN",
+	SLASH_ASSIGN: "SLASH_ASSIGN",
+	PLUS:         "PLUS",
+	MINUS:        "MINUS",
+	BANG:         "BANG",
+	STAR:         "STAR",
+	SLASH:        "SLASH",
+	POW:          "POW",
+	LT:           "LT",
+	GT:           "GT",
+	EQ:           "EQ",
+	NE:           "NE",
+	LE:           "LE",
+	GE:           "GE",
+	SPACESHIP:    "SPACESHIP",
+	AND:          "AND",
+	OR:           "OR",
+	AMP:          "AMP",
+	PIPE:         "PIPE",
+	TILDE:        "TILDE",
+	SHL:          "SHL",
+	SHR:          "SHR",
+	COMMA:        "COMMA",
+	SEMCOL:       "SEMCOL",
+	NEWLINE:      "NEWLINE",
+	COLON:        "COLON",
+	QUESTION:     "QUESTION",
+	ELLIPSIS:     "ELLIPSIS",
+	ARROW:        "ARROW",
+	DOT:          "DOT",
+	DOTDOT:       "DOTDOT",
+	DOTDOTEQ:     "DOTDOTEQ",
+	AT:           "AT",
+	HASH:         "HASH",
+	LPAREN:       "LPAREN",
+	RPAREN:       "RPAREN",
+	LBRACE:       "LBRACE",
+	RBRACE:       "RBRACE",
+	LBRACKET:     "LBRACKET",
+	RBRACKET:     "RBRACKET",
+	FN:           "FN",
+	RETURN:       "RETURN",
+	LET:          "LET",
+	CONST:        "CONST",
+	TRUE:         "TRUE",
+	FALSE:        "FALSE",
+	IF:           "IF",
+	ELSE:         "ELSE",
+	ELIF:         "ELIF",
+	DO:           "DO",
+	WHILE:        "WHILE",
+	FOR:          "FOR",
+	IN:           "IN",
+	BREAK:        "BREAK",
+	CONT:         "CONT",
+	IMPORT:       "IMPORT",
+	AS:           "AS",
+	ASSERT:       "ASSERT",
+	DEFER:        "DEFER",
+	PRINT:        "PRINT",
+	PRINTLN:      "PRINTLN",
+	BETWEEN:      "BETWEEN",
+	IS:           "IS",
+	UNLESS:       "UNLESS",
+	THEN:         "THEN",
+	TYPEOF:       "TYPEOF",
+}
+
+// Returns the name of the constant, like "PLUS"
+func (t TokenType) String() string {
+	if t < TOTAL {
+		return tokenNames[t]
+	}
+
+	return fmt.Sprintf("TokenType(%d)", uint(t))
 }
 
 type Token struct {
 	Loc  SrcLoc
 	Type TokenType
 	Word string
+	// the line breaks before the token are all
+	// escaped with '\', so it continues the line
+	Joined bool
+	// a string written between triple quotes, taken as is
+	Raw bool
+	// only kept by a lexer in trivia mode, the whitespace before
+	// the token split into runs of blanks and line breaks, and the
+	// token as written, which Word may not be for strings
+	Trivia []string
+	Source string
 }
 
 var keywords = map[string]TokenType{
-	"fn":     FN,
-	"return": RETURN,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
+	"fn":       FN,
+	"return":   RETURN,
+	"let":      LET,
+	"const":    CONST,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"elif":     ELIF,
+	"do":       DO,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONT,
+	"import":   IMPORT,
+	"as":       AS,
+	"assert":   ASSERT,
+	"defer":    DEFER,
+	"print":    PRINT,
+	"println":  PRINTLN,
+	// comparison sugar
+	"between": BETWEEN,
+	"is":      IS,
+	"unless":  UNLESS,
+	"then":    THEN,
+	"typeof":  TYPEOF,
+	// word spellings of operators
+	"and": AND,
+	"or":  OR,
+	"not": BANG,
 }
 
 func LookUpKeyword(word string) TokenType {
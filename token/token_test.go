@@ -0,0 +1,53 @@
+package token
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestTokenTypeString(t *testing.T) {
+	seen := map[string]TokenType{}
+
+	for tokType := TokenType(0); tokType < TOTAL; tokType++ {
+		name := tokType.String()
+		if name == "" || strings.HasPrefix(name, "TokenType(") {
+			t.Errorf("token type %d has no name", uint(tokType))
+			continue
+		}
+
+		if other, ok := seen[name]; ok {
+			t.Errorf("token types %d and %d are both named %q", uint(other), uint(tokType), name)
+		}
+		seen[name] = tokType
+	}
+
+	if found := fmt.Sprintf("%v", PLUS); found != "PLUS" {
+		t.Errorf("wrong name for PLUS. got=%q", found)
+	}
+	if found := TOTAL.String(); found != fmt.Sprintf("TokenType(%d)", uint(TOTAL)) {
+		t.Errorf("wrong name for an unknown type. got=%q", found)
+	}
+}
+
+func TestFormatError(t *testing.T) {
+	source := "let x = 1;\r\n\tlet = 2;\nlet é = ;\n"
+
+	tests := []struct {
+		loc    SrcLoc
+		expect string
+	}{
+		{SrcLoc{"test", 2, 6}, "test:2:6: expected an identifier\n\tlet = 2;\n\t    ^"},
+		{SrcLoc{"test", 3, 10}, "test:3:10: expected an identifier\nlet é = ;\n        ^"},
+		{SrcLoc{"test", 1, 1}, "test:1:1: expected an identifier\nlet x = 1;\n^"},
+		// the end of input sits on the empty last line
+		{SrcLoc{"test", 4, 1}, "test:4:1: expected an identifier\n\n^"},
+		{SrcLoc{"test", 5, 1}, "test:5:1: expected an identifier"},
+	}
+
+	for _, test := range tests {
+		if found := FormatError(source, test.loc, "expected an identifier"); found != test.expect {
+			t.Errorf("wrong output at %s\nexpect=%q\ngot=   %q", test.loc, test.expect, found)
+		}
+	}
+}
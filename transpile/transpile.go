@@ -0,0 +1,246 @@
+package transpile
+
+import (
+	"RoLang/ast"
+	"RoLang/token"
+
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+)
+
+// errors raised while walking the tree, recovered in ToGo
+type unsupportedError struct {
+	node ast.Node
+}
+
+func (e unsupportedError) Error() string {
+	if loc, ok := e.node.(interface{ Location() token.SrcLoc }); ok {
+		return fmt.Sprintf("%s unsupported node %T", loc.Location(), e.node)
+	}
+	return fmt.Sprintf("unsupported node %T", e.node)
+}
+
+type emitter struct {
+	out   strings.Builder
+	depth int // indentation level
+}
+
+// Translates a program into Go source. Top level functions become
+// Go functions, top level lets become package variables and the
+// remaining statements make up the body of main. Only a numeric
+// subset is supported, so variables, parameters and results are all
+// float64 and the only expressions run for their effect are calls.
+func ToGo(prog *ast.Program) (src string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(unsupportedError)
+			if !ok {
+				panic(r)
+			}
+			err = e
+		}
+	}()
+
+	e := &emitter{}
+	e.line("package main")
+
+	var body []ast.Statement
+	for _, stmt := range prog.Statements {
+		switch s := stmt.(type) {
+		case *ast.FunctionStatement:
+			e.line("")
+			e.function(s)
+		case *ast.LetStatement:
+			if s.InitValue == nil {
+				panic(unsupportedError{s})
+			}
+			e.line("")
+			e.line("var %s float64 = %s", s.Ident.Value, e.expression(s.InitValue))
+		default:
+			body = append(body, stmt)
+		}
+	}
+
+	e.line("")
+	e.line("func main() {")
+	e.statements(body)
+	e.line("}")
+
+	formatted, ferr := format.Source([]byte(e.out.String()))
+	if ferr != nil {
+		return "", fmt.Errorf("generated invalid Go source: %w", ferr)
+	}
+
+	return string(formatted), nil
+}
+
+func (e *emitter) function(s *ast.FunctionStatement) {
+	fn := s.Value
+	if fn.Variadic {
+		panic(unsupportedError{fn})
+	}
+
+	params := make([]string, len(fn.Parameters))
+	for i, param := range fn.Parameters {
+		if param.Default != nil {
+			panic(unsupportedError{param.Default})
+		}
+		params[i] = param.Ident.Value + " float64"
+	}
+
+	result := ""
+	if returnsValue(fn.Body.Statements) {
+		result = " float64"
+	}
+
+	e.line("func %s(%s)%s {", s.Ident.Value, strings.Join(params, ", "), result)
+	e.statements(fn.Body.Statements)
+	e.line("}")
+}
+
+func (e *emitter) statements(stmts []ast.Statement) {
+	e.depth++
+	for _, stmt := range stmts {
+		e.statement(stmt)
+	}
+	e.depth--
+}
+
+func (e *emitter) statement(stmt ast.Statement) {
+	switch s := stmt.(type) {
+	case *ast.LetStatement:
+		if s.InitValue == nil {
+			panic(unsupportedError{s})
+		}
+		e.line("%s := float64(%s)", s.Ident.Value, e.expression(s.InitValue))
+	case *ast.ReturnStatement:
+		if len(s.ReturnValues) > 1 {
+			panic(unsupportedError{s})
+		}
+		if s.ReturnValue == nil {
+			e.line("return")
+		} else {
+			e.line("return %s", e.expression(s.ReturnValue))
+		}
+	case *ast.ExpressionStatement:
+		// Go only allows calls as statements
+		if _, ok := s.Expression.(*ast.CallExpression); !ok {
+			panic(unsupportedError{s})
+		}
+		e.line("%s", e.expression(s.Expression))
+	case *ast.DeferStatement:
+		e.line("defer %s", e.expression(s.Call))
+	case *ast.BlockStatement:
+		e.line("{")
+		e.statements(s.Statements)
+		e.line("}")
+	case *ast.IfStatement:
+		if s.Binding != nil {
+			panic(unsupportedError{s})
+		}
+		e.line("if %s {", e.expression(s.Condition))
+		e.ifTail(s)
+	default:
+		panic(unsupportedError{stmt})
+	}
+}
+
+// writes the then block and the else chain of an if statement
+func (e *emitter) ifTail(s *ast.IfStatement) {
+	e.statements(s.Then.Statements)
+
+	switch elze := s.Else.(type) {
+	case nil:
+		e.line("}")
+	case *ast.IfStatement:
+		if elze.Binding != nil {
+			panic(unsupportedError{elze})
+		}
+		e.line("} else if %s {", e.expression(elze.Condition))
+		e.ifTail(elze)
+	case *ast.BlockStatement:
+		e.line("} else {")
+		e.statements(elze.Statements)
+		e.line("}")
+	default:
+		panic(unsupportedError{elze})
+	}
+}
+
+func (e *emitter) expression(expr ast.Expression) string {
+	switch x := expr.(type) {
+	case *ast.Identifier:
+		return x.Value
+	case *ast.IntegerLiteral:
+		return strconv.FormatInt(x.Value, 10)
+	case *ast.FloatLiteral:
+		return strconv.FormatFloat(x.Value, 'g', -1, 64)
+	case *ast.StringLiteral:
+		return strconv.Quote(x.Value)
+	case *ast.BoolLiteral:
+		return strconv.FormatBool(x.Value)
+	case *ast.PrefixExpression:
+		// word operators are emitted with their symbolic spelling
+		op := token.TokenString[x.Token.Type]
+		if op != "!" && op != "-" && op != "+" {
+			panic(unsupportedError{x})
+		}
+		return fmt.Sprintf("(%s%s)", op, e.expression(x.Right))
+	case *ast.GroupExpression:
+		return fmt.Sprintf("(%s)", e.expression(x.Inner))
+	case *ast.InfixExpression:
+		op := token.TokenString[x.Token.Type]
+		switch op {
+		case "+", "-", "*", "/", "<", ">", "<=", ">=", "==", "!=", "&&", "||":
+		default:
+			panic(unsupportedError{x})
+		}
+		return fmt.Sprintf("(%s %s %s)", e.expression(x.Left), op, e.expression(x.Right))
+	case *ast.CallExpression:
+		if len(x.NamedArgs) != 0 {
+			panic(unsupportedError{x})
+		}
+		args := make([]string, len(x.Arguments))
+		for i, arg := range x.Arguments {
+			args[i] = e.expression(arg)
+		}
+		return fmt.Sprintf("%s(%s)", e.expression(x.Callee), strings.Join(args, ", "))
+	default:
+		panic(unsupportedError{expr})
+	}
+}
+
+func (e *emitter) line(format string, args ...any) {
+	if format != "" {
+		e.out.WriteString(strings.Repeat("\t", e.depth))
+		fmt.Fprintf(&e.out, format, args...)
+	}
+	e.out.WriteString("\n")
+}
+
+// checks whether any control flow path returns a value
+func returnsValue(stmts []ast.Statement) bool {
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *ast.ReturnStatement:
+			if s.ReturnValue != nil {
+				return true
+			}
+		case *ast.BlockStatement:
+			if returnsValue(s.Statements) {
+				return true
+			}
+		case *ast.IfStatement:
+			if returnsValue(s.Then.Statements) {
+				return true
+			}
+			if s.Else != nil && returnsValue([]ast.Statement{s.Else}) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,118 @@
+package transpile
+
+import (
+	"RoLang/ast"
+	"RoLang/lexer"
+	"RoLang/parser"
+
+	goast "go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"go/types"
+	"strings"
+	"testing"
+)
+
+func TestToGo(t *testing.T) {
+	input := `
+let scale = 2.5;
+fn area(w, h) {
+	if w < 0 or not (h > 0) {
+		return 0;
+	} else if h < 0 {
+		return 0;
+	}
+	let a = w * h;
+	return a * scale;
+}
+area(3, 4 - -1);
+defer area(0, 0);
+`
+	program := parse(t, input)
+
+	src, err := ToGo(program)
+	if err != nil {
+		t.Fatalf("ToGo returned an error: %s", err)
+	}
+
+	typeCheck(t, src)
+
+	expects := []string{
+		"var scale float64 = 2.5",
+		"func area(w float64, h float64) float64 {",
+		"if (w < 0) || (!(h > 0)) {",
+		"} else if h < 0 {",
+		"a := float64((w * h))",
+		"return (a * scale)",
+		"func main() {\n\tarea(3, (4 - (-1)))\n\tdefer area(0, 0)\n}",
+	}
+
+	for _, expect := range expects {
+		if !strings.Contains(src, expect) {
+			t.Errorf("generated source does not contain %q\n%s", expect, src)
+		}
+	}
+}
+
+// integer lets mix with the float64 parameters
+func TestToGoMixedNumbers(t *testing.T) {
+	program := parse(t, "let k = 2; fn f(x) { let a = 1; return x * k + a; } f(3);")
+
+	src, err := ToGo(program)
+	if err != nil {
+		t.Fatalf("ToGo returned an error: %s", err)
+	}
+
+	typeCheck(t, src)
+}
+
+func TestToGoUnsupported(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect string
+	}{
+		{"let f = fn(x) { return x; };", "transpile_test:1:9: unsupported node *ast.FunctionLiteral"},
+		// only calls can be Go statements
+		{"1 + 2;", "transpile_test:1:1: unsupported node *ast.ExpressionStatement"},
+		{"fn f(x) { x; }", "transpile_test:1:11: unsupported node *ast.ExpressionStatement"},
+	}
+
+	for _, test := range tests {
+		program := parse(t, test.input)
+
+		_, err := ToGo(program)
+		if err == nil {
+			t.Errorf("%q: expected an error for an unsupported node", test.input)
+			continue
+		}
+
+		if err.Error() != test.expect {
+			t.Errorf("%q: wrong error. expect=%q, got=%q", test.input, test.expect, err)
+		}
+	}
+}
+
+// parses and type checks the generated source as Go would
+func typeCheck(t *testing.T, src string) {
+	fset := gotoken.NewFileSet()
+	file, err := goparser.ParseFile(fset, "out.go", src, 0)
+	if err != nil {
+		t.Fatalf("generated source does not parse: %s\n%s", err, src)
+	}
+
+	if _, err := new(types.Config).Check("main", fset, []*goast.File{file}, nil); err != nil {
+		t.Fatalf("generated source does not type check: %s\n%s", err, src)
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	l := lexer.New("transpile_test", input)
+	p := parser.New(l)
+
+	program := p.Parse()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	return program
+}